@@ -1,14 +1,23 @@
 package cli
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 
+	"github.com/berrydev-ai/gojq-mcp/config"
 	"github.com/berrydev-ai/gojq-mcp/jq"
 )
 
 // RunCLIMode executes jq query on files in CLI mode
 func RunCLIMode(filePaths []string, query string) {
+	RunCLIModeWithOptions(filePaths, query, jq.Options{})
+}
+
+// RunCLIModeWithOptions executes jq query on files in CLI mode using opts
+func RunCLIModeWithOptions(filePaths []string, query string, opts jq.Options) {
 	if len(filePaths) == 0 {
 		fmt.Fprintf(os.Stderr, "Error: no file paths provided\n")
 		os.Exit(1)
@@ -26,25 +35,91 @@ func RunCLIMode(filePaths []string, query string) {
 		os.Exit(1)
 	}
 
-	// Validate and read all JSON files
-	jsonDataList, err := jq.ValidateAndReadJSONFiles(expandedPaths)
+	// Single JSON files are the query input; anything else is read through inputs
+	result, err := jq.ExecuteJQFilesWithOptions(context.Background(), query, expandedPaths, opts)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error reading files: %v\n", err)
+		fmt.Fprintf(os.Stderr, "Error executing jq query: %v\n", err)
 		os.Exit(1)
 	}
 
-	// Execute query based on number of files
-	var result string
-	if len(jsonDataList) == 1 {
-		result, err = jq.ExecuteJQ(query, jsonDataList[0])
-	} else {
-		result, err = jq.ExecuteJQMultiFiles(query, jsonDataList)
+	fmt.Println(result.Output)
+	if summary := result.Summary(); summary != "" {
+		fmt.Fprintln(os.Stderr, summary)
+	}
+}
+
+// RunValidate validates the files filePaths match against the JSON Schema in schemaPath,
+// printing the report and exiting with status 1 when a file does not conform
+func RunValidate(schemaPath string, filePaths []string, opts jq.Options) {
+	if schemaPath == "" {
+		fmt.Fprintf(os.Stderr, "Error: schema path is required. Use -s flag\n")
+		os.Exit(1)
+	}
+	if len(filePaths) == 0 {
+		fmt.Fprintf(os.Stderr, "Error: no file paths provided\n")
+		os.Exit(1)
 	}
 
+	expandedPaths, err := jq.ExpandGlobPatterns(filePaths)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error executing jq query: %v\n", err)
+		fmt.Fprintf(os.Stderr, "Error expanding glob patterns: %v\n", err)
+		os.Exit(1)
+	}
+
+	schema, err := jq.LoadJSONSchema(schemaPath, nil)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	report, err := jq.ValidateJSONFiles(context.Background(), expandedPaths, schema, opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error validating files: %v\n", err)
+		os.Exit(1)
+	}
+
+	output, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error formatting report: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Println(string(output))
+
+	invalid := 0
+	for _, file := range report.Files {
+		if !file.Valid {
+			invalid++
+		}
+	}
+	if invalid > 0 {
+		fmt.Fprintf(os.Stderr, "❌ %d of %d file(s) do not match %s\n", invalid, len(report.Files), schemaPath)
+		os.Exit(1)
+	}
+	fmt.Fprintf(os.Stderr, "✅ %d file(s) match %s\n", len(report.Files), schemaPath)
+}
+
+// RunGenerateConfig writes a configuration tailored to the dataset in dataPath. An existing
+// output file is only replaced when force is set.
+func RunGenerateConfig(dataPath string, outputPath string, force bool) {
+	if dataPath == "" {
+		fmt.Fprintf(os.Stderr, "Error: data path is required. Use -p flag\n")
+		os.Exit(1)
+	}
+
+	cfg, err := config.GenerateConfig(dataPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error generating config: %v\n", err)
+		os.Exit(1)
+	}
+
+	if err := config.WriteConfig(cfg, outputPath, force); err != nil {
+		if errors.Is(err, config.ErrConfigExists) {
+			fmt.Fprintf(os.Stderr, "❌ %s already exists. Use -force to overwrite it or -o to write elsewhere\n", outputPath)
+			os.Exit(1)
+		}
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
 
-	fmt.Println(result)
+	fmt.Fprintf(os.Stderr, "✅ Wrote configuration with %d prompt(s) to %s\n", len(cfg.Prompts), outputPath)
 }
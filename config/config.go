@@ -3,25 +3,102 @@ package config
 import (
 	"fmt"
 	"os"
+	"time"
 
+	"github.com/berrydev-ai/gojq-mcp/jq"
+	"github.com/berrydev-ai/gojq-mcp/sandbox"
 	"gopkg.in/yaml.v3"
 )
 
 // Config represents the YAML configuration file structure
 type Config struct {
-	DataPath     string         `yaml:"data_path"`
-	Transport    string         `yaml:"transport"`
-	Port         int            `yaml:"port"`
-	AuthToken    string         `yaml:"auth_token"`
-	Instructions string         `yaml:"instructions"`
-	Prompts      []PromptConfig `yaml:"prompts"`
+	DataPath       string         `yaml:"data_path"`
+	Transport      string         `yaml:"transport"`
+	Port           int            `yaml:"port"`
+	AuthToken      string         `yaml:"auth_token,omitempty"`
+	Instructions   string         `yaml:"instructions"`
+	MaxMemoryMB    int            `yaml:"max_memory_mb,omitempty"`
+	QueryTimeout   time.Duration  `yaml:"query_timeout,omitempty"`
+	MaxResultBytes int            `yaml:"max_result_bytes,omitempty"`
+	MaxResultItems int            `yaml:"max_result_items,omitempty"`
+	Symlinks       string         `yaml:"symlinks,omitempty"`
+	CacheMB        int            `yaml:"cache_mb,omitempty"`
+	LoadWorkers    int            `yaml:"load_workers,omitempty"`
+	Prompts        []PromptConfig `yaml:"prompts"`
+}
+
+// MemoryLimit returns the per-query memory ceiling in bytes, using jq.DefaultMemoryLimit
+// when max_memory_mb is not set
+func (c *Config) MemoryLimit() int64 {
+	if c.MaxMemoryMB <= 0 {
+		return jq.DefaultMemoryLimit
+	}
+	return int64(c.MaxMemoryMB) << 20
+}
+
+// Timeout returns the per-query timeout, using jq.DefaultTimeout when query_timeout is not set
+func (c *Config) Timeout() time.Duration {
+	if c.QueryTimeout <= 0 {
+		return jq.DefaultTimeout
+	}
+	return c.QueryTimeout
+}
+
+// CacheSize returns the memory budget in bytes of the parsed-document cache, using
+// jq.DefaultCacheSize when cache_mb is not set. A negative cache_mb disables the cache.
+func (c *Config) CacheSize() int64 {
+	if c.CacheMB < 0 {
+		return 0
+	}
+	if c.CacheMB == 0 {
+		return jq.DefaultCacheSize
+	}
+	return int64(c.CacheMB) << 20
+}
+
+// Workers returns the number of files a query decodes at the same time, using
+// jq.DefaultWorkers when load_workers is not set
+func (c *Config) Workers() int {
+	if c.LoadWorkers <= 0 {
+		return jq.DefaultWorkers
+	}
+	return c.LoadWorkers
+}
+
+// SymlinkPolicy returns how queries treat symbolic links in the data directory, using
+// sandbox.SymlinksWithinRoot when symlinks is not set
+func (c *Config) SymlinkPolicy() sandbox.SymlinkPolicy {
+	policy, err := sandbox.ParseSymlinkPolicy(c.Symlinks)
+	if err != nil {
+		return sandbox.SymlinksWithinRoot
+	}
+	return policy
+}
+
+// QueryOptions returns the limits applied to every query the server runs
+func (c *Config) QueryOptions() jq.Options {
+	opts := jq.Options{
+		MemoryLimit:    c.MemoryLimit(),
+		Timeout:        c.Timeout(),
+		MaxResultBytes: c.MaxResultBytes,
+		MaxResultItems: c.MaxResultItems,
+		Symlinks:       c.SymlinkPolicy(),
+		Workers:        c.Workers(),
+	}
+	if opts.MaxResultBytes <= 0 {
+		opts.MaxResultBytes = jq.DefaultMaxResultBytes
+	}
+	if opts.MaxResultItems <= 0 {
+		opts.MaxResultItems = jq.DefaultMaxResultItems
+	}
+	return opts
 }
 
 // PromptConfig defines a reusable prompt
 type PromptConfig struct {
-	Name        string                   `yaml:"name"`
-	Description string                   `yaml:"description"`
-	Arguments   []PromptArgumentConfig   `yaml:"arguments"`
+	Name        string                 `yaml:"name"`
+	Description string                 `yaml:"description"`
+	Arguments   []PromptArgumentConfig `yaml:"arguments"`
 }
 
 // PromptArgumentConfig defines a prompt argument
@@ -43,6 +120,10 @@ func LoadConfig(path string) (*Config, error) {
 		return nil, fmt.Errorf("error parsing config file: %w", err)
 	}
 
+	if _, err := sandbox.ParseSymlinkPolicy(config.Symlinks); err != nil {
+		return nil, fmt.Errorf("error parsing config file: %w", err)
+	}
+
 	// Set defaults if not specified
 	if config.Transport == "" {
 		config.Transport = "stdio"
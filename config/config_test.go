@@ -4,7 +4,10 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/berrydev-ai/gojq-mcp/jq"
+	"github.com/berrydev-ai/gojq-mcp/sandbox"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -49,6 +52,14 @@ data_path: /data
 			expected:    nil,
 			expectError: true,
 		},
+		{
+			name: "unsupported symlink policy",
+			configYAML: `data_path: /data
+symlinks: always
+`,
+			expected:    nil,
+			expectError: true,
+		},
 		{
 			name:        "nonexistent file",
 			configYAML:  "",
@@ -82,3 +93,36 @@ data_path: /data
 		})
 	}
 }
+
+func TestConfig_MemoryLimit(t *testing.T) {
+	assert.Equal(t, jq.DefaultMemoryLimit, (&Config{}).MemoryLimit())
+	assert.Equal(t, int64(256<<20), (&Config{MaxMemoryMB: 256}).MemoryLimit())
+}
+
+func TestConfig_Timeout(t *testing.T) {
+	assert.Equal(t, jq.DefaultTimeout, (&Config{}).Timeout())
+
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(configPath, []byte("data_path: ./data\nquery_timeout: 45s\n"), 0644))
+
+	cfg, err := LoadConfig(configPath)
+	require.NoError(t, err)
+	assert.Equal(t, 45*time.Second, cfg.Timeout())
+}
+
+func TestConfig_SymlinkPolicy(t *testing.T) {
+	assert.Equal(t, sandbox.SymlinksWithinRoot, (&Config{}).SymlinkPolicy())
+	assert.Equal(t, sandbox.SymlinksDeny, (&Config{Symlinks: "deny"}).SymlinkPolicy())
+	assert.Equal(t, sandbox.SymlinksFollow, (&Config{Symlinks: "follow"}).QueryOptions().Symlinks)
+}
+
+func TestConfig_Workers(t *testing.T) {
+	assert.Equal(t, jq.DefaultWorkers, (&Config{}).Workers())
+	assert.Equal(t, 1, (&Config{LoadWorkers: 1}).QueryOptions().Workers)
+}
+
+func TestConfig_CacheSize(t *testing.T) {
+	assert.Equal(t, jq.DefaultCacheSize, (&Config{}).CacheSize())
+	assert.Equal(t, int64(64<<20), (&Config{CacheMB: 64}).CacheSize())
+	assert.Equal(t, int64(0), (&Config{CacheMB: -1}).CacheSize())
+}
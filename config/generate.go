@@ -0,0 +1,518 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/berrydev-ai/gojq-mcp/jq"
+	"github.com/berrydev-ai/gojq-mcp/registry"
+	"gopkg.in/yaml.v3"
+)
+
+// maxSampleSize caps the size of the file read to infer a directory's top-level keys
+const maxSampleSize = 50 * 1024 * 1024
+
+// maxListedKeys caps the number of top-level keys listed per directory in the instructions
+const maxListedKeys = 12
+
+var (
+	dateNamePattern = regexp.MustCompile(`^\d{4}-\d{2}(-\d{2})?$`)
+	slugPattern     = regexp.MustCompile(`[^a-z0-9]+`)
+)
+
+// datasetGroup summarizes the files that share a directory
+type datasetGroup struct {
+	Dir       string
+	Files     []string
+	DateNamed bool
+	FirstDate string
+	LastDate  string
+	Ext       string
+	Shape     string
+	Keys      []string
+	InFamily  bool
+}
+
+// pattern returns the glob pattern matching every file in the group
+func (g *datasetGroup) pattern() string {
+	if g.Dir == "." {
+		return "*" + g.Ext
+	}
+	return g.Dir + "/*" + g.Ext
+}
+
+// slug returns an identifier for the group usable in prompt names
+func (g *datasetGroup) slug() string {
+	if g.Dir == "." {
+		return "base"
+	}
+	return strings.Trim(slugPattern.ReplaceAllString(strings.ToLower(g.Dir), "_"), "_")
+}
+
+// datedDirFamily groups sibling directories whose names are dates (e.g. 2025-01, 2025-02)
+type datedDirFamily struct {
+	Parent string
+	Groups []*datasetGroup
+}
+
+// periods returns the first and last directory names in the family
+func (f *datedDirFamily) periods() (string, string) {
+	return filepath.Base(f.Groups[0].Dir), filepath.Base(f.Groups[len(f.Groups)-1].Dir)
+}
+
+// slug returns an identifier for the family usable in prompt names
+func (f *datedDirFamily) slug() string {
+	if f.Parent == "." {
+		return "base"
+	}
+	return strings.Trim(slugPattern.ReplaceAllString(strings.ToLower(f.Parent), "_"), "_")
+}
+
+// pattern returns the glob pattern matching every file in the family, built from the
+// extensions its directories hold
+func (f *datedDirFamily) pattern() string {
+	seen := make(map[string]bool)
+	var exts []string
+	for _, group := range f.Groups {
+		if !seen[group.Ext] {
+			seen[group.Ext] = true
+			exts = append(exts, group.Ext)
+		}
+	}
+	sort.Strings(exts)
+
+	name := "*" + exts[0]
+	if len(exts) > 1 {
+		name = "*{" + strings.Join(exts, ",") + "}"
+	}
+
+	// Name the family's directories so unrelated siblings are left out
+	var dirs []string
+	for _, group := range f.Groups {
+		dir := filepath.Base(group.Dir)
+		if !seen[dir] {
+			seen[dir] = true
+			dirs = append(dirs, dir)
+		}
+	}
+	sort.Strings(dirs)
+	pattern := "{" + strings.Join(dirs, ",") + "}/" + name
+	if f.Parent == "." {
+		return pattern
+	}
+	return f.Parent + "/" + pattern
+}
+
+// GenerateConfig scans dataPath and builds a configuration with instructions and
+// prompts tailored to the layout of the dataset
+func GenerateConfig(dataPath string) (*Config, error) {
+	info, err := os.Stat(dataPath)
+	if err != nil {
+		return nil, fmt.Errorf("data path does not exist: %s", dataPath)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("data path is not a directory: %s", dataPath)
+	}
+
+	fileRegistry, err := registry.NewFileRegistry(dataPath)
+	if err != nil {
+		return nil, fmt.Errorf("error scanning data path: %w", err)
+	}
+	defer fileRegistry.Close()
+
+	rootPath, err := filepath.Abs(dataPath)
+	if err != nil {
+		return nil, fmt.Errorf("error resolving path: %w", err)
+	}
+
+	groups := groupFiles(rootPath, fileRegistry.GetFiles())
+	families := datedDirFamilies(groups)
+
+	return &Config{
+		DataPath:     dataPath,
+		Transport:    "stdio",
+		Port:         8080,
+		Instructions: buildInstructions(groups, families),
+		Prompts:      buildPrompts(groups, families),
+	}, nil
+}
+
+// ErrConfigExists is returned by WriteConfig when it would overwrite a file
+var ErrConfigExists = errors.New("config file already exists")
+
+// WriteConfig writes cfg as YAML to path. An existing file is only replaced when force is
+// set; the config is written to a temporary file first so a failed write leaves it intact.
+func WriteConfig(cfg *Config, path string, force bool) error {
+	if !force {
+		if _, err := os.Lstat(path); err == nil {
+			return fmt.Errorf("%w: %s", ErrConfigExists, path)
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("error accessing config file: %w", err)
+		}
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("# GoJQ MCP Server Configuration\n# Generated by gojq-mcp generate-config. Review the instructions and prompts before use.\n\n")
+
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(cfg); err != nil {
+		return fmt.Errorf("error encoding config: %w", err)
+	}
+	encoder.Close()
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("error writing config file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(buf.Bytes())
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0644)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		return fmt.Errorf("error writing config file: %w", err)
+	}
+
+	return nil
+}
+
+// groupFiles buckets files by directory and samples each bucket for its structure
+func groupFiles(rootPath string, files []registry.FileInfo) []*datasetGroup {
+	byDir := make(map[string]*datasetGroup)
+	var groups []*datasetGroup
+
+	for _, file := range files {
+		relPath, err := filepath.Rel(rootPath, file.Path)
+		if err != nil {
+			continue
+		}
+		relPath = filepath.ToSlash(relPath)
+		dir := filepath.ToSlash(filepath.Dir(relPath))
+
+		group, ok := byDir[dir]
+		if !ok {
+			group = &datasetGroup{Dir: dir, DateNamed: true}
+			byDir[dir] = group
+			groups = append(groups, group)
+		}
+		group.Files = append(group.Files, relPath)
+
+		// Directories mixing formats are described by their JSON files, if any, or else by
+		// the first format found
+		ext := jq.FileExt(relPath)
+		if group.Ext == "" || ext == ".json" {
+			group.Ext = ext
+		}
+
+		base := filepath.Base(relPath)
+		name := base[:len(base)-len(ext)]
+		if !dateNamePattern.MatchString(name) {
+			group.DateNamed = false
+			continue
+		}
+		if group.FirstDate == "" || name < group.FirstDate {
+			group.FirstDate = name
+		}
+		if name > group.LastDate {
+			group.LastDate = name
+		}
+	}
+
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].Dir < groups[j].Dir
+	})
+
+	for _, file := range files {
+		relPath, err := filepath.Rel(rootPath, file.Path)
+		if err != nil {
+			continue
+		}
+		group := byDir[filepath.ToSlash(filepath.Dir(relPath))]
+		if group.Shape != "" || file.Size > maxSampleSize {
+			continue
+		}
+		sampleStructure(group, file.Path)
+	}
+
+	return groups
+}
+
+// sampleStructure records the shape and top-level keys of a single file in the group
+func sampleStructure(group *datasetGroup, path string) {
+	docs, err := jq.ValidateAndReadJSONFiles([]string{path})
+	if err != nil || len(docs) == 0 {
+		return
+	}
+
+	if jq.IsJSONLinesFile(path) {
+		group.Shape = "lines"
+		if record, ok := docs[0].(map[string]interface{}); ok {
+			group.Keys = sortedKeys(record)
+		}
+		return
+	}
+
+	switch doc := docs[0].(type) {
+	case map[string]interface{}:
+		group.Shape = "object"
+		group.Keys = sortedKeys(doc)
+	case []interface{}:
+		group.Shape = "array"
+		if len(doc) > 0 {
+			if record, ok := doc[0].(map[string]interface{}); ok {
+				group.Keys = sortedKeys(record)
+			}
+		}
+	default:
+		group.Shape = "scalar"
+	}
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// datedDirFamilies finds parents with more than one date-named subdirectory
+func datedDirFamilies(groups []*datasetGroup) []*datedDirFamily {
+	byParent := make(map[string]*datedDirFamily)
+	var families []*datedDirFamily
+
+	for _, group := range groups {
+		if group.Dir == "." || !dateNamePattern.MatchString(filepath.Base(group.Dir)) {
+			continue
+		}
+		parent := filepath.ToSlash(filepath.Dir(group.Dir))
+		family, ok := byParent[parent]
+		if !ok {
+			family = &datedDirFamily{Parent: parent}
+			byParent[parent] = family
+			families = append(families, family)
+		}
+		family.Groups = append(family.Groups, group)
+	}
+
+	var result []*datedDirFamily
+	for _, family := range families {
+		if len(family.Groups) < 2 {
+			continue
+		}
+		for _, group := range family.Groups {
+			group.InFamily = true
+		}
+		result = append(result, family)
+	}
+	return result
+}
+
+// buildInstructions renders server instructions describing the dataset layout
+func buildInstructions(groups []*datasetGroup, families []*datedDirFamily) string {
+	var b strings.Builder
+
+	total := 0
+	for _, group := range groups {
+		total += len(group.Files)
+	}
+
+	b.WriteString("You are a helpful assistant that can query and analyze data files.\n\n")
+	fmt.Fprintf(&b, "## Dataset Layout\n\nThe data directory contains %d data file(s) in %d location(s):\n\n", total, len(groups))
+
+	for _, group := range groups {
+		location := group.Dir + "/"
+		if group.Dir == "." {
+			location = "(base directory)"
+		}
+		fmt.Fprintf(&b, "- `%s`: %d file(s)", location, len(group.Files))
+		if group.DateNamed && group.FirstDate != "" {
+			if group.FirstDate == group.LastDate {
+				fmt.Fprintf(&b, " named by date (%s)", group.FirstDate)
+			} else {
+				fmt.Fprintf(&b, " named by date (%s to %s)", group.FirstDate, group.LastDate)
+			}
+		}
+		b.WriteString(describeStructure(group))
+		b.WriteString("\n")
+	}
+
+	b.WriteString("\n## Getting Started\n\n")
+	b.WriteString("1. Call `list_data_files` to see what's available\n")
+	b.WriteString("2. Use the suggested patterns to construct queries\n")
+	b.WriteString("3. Run jq filters with `run_jq` to extract data\n")
+
+	b.WriteString("\n## Useful Patterns\n\n")
+	for _, group := range groups {
+		if len(group.Files) == 1 {
+			fmt.Fprintf(&b, "- `%s`\n", group.Files[0])
+			continue
+		}
+		fmt.Fprintf(&b, "- `%s` - all %d files in %s\n", group.pattern(), len(group.Files), describeDir(group.Dir))
+	}
+	for _, family := range families {
+		first, last := family.periods()
+		fmt.Fprintf(&b, "- `%s` - every file across %d date-named directories (%s to %s)\n",
+			family.pattern(), len(family.Groups), first, last)
+	}
+
+	b.WriteString("\n## Query Tips\n\n")
+	b.WriteString("- Multiple files are exposed through `inputs`: use `[inputs]` to collect them into an array\n")
+	b.WriteString("- Combine records across files: `[inputs | .[]]` or `[inputs | .<key>[]]`\n")
+	b.WriteString("- Check a file's shape before filtering: `keys` or `.[0]`\n")
+
+	return b.String()
+}
+
+// describeStructure renders the shape and keys of a group for the instructions
+func describeStructure(group *datasetGroup) string {
+	if len(group.Keys) == 0 {
+		switch group.Shape {
+		case "array":
+			return "; each file is an array"
+		case "lines":
+			return "; each file is JSON Lines with one record per line"
+		case "object":
+			return "; each file is an empty object"
+		}
+		return ""
+	}
+
+	keys := group.Keys
+	suffix := ""
+	if len(keys) > maxListedKeys {
+		suffix = fmt.Sprintf(", ... (%d more)", len(keys)-maxListedKeys)
+		keys = keys[:maxListedKeys]
+	}
+	quoted := make([]string, len(keys))
+	for i, key := range keys {
+		quoted[i] = "`" + key + "`"
+	}
+
+	if group.Shape == "lines" {
+		return "; each file is JSON Lines with records having fields " + strings.Join(quoted, ", ") + suffix
+	}
+	if group.Shape == "array" {
+		return "; each file is an array of records with fields " + strings.Join(quoted, ", ") + suffix
+	}
+	return "; top-level keys " + strings.Join(quoted, ", ") + suffix
+}
+
+func describeDir(dir string) string {
+	if dir == "." {
+		return "the base directory"
+	}
+	return dir
+}
+
+// buildPrompts creates starter prompts for the discovered layout
+func buildPrompts(groups []*datasetGroup, families []*datedDirFamily) []PromptConfig {
+	var prompts []PromptConfig
+
+	for _, group := range groups {
+		if group.DateNamed && group.FirstDate != "" && len(group.Files) > 1 {
+			dateFormat := "YYYY-MM-DD"
+			if len(group.FirstDate) == len("2006-01") {
+				dateFormat = "YYYY-MM"
+			}
+			prompts = append(prompts, PromptConfig{
+				Name:        "query_" + group.slug() + "_by_date",
+				Description: fmt.Sprintf("Query %s data for a specific date", describeDir(group.Dir)),
+				Arguments: []PromptArgumentConfig{
+					{
+						Name:        "date",
+						Description: fmt.Sprintf("Date in %s format (%s to %s)", dateFormat, group.FirstDate, group.LastDate),
+						Required:    true,
+					},
+					{
+						Name:        "filter",
+						Description: "Optional jq filter (default: returns all data)",
+						Required:    false,
+					},
+				},
+			})
+		}
+
+		if len(group.Keys) > 0 && !group.InFamily {
+			prompts = append(prompts, PromptConfig{
+				Name:        "summarize_" + group.slug(),
+				Description: fmt.Sprintf("Summarize the data in %s (%s)", describeDir(group.Dir), group.pattern()),
+				Arguments: []PromptArgumentConfig{
+					{
+						Name:        "field",
+						Description: "Optional field to focus on (e.g., " + group.Keys[0] + ")",
+						Required:    false,
+					},
+				},
+			})
+		}
+	}
+
+	for _, family := range families {
+		first, last := family.periods()
+		prompts = append(prompts, PromptConfig{
+			Name:        "query_" + family.slug() + "_by_period",
+			Description: fmt.Sprintf("Query %s data for a single period directory", describeDir(family.Parent)),
+			Arguments: []PromptArgumentConfig{
+				{
+					Name:        "period",
+					Description: fmt.Sprintf("Period directory name (%s to %s)", first, last),
+					Required:    true,
+				},
+				{
+					Name:        "filter",
+					Description: "Optional jq filter (default: returns all data)",
+					Required:    false,
+				},
+			},
+		})
+		prompts = append(prompts, PromptConfig{
+			Name:        "compare_" + family.slug() + "_periods",
+			Description: fmt.Sprintf("Compare data across the date-named directories in %s", describeDir(family.Parent)),
+			Arguments: []PromptArgumentConfig{
+				{
+					Name:        "periods",
+					Description: fmt.Sprintf("Space-separated periods to compare (%s to %s)", first, last),
+					Required:    true,
+				},
+			},
+		})
+	}
+
+	if len(groups) > 0 {
+		prompts = append(prompts, PromptConfig{
+			Name:        "count_by_field",
+			Description: "Count occurrences grouped by a specific field",
+			Arguments: []PromptArgumentConfig{
+				{
+					Name:        "file_pattern",
+					Description: fmt.Sprintf("File pattern (e.g., '%s')", groups[0].pattern()),
+					Required:    true,
+				},
+				{
+					Name:        "field",
+					Description: "Field name to group by",
+					Required:    true,
+				},
+			},
+		})
+	}
+
+	return prompts
+}
@@ -0,0 +1,131 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bmatcuk/doublestar/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateConfig(t *testing.T) {
+	tempDir := t.TempDir()
+
+	testFiles := map[string]string{
+		"users.json":             `{"users": [{"name": "Alice"}], "total": 1}`,
+		"calls/2025-01-01.json":  `[{"id": 1, "status": "completed"}]`,
+		"calls/2025-01-02.json":  `[{"id": 2, "status": "missed"}]`,
+		"revenue/2025-01/a.json": `{"transactions": []}`,
+		"revenue/2025-02/a.json": `{"transactions": []}`,
+		"events/2025-01/a.jsonl": `{"id": 1}`,
+		"events/2025-02/a.jsonl": `{"id": 2}`,
+	}
+	for file, content := range testFiles {
+		fullPath := filepath.Join(tempDir, file)
+		require.NoError(t, os.MkdirAll(filepath.Dir(fullPath), 0755))
+		require.NoError(t, os.WriteFile(fullPath, []byte(content), 0644))
+	}
+
+	cfg, err := GenerateConfig(tempDir)
+	require.NoError(t, err)
+
+	assert.Equal(t, tempDir, cfg.DataPath)
+	assert.Equal(t, "stdio", cfg.Transport)
+	assert.Equal(t, 8080, cfg.Port)
+
+	assert.Contains(t, cfg.Instructions, "7 data file(s)")
+	assert.Contains(t, cfg.Instructions, "`calls/`: 2 file(s) named by date (2025-01-01 to 2025-01-02)")
+	assert.Contains(t, cfg.Instructions, "array of records with fields `id`, `status`")
+	assert.Contains(t, cfg.Instructions, "top-level keys `total`, `users`")
+	assert.Contains(t, cfg.Instructions, "`revenue/{2025-01,2025-02}/*.json`")
+	assert.Contains(t, cfg.Instructions, "`events/{2025-01,2025-02}/*.jsonl`")
+
+	var names []string
+	for _, prompt := range cfg.Prompts {
+		names = append(names, prompt.Name)
+	}
+	assert.Contains(t, names, "query_calls_by_date")
+	assert.Contains(t, names, "summarize_calls")
+	assert.Contains(t, names, "summarize_base")
+	assert.Contains(t, names, "query_revenue_by_period")
+	assert.Contains(t, names, "compare_revenue_periods")
+	assert.Contains(t, names, "count_by_field")
+	assert.NotContains(t, names, "summarize_revenue_2025_01")
+}
+
+func TestGenerateConfig_UnrelatedSibling(t *testing.T) {
+	tempDir := t.TempDir()
+	for _, file := range []string{"2025-01/a.json", "2025-02/a.json", "users/a.json"} {
+		fullPath := filepath.Join(tempDir, file)
+		require.NoError(t, os.MkdirAll(filepath.Dir(fullPath), 0755))
+		require.NoError(t, os.WriteFile(fullPath, []byte(`{"id": 1}`), 0644))
+	}
+
+	cfg, err := GenerateConfig(tempDir)
+	require.NoError(t, err)
+	assert.Contains(t, cfg.Instructions, "`{2025-01,2025-02}/*.json` - every file across 2 date-named directories")
+
+	matches, err := doublestar.Glob(os.DirFS(tempDir), "{2025-01,2025-02}/*.json")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"2025-01/a.json", "2025-02/a.json"}, matches)
+}
+
+func TestGenerateConfig_InvalidPath(t *testing.T) {
+	_, err := GenerateConfig("/nonexistent/path")
+	assert.Error(t, err)
+
+	file := filepath.Join(t.TempDir(), "file.json")
+	require.NoError(t, os.WriteFile(file, []byte(`{}`), 0644))
+	_, err = GenerateConfig(file)
+	assert.Error(t, err)
+}
+
+func TestWriteConfig(t *testing.T) {
+	tempDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "2025-01-01.json"), []byte(`{"a": 1}`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "2025-01-02.json"), []byte(`{"a": 2}`), 0644))
+
+	cfg, err := GenerateConfig(tempDir)
+	require.NoError(t, err)
+
+	output := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, WriteConfig(cfg, output, false))
+
+	loaded, err := LoadConfig(output)
+	require.NoError(t, err)
+	assert.Equal(t, cfg, loaded)
+
+	// An existing file is only replaced when forced
+	require.NoError(t, os.WriteFile(output, []byte("transport: http\n"), 0644))
+	err = WriteConfig(cfg, output, false)
+	assert.ErrorIs(t, err, ErrConfigExists)
+	data, err := os.ReadFile(output)
+	require.NoError(t, err)
+	assert.Equal(t, "transport: http\n", string(data))
+
+	require.NoError(t, WriteConfig(cfg, output, true))
+	loaded, err = LoadConfig(output)
+	require.NoError(t, err)
+	assert.Equal(t, cfg, loaded)
+	entries, err := os.ReadDir(filepath.Dir(output))
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "the temporary file is renamed into place")
+}
+
+func TestDatedDirFamilyPattern(t *testing.T) {
+	family := &datedDirFamily{Parent: "exports", Groups: []*datasetGroup{
+		{Dir: "exports/2025-01", Ext: ".json.gz"},
+		{Dir: "exports/2025-02", Ext: ".jsonl"},
+		{Dir: "exports/2025-03", Ext: ".json.gz"},
+	}}
+	assert.Equal(t, "exports/{2025-01,2025-02,2025-03}/*{.json.gz,.jsonl}", family.pattern())
+
+	family = &datedDirFamily{Parent: ".", Groups: []*datasetGroup{
+		{Dir: "2025-01", Ext: ".yaml"},
+		{Dir: "2025-01", Ext: ".yml"},
+		{Dir: "2025-02", Ext: ".yaml"},
+	}}
+	assert.Equal(t, "{2025-01,2025-02}/*{.yaml,.yml}", family.pattern())
+}
@@ -0,0 +1,372 @@
+package jq
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ArchiveMember is a data file inside a zip or tar archive
+type ArchiveMember struct {
+	// Name is the member's slash-separated path inside the archive
+	Name     string
+	Size     int64
+	Modified time.Time
+}
+
+// IsArchive reports whether path names a zip or tar archive, including compressed tar
+// archives such as .tar.gz and .tgz
+func IsArchive(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".zip" || ext == ".tgz" || dataExt(path) == ".tar"
+}
+
+// SplitArchivePath splits a virtual path such as "data/bundle.zip/2025/01.json" into the
+// archive file on disk and the slash-separated name of the member inside it
+func SplitArchivePath(filePath string) (string, string, bool) {
+	filePath = filepath.Clean(filePath)
+	for i := 0; i < len(filePath); i++ {
+		if filePath[i] != filepath.Separator || i == 0 {
+			continue
+		}
+		archive := filePath[:i]
+		if !IsArchive(archive) {
+			continue
+		}
+		if info, err := os.Stat(archive); err == nil && info.Mode().IsRegular() {
+			return archive, filepath.ToSlash(filePath[i+1:]), true
+		}
+	}
+	return "", "", false
+}
+
+// ArchiveMembers lists the members of an archive that the jq package can read, sorted by
+// name. Members whose names would leave the archive, such as "../x.json", are skipped.
+func ArchiveMembers(archivePath string) ([]ArchiveMember, error) {
+	index, err := indexArchive(archivePath)
+	if err != nil {
+		return nil, err
+	}
+
+	var members []ArchiveMember
+	for _, name := range index.names {
+		if IsSupportedFile(name) {
+			info := index.members[name].info
+			members = append(members, ArchiveMember{Name: name, Size: info.Size(), Modified: info.ModTime()})
+		}
+	}
+	sort.Slice(members, func(i, j int) bool {
+		return members[i].Name < members[j].Name
+	})
+	return members, nil
+}
+
+// memberName cleans the name of an archive entry, returning false for directories and
+// names that are not local to the archive
+func memberName(name string) (string, bool) {
+	name = path.Clean(strings.TrimPrefix(name, "./"))
+	return name, fs.ValidPath(name) && name != "."
+}
+
+// idleStreamTimeout is how long a compressed tar stream is kept open after a member is read,
+// waiting for the query to open a later member
+const idleStreamTimeout = 2 * time.Second
+
+// archiveIndex lists the regular files of an archive. It is read in one pass and reused
+// while the archive keeps the size and modification time it was read with.
+type archiveIndex struct {
+	path     string
+	size     int64
+	modified time.Time
+	// names are the members in archive order; the first of several with a name is indexed
+	names   []string
+	members map[string]indexedMember
+
+	mu sync.Mutex
+	// idle is the stream left after reading a member of a compressed tar archive, which can
+	// only be read from the start, so that reading the members in order decompresses it once
+	idle *tarStream
+}
+
+// indexedMember is the file info of a member and, in tar archives, where its data starts
+type indexedMember struct {
+	info   fs.FileInfo
+	offset int64
+}
+
+var (
+	archiveIndexesMu sync.Mutex
+	// archiveIndexes holds the index of every archive read, by path
+	archiveIndexes = make(map[string]*archiveIndex)
+)
+
+// indexArchive returns the index of an archive, reading it again only if it has changed
+func indexArchive(archivePath string) (*archiveIndex, error) {
+	info, err := os.Stat(archivePath)
+	if err != nil {
+		return nil, fmt.Errorf("archive %s is not readable: %w", archivePath, err)
+	}
+
+	archiveIndexesMu.Lock()
+	index, ok := archiveIndexes[archivePath]
+	archiveIndexesMu.Unlock()
+	if ok && index.size == info.Size() && index.modified.Equal(info.ModTime()) {
+		return index, nil
+	}
+
+	index = &archiveIndex{
+		path:     archivePath,
+		size:     info.Size(),
+		modified: info.ModTime(),
+		members:  make(map[string]indexedMember),
+	}
+	if err := index.read(); err != nil {
+		return nil, err
+	}
+
+	archiveIndexesMu.Lock()
+	if previous, ok := archiveIndexes[archivePath]; ok {
+		previous.closeIdle()
+	}
+	archiveIndexes[archivePath] = index
+	archiveIndexesMu.Unlock()
+	return index, nil
+}
+
+// add indexes a member unless one with the same name came before it
+func (index *archiveIndex) add(name string, member indexedMember) {
+	if _, ok := index.members[name]; !ok {
+		index.names = append(index.names, name)
+		index.members[name] = member
+	}
+}
+
+// read lists the members of the archive
+func (index *archiveIndex) read() error {
+	if strings.ToLower(filepath.Ext(index.path)) == ".zip" {
+		reader, err := zip.OpenReader(index.path)
+		if err != nil {
+			return fmt.Errorf("archive %s is not readable: %w", index.path, err)
+		}
+		defer reader.Close()
+
+		for _, file := range reader.File {
+			if name, ok := memberName(file.Name); ok && file.Mode().IsRegular() {
+				index.add(name, indexedMember{info: file.FileInfo()})
+			}
+		}
+		return nil
+	}
+
+	stream, err := openTarStream(index.path)
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+
+	reader := tar.NewReader(stream)
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("archive %s is not readable: %w", index.path, err)
+		}
+		// The tar reader reads no further than the header, so the data starts here
+		if name, ok := memberName(header.Name); ok && header.Typeflag == tar.TypeReg {
+			index.add(name, indexedMember{info: header.FileInfo(), offset: stream.offset})
+		}
+	}
+}
+
+// member returns the indexed member called name
+func (index *archiveIndex) member(name string) (indexedMember, error) {
+	member, ok := index.members[name]
+	if !ok {
+		return indexedMember{}, fmt.Errorf("%s not found in archive %s: %w", name, index.path, fs.ErrNotExist)
+	}
+	return member, nil
+}
+
+// takeStream returns a stream of the compressed tar archive positioned at or before offset,
+// reusing the idle stream when it has not passed offset
+func (index *archiveIndex) takeStream(offset int64) (*tarStream, error) {
+	index.mu.Lock()
+	stream := index.idle
+	index.idle = nil
+	index.mu.Unlock()
+
+	if stream != nil {
+		stream.timer.Stop()
+		if stream.offset <= offset {
+			return stream, nil
+		}
+		stream.Close()
+	}
+	return openTarStream(index.path)
+}
+
+// putStream keeps stream for the next member until idleStreamTimeout passes
+func (index *archiveIndex) putStream(stream *tarStream) {
+	index.mu.Lock()
+	previous := index.idle
+	index.idle = stream
+	stream.timer = time.AfterFunc(idleStreamTimeout, func() {
+		index.mu.Lock()
+		idle := index.idle == stream
+		if idle {
+			index.idle = nil
+		}
+		index.mu.Unlock()
+		if idle {
+			stream.Close()
+		}
+	})
+	index.mu.Unlock()
+
+	if previous != nil {
+		previous.timer.Stop()
+		previous.Close()
+	}
+}
+
+// closeIdle closes the idle stream, if any
+func (index *archiveIndex) closeIdle() {
+	index.mu.Lock()
+	stream := index.idle
+	index.idle = nil
+	index.mu.Unlock()
+	if stream != nil {
+		stream.timer.Stop()
+		stream.Close()
+	}
+}
+
+// tarStream reads a tar archive, decompressed, counting the bytes read
+type tarStream struct {
+	file   io.ReadCloser
+	offset int64
+	timer  *time.Timer
+}
+
+func openTarStream(archivePath string) (*tarStream, error) {
+	file, err := OpenFile(archivePath)
+	if err != nil {
+		return nil, fmt.Errorf("archive %s is not readable: %w", archivePath, err)
+	}
+	return &tarStream{file: file}, nil
+}
+
+func (s *tarStream) Read(p []byte) (int, error) {
+	n, err := s.file.Read(p)
+	s.offset += int64(n)
+	return n, err
+}
+
+func (s *tarStream) Close() error {
+	return s.file.Close()
+}
+
+// statArchiveMember returns the file info of a member of an archive
+func statArchiveMember(archivePath, member string) (fs.FileInfo, error) {
+	index, err := indexArchive(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	indexed, err := index.member(member)
+	if err != nil {
+		return nil, err
+	}
+	return indexed.info, nil
+}
+
+// openArchiveMember opens a member of an archive without extracting it. Zip members and
+// members of uncompressed tar archives are read directly. Compressed tar archives are
+// decompressed up to the member, continuing from the previous member when it came earlier.
+func openArchiveMember(archivePath, member string) (io.ReadCloser, error) {
+	index, err := indexArchive(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	indexed, err := index.member(member)
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.ToLower(filepath.Ext(archivePath)) == ".zip" {
+		reader, err := zip.OpenReader(archivePath)
+		if err != nil {
+			return nil, fmt.Errorf("archive %s is not readable: %w", archivePath, err)
+		}
+		file, err := reader.Open(member)
+		if err != nil {
+			reader.Close()
+			return nil, err
+		}
+		return &archiveMemberFile{ReadCloser: file, archive: reader}, nil
+	}
+
+	if Compression(archivePath) == "" {
+		file, err := os.Open(archivePath)
+		if err != nil {
+			return nil, fmt.Errorf("archive %s is not readable: %w", archivePath, err)
+		}
+		section := io.NewSectionReader(file, indexed.offset, indexed.info.Size())
+		return &archiveMemberFile{ReadCloser: io.NopCloser(section), archive: file}, nil
+	}
+
+	stream, err := index.takeStream(indexed.offset)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.CopyN(io.Discard, stream, indexed.offset-stream.offset); err != nil {
+		stream.Close()
+		return nil, fmt.Errorf("archive %s is not readable: %w", archivePath, err)
+	}
+	return &tarMemberFile{Reader: io.LimitReader(stream, indexed.info.Size()), stream: stream, index: index}, nil
+}
+
+// tarMemberFile reads a member of a compressed tar archive and hands the stream back to the
+// index when closed
+type tarMemberFile struct {
+	io.Reader
+	stream *tarStream
+	index  *archiveIndex
+}
+
+func (f *tarMemberFile) Close() error {
+	f.index.putStream(f.stream)
+	return nil
+}
+
+// archiveMemberFile reads a member and closes the archive it came from
+type archiveMemberFile struct {
+	io.ReadCloser
+	archive io.Closer
+}
+
+func (f *archiveMemberFile) Close() error {
+	err := f.ReadCloser.Close()
+	if archiveErr := f.archive.Close(); err == nil {
+		err = archiveErr
+	}
+	return err
+}
+
+// statFile returns the file info of a file on disk or of a member of an archive
+func statFile(filePath string) (fs.FileInfo, error) {
+	if archive, member, ok := SplitArchivePath(filePath); ok {
+		return statArchiveMember(archive, member)
+	}
+	return os.Stat(filePath)
+}
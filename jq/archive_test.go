@@ -0,0 +1,211 @@
+package jq
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// zipData builds a zip archive holding files keyed by their names inside it
+func zipData(t *testing.T, files map[string]string) []byte {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, content := range files {
+		f, err := w.Create(name)
+		require.NoError(t, err)
+		_, err = f.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+// tarData builds a tar archive holding files keyed by their names inside it
+func tarData(t *testing.T, files map[string]string) []byte {
+	var buf bytes.Buffer
+	w := tar.NewWriter(&buf)
+	for name, content := range files {
+		require.NoError(t, w.WriteHeader(&tar.Header{
+			Name:     name,
+			Mode:     0644,
+			Size:     int64(len(content)),
+			ModTime:  time.Now(),
+			Typeflag: tar.TypeReg,
+		}))
+		_, err := w.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func setupArchives(t *testing.T) string {
+	tempDir := t.TempDir()
+	files := map[string][]byte{
+		"bundle.zip": zipData(t, map[string]string{
+			"2025/01.json":    `{"month": 1, "total": 10}`,
+			"2025/02.json.gz": string(gzipData(t, `{"month": 2, "total": 20}`)),
+			"notes.txt":       "not data",
+			"../escape.json":  `{"month": 0}`,
+		}),
+		"logs.tar.gz": gzipData(t, string(tarData(t, map[string]string{
+			"./events.jsonl": "{\"id\": 1}\n{\"id\": 2}\n",
+			"config.yaml":    "name: logs\n",
+		}))),
+		"plain.json": []byte(`{"month": 3, "total": 30}`),
+	}
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(tempDir, name), content, 0644))
+	}
+	return tempDir
+}
+
+func TestArchiveMembers(t *testing.T) {
+	tempDir := setupArchives(t)
+
+	members, err := ArchiveMembers(filepath.Join(tempDir, "bundle.zip"))
+	require.NoError(t, err)
+	var names []string
+	for _, member := range members {
+		names = append(names, member.Name)
+	}
+	assert.Equal(t, []string{"2025/01.json", "2025/02.json.gz"}, names)
+
+	members, err = ArchiveMembers(filepath.Join(tempDir, "logs.tar.gz"))
+	require.NoError(t, err)
+	require.Len(t, members, 2)
+	assert.Equal(t, "config.yaml", members[0].Name)
+	assert.Equal(t, "events.jsonl", members[1].Name)
+	assert.Equal(t, int64(20), members[1].Size)
+
+	_, err = ArchiveMembers(filepath.Join(tempDir, "plain.json"))
+	assert.Error(t, err)
+}
+
+func TestSplitArchivePath(t *testing.T) {
+	tempDir := setupArchives(t)
+
+	archive, member, ok := SplitArchivePath(filepath.Join(tempDir, "bundle.zip", "2025", "01.json"))
+	require.True(t, ok)
+	assert.Equal(t, filepath.Join(tempDir, "bundle.zip"), archive)
+	assert.Equal(t, "2025/01.json", member)
+
+	_, _, ok = SplitArchivePath(filepath.Join(tempDir, "plain.json"))
+	assert.False(t, ok)
+	_, _, ok = SplitArchivePath(filepath.Join(tempDir, "missing.zip", "01.json"))
+	assert.False(t, ok)
+}
+
+func TestProcessJQQuery_Archives(t *testing.T) {
+	tempDir := setupArchives(t)
+
+	tests := []struct {
+		name      string
+		filter    string
+		patterns  []string
+		expected  string
+		expectErr string
+	}{
+		{name: "zip member", filter: ".total", patterns: []string{"bundle.zip/2025/01.json"}, expected: "10"},
+		{name: "compressed zip member", filter: ".total", patterns: []string{"bundle.zip/2025/02.json.gz"}, expected: "20"},
+		{name: "glob inside an archive", filter: "[inputs.month] | add", patterns: []string{"bundle.zip/2025/*"}, expected: "3"},
+		{name: "recursive glob stays out of archives", filter: ".total", patterns: []string{"**/*.json"}, expected: "30"},
+		{name: "glob naming archives", filter: "[inputs.total] | add", patterns: []string{"**/*.zip/**/*.json", "*.json"}, expected: "40"},
+		{name: "excluding an archive", filter: ".month", patterns: []string{"*.json", "*.zip/**/*.json", "!bundle.zip/**"}, expected: "3"},
+		{name: "tar.gz JSON Lines member", filter: "[inputs.id] | add", patterns: []string{"logs.tar.gz/events.jsonl"}, expected: "3"},
+		{name: "tar.gz YAML member", filter: ".name", patterns: []string{"logs.tar.gz/config.yaml"}, expected: `"logs"`},
+		{name: "members are named by virtual path", filter: `[inputs | input_filename] | join(",")`, patterns: []string{"bundle.zip/**"}, expected: `"bundle.zip/2025/01.json,bundle.zip/2025/02.json.gz"`},
+		{name: "missing member", filter: ".", patterns: []string{"bundle.zip/2025/03.json"}, expectErr: "no files found"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := ProcessJQQueryWithOptions(context.Background(), tt.filter, tt.patterns, tempDir, Options{})
+			if tt.expectErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.expectErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, result.Output)
+		})
+	}
+
+	// Explicit member paths are read even when globbing does not list them
+	_, err := ExecuteJQFiles(".", []string{filepath.Join(tempDir, "bundle.zip", "2025", "03.json")})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "does not exist")
+}
+
+func TestArchiveIndex(t *testing.T) {
+	tempDir := t.TempDir()
+
+	// Members are written in order so reading them in order can reuse the stream
+	var buf bytes.Buffer
+	w := tar.NewWriter(&buf)
+	for _, name := range []string{"a.json", "b.json", "c.json"} {
+		content := fmt.Sprintf(`{"name": %q}`, name)
+		require.NoError(t, w.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}))
+		_, err := w.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Close())
+	plainPath := filepath.Join(tempDir, "data.tar")
+	require.NoError(t, os.WriteFile(plainPath, buf.Bytes(), 0644))
+	gzipPath := filepath.Join(tempDir, "data.tar.gz")
+	require.NoError(t, os.WriteFile(gzipPath, gzipData(t, buf.String()), 0644))
+
+	readMember := func(archivePath, member string) (string, *tarStream) {
+		file, err := openArchiveMember(archivePath, member)
+		require.NoError(t, err)
+		data, err := io.ReadAll(file)
+		require.NoError(t, err)
+		var stream *tarStream
+		if memberFile, ok := file.(*tarMemberFile); ok {
+			stream = memberFile.stream
+		}
+		require.NoError(t, file.Close())
+		return string(data), stream
+	}
+
+	// Uncompressed members are read at their offset
+	data, _ := readMember(plainPath, "b.json")
+	assert.Equal(t, `{"name": "b.json"}`, data)
+
+	// Compressed archives continue from the previous member when it came earlier
+	data, first := readMember(gzipPath, "a.json")
+	assert.Equal(t, `{"name": "a.json"}`, data)
+	data, next := readMember(gzipPath, "c.json")
+	assert.Equal(t, `{"name": "c.json"}`, data)
+	assert.Same(t, first, next)
+	data, restarted := readMember(gzipPath, "b.json")
+	assert.Equal(t, `{"name": "b.json"}`, data)
+	assert.NotSame(t, first, restarted)
+
+	// The index is read once and again only when the archive changes
+	index, err := indexArchive(plainPath)
+	require.NoError(t, err)
+	again, err := indexArchive(plainPath)
+	require.NoError(t, err)
+	assert.Same(t, index, again)
+
+	require.NoError(t, os.WriteFile(plainPath, tarData(t, map[string]string{"d.json": "{}"}), 0644))
+	require.NoError(t, os.Chtimes(plainPath, time.Now(), time.Now().Add(time.Minute)))
+	members, err := ArchiveMembers(plainPath)
+	require.NoError(t, err)
+	require.Len(t, members, 1)
+	assert.Equal(t, "d.json", members[0].Name)
+	_, err = statArchiveMember(plainPath, "a.json")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+}
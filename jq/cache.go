@@ -0,0 +1,263 @@
+package jq
+
+import (
+	"container/list"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+)
+
+// DefaultCacheSize is the memory budget of the document cache when none is configured
+const DefaultCacheSize int64 = 256 << 20
+
+// CacheStats reports how a DocumentCache is used, for tuning its budget
+type CacheStats struct {
+	Hits      int64 `json:"hits"`
+	Misses    int64 `json:"misses"`
+	Evictions int64 `json:"evictions"`
+	Entries   int   `json:"entries"`
+	Bytes     int64 `json:"bytes"`
+	Budget    int64 `json:"budget_bytes"`
+}
+
+// DocumentCache keeps the decoded documents of recently read files so repeated queries do
+// not read and parse them again. Entries are keyed by path and only served while the file
+// keeps the size and modification time it was read with. Each entry is charged an estimate
+// of the memory its documents take, and the least recently used entries are evicted once
+// the total passes the budget. A nil cache caches nothing.
+//
+// Cached documents are handed to queries without copying. gojq normalizes the numbers of
+// its inputs in place, so an entry is lent to one query at a time; a query reading a file
+// whose entry is lent to another decodes the file itself.
+type DocumentCache struct {
+	mu      sync.Mutex
+	budget  int64
+	bytes   int64
+	order   *list.List // most recently used first
+	entries map[string]*list.Element
+	hits    int64
+	misses  int64
+	evicted int64
+}
+
+// cacheEntry is the decoded documents of one file
+type cacheEntry struct {
+	path     string
+	size     int64
+	modified time.Time
+	cost     int64
+	// decoded is the number of bytes the values were decoded from
+	decoded int64
+	values  []interface{}
+	// holder is the query the values are lent to, if any; it is guarded by the cache's mu
+	holder *cacheLeases
+}
+
+// cacheLeases records the entries lent to one query so they can be returned when it finishes
+type cacheLeases struct {
+	cache   *DocumentCache
+	entries []*cacheEntry
+}
+
+// NewDocumentCache creates a cache holding up to budget bytes of documents, or returns nil
+// if budget is not positive
+func NewDocumentCache(budget int64) *DocumentCache {
+	if budget <= 0 {
+		return nil
+	}
+	return &DocumentCache{
+		budget:  budget,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// leases starts recording the entries lent to a query, or returns nil for a nil cache
+func (c *DocumentCache) leases() *cacheLeases {
+	if c == nil {
+		return nil
+	}
+	return &cacheLeases{cache: c}
+}
+
+// release returns the entries lent to a query; the query must not use their values after
+func (l *cacheLeases) release() {
+	if l == nil {
+		return
+	}
+	l.cache.mu.Lock()
+	defer l.cache.mu.Unlock()
+	for _, entry := range l.entries {
+		entry.holder = nil
+	}
+	l.entries = nil
+}
+
+// read returns the documents of filePath for the query holding leases, from the cache when
+// they are not lent to another query and by reading filePath within the limit of guard
+// otherwise. Documents read are cached and lent to the query. Without leases the cache is
+// not used.
+func (c *DocumentCache) read(filePath string, guard *memoryGuard, leases *cacheLeases) ([]interface{}, error) {
+	if c == nil || leases == nil {
+		return readFile(filePath, guard)
+	}
+
+	info, err := statFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("file %s is not readable: %w", filePath, err)
+	}
+	entry, lent := c.get(filePath, info.Size(), info.ModTime(), leases)
+	if entry != nil {
+		// The query holds the documents as if it had decoded them
+		if err := guard.charge(filePath, entry.decoded); err != nil {
+			return nil, err
+		}
+		return entry.values, nil
+	}
+
+	data, err := readData(filePath, guard)
+	if err != nil {
+		return nil, err
+	}
+	values, err := decodeData(filePath, data)
+	if err != nil {
+		return nil, err
+	}
+	if !lent {
+		c.put(&cacheEntry{
+			path:     filePath,
+			size:     info.Size(),
+			modified: info.ModTime(),
+			cost:     decodedSize(values),
+			decoded:  int64(len(data)),
+			values:   values,
+		}, leases)
+	}
+	return values, nil
+}
+
+// get lends the cached entry of path to leases if it was read from a file of the given
+// size and modification time. lent is set when the entry is lent to another query.
+func (c *DocumentCache) get(path string, size int64, modified time.Time, leases *cacheLeases) (entry *cacheEntry, lent bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[path]
+	if !ok {
+		c.misses++
+		return nil, false
+	}
+	entry = element.Value.(*cacheEntry)
+	if entry.size != size || !entry.modified.Equal(modified) {
+		c.remove(element)
+		c.misses++
+		return nil, false
+	}
+	if entry.holder != nil && entry.holder != leases {
+		c.misses++
+		return nil, true
+	}
+
+	c.hits++
+	c.order.MoveToFront(element)
+	c.lend(entry, leases)
+	return entry, false
+}
+
+// lend records that entry is lent to leases; the caller holds c.mu
+func (c *DocumentCache) lend(entry *cacheEntry, leases *cacheLeases) {
+	if entry.holder == nil {
+		entry.holder = leases
+		leases.entries = append(leases.entries, entry)
+	}
+}
+
+// put adds an entry lent to leases, evicting the least recently used entries to stay
+// within the budget. Entries larger than the whole budget are not kept.
+func (c *DocumentCache) put(entry *cacheEntry, leases *cacheLeases) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.entries[entry.path]; ok {
+		c.remove(element)
+	}
+	if entry.cost > c.budget {
+		return
+	}
+
+	for c.bytes+entry.cost > c.budget {
+		c.remove(c.order.Back())
+		c.evicted++
+	}
+	c.entries[entry.path] = c.order.PushFront(entry)
+	c.bytes += entry.cost
+	c.lend(entry, leases)
+}
+
+// remove drops an entry; the caller holds c.mu
+func (c *DocumentCache) remove(element *list.Element) {
+	entry := c.order.Remove(element).(*cacheEntry)
+	delete(c.entries, entry.path)
+	c.bytes -= entry.cost
+}
+
+// Invalidate drops the cached documents of the given paths
+func (c *DocumentCache) Invalidate(paths ...string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, path := range paths {
+		if element, ok := c.entries[path]; ok {
+			c.remove(element)
+		}
+	}
+}
+
+// Stats returns the cache's counters and current size
+func (c *DocumentCache) Stats() CacheStats {
+	if c == nil {
+		return CacheStats{}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return CacheStats{
+		Hits:      c.hits,
+		Misses:    c.misses,
+		Evictions: c.evicted,
+		Entries:   len(c.entries),
+		Bytes:     c.bytes,
+		Budget:    c.budget,
+	}
+}
+
+// decodedSize estimates the bytes of memory decoded values take: the strings and numbers
+// they hold and the headers of the interfaces, slices and maps around them
+func decodedSize(values ...interface{}) int64 {
+	var size int64
+	for _, value := range values {
+		// Every value sits in an interface of two words
+		size += 16
+		switch v := value.(type) {
+		case []interface{}:
+			size += 24 + decodedSize(v...)
+		case map[string]interface{}:
+			// Maps also keep a hash byte per slot and leave slots free
+			size += 48
+			for key, element := range v {
+				size += 24 + int64(len(key)) + decodedSize(element)
+			}
+		case string:
+			size += 16 + int64(len(v))
+		case *big.Int:
+			size += 32 + int64(len(v.Bits()))*8
+		case float64, int:
+			size += 8
+		}
+	}
+	return size
+}
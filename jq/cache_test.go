@@ -0,0 +1,157 @@
+package jq
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDocumentCache(t *testing.T) {
+	tempDir := t.TempDir()
+	usersPath := filepath.Join(tempDir, "users.json")
+	ordersPath := filepath.Join(tempDir, "orders.yaml")
+	require.NoError(t, os.WriteFile(usersPath, []byte(`{"users": [{"id": 1}, {"id": 2}]}`), 0644))
+	require.NoError(t, os.WriteFile(ordersPath, []byte("total: 3\n"), 0644))
+
+	cache := NewDocumentCache(1 << 20)
+	opts := Options{Cache: cache}
+	run := func(filter string, paths ...string) string {
+		result, err := ExecuteJQFilesWithOptions(context.Background(), filter, paths, opts)
+		require.NoError(t, err)
+		return result.Output
+	}
+
+	assert.Equal(t, "2", run(".users | length", usersPath))
+	assert.Equal(t, "2", run(".users | length", usersPath))
+	assert.Equal(t, "3", run("[inputs | .users // [.total]] | flatten | length", usersPath, ordersPath))
+	stats := cache.Stats()
+	assert.Equal(t, int64(2), stats.Hits)
+	assert.Equal(t, int64(2), stats.Misses)
+	assert.Equal(t, 2, stats.Entries)
+
+	// Updating a cached value must not change what later queries read
+	assert.Equal(t, "5", run(".users[0].id = 5 | .users[0].id", usersPath))
+	assert.Equal(t, "1", run(".users[0].id", usersPath))
+
+	// A file rewritten with a new size or modification time is read again
+	require.NoError(t, os.WriteFile(usersPath, []byte(`{"users": [{"id": 1}]}`), 0644))
+	require.NoError(t, os.Chtimes(usersPath, time.Now(), time.Now().Add(time.Minute)))
+	assert.Equal(t, "1", run(".users | length", usersPath))
+	assert.Equal(t, int64(3), cache.Stats().Misses)
+
+	cache.Invalidate(usersPath, ordersPath)
+	stats = cache.Stats()
+	assert.Equal(t, 0, stats.Entries)
+	assert.Equal(t, int64(0), stats.Bytes)
+}
+
+func TestDocumentCache_Budget(t *testing.T) {
+	tempDir := t.TempDir()
+	var paths []string
+	for _, name := range []string{"a.json", "b.json", "c.json"} {
+		path := filepath.Join(tempDir, name)
+		require.NoError(t, os.WriteFile(path, []byte(`{"padding": "0123456789"}`), 0644))
+		paths = append(paths, path)
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "big.json"), []byte(`{"padding": "0123456789012345678901234567890123456789012345678901234567890123456789"}`), 0644))
+
+	// Room for two of the small files
+	small, err := readFile(paths[0], nil)
+	require.NoError(t, err)
+	cost := decodedSize(small)
+	cache := NewDocumentCache(2*cost + cost/2)
+	leases := cache.leases()
+	for _, path := range paths {
+		_, err := cache.read(path, nil, leases)
+		require.NoError(t, err)
+	}
+	stats := cache.Stats()
+	assert.Equal(t, 2, stats.Entries)
+	assert.Equal(t, int64(1), stats.Evictions)
+	assert.Equal(t, 2*cost, stats.Bytes)
+
+	// The least recently used file was evicted
+	_, err = cache.read(paths[0], nil, leases)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), cache.Stats().Hits)
+
+	// Files larger than the budget are read but not kept
+	_, err = cache.read(filepath.Join(tempDir, "big.json"), nil, leases)
+	require.NoError(t, err)
+	assert.Equal(t, 2, cache.Stats().Entries)
+
+	assert.Nil(t, NewDocumentCache(0))
+	var disabled *DocumentCache
+	values, err := disabled.read(paths[1], nil, disabled.leases())
+	require.NoError(t, err)
+	assert.Len(t, values, 1)
+	assert.Equal(t, CacheStats{}, disabled.Stats())
+}
+
+func TestDocumentCache_Leases(t *testing.T) {
+	tempDir := t.TempDir()
+	path := filepath.Join(tempDir, "users.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"users": [{"id": 1}, {"id": 2}]}`), 0644))
+
+	cache := NewDocumentCache(1 << 20)
+	first, second := cache.leases(), cache.leases()
+	shared, err := cache.read(path, nil, first)
+	require.NoError(t, err)
+
+	// The query holding the documents is handed them again without copying
+	again, err := cache.read(path, nil, first)
+	require.NoError(t, err)
+	assert.Same(t, &shared[0], &again[0])
+
+	// Another query reads its own while they are lent
+	own, err := cache.read(path, nil, second)
+	require.NoError(t, err)
+	assert.Equal(t, shared, own)
+	assert.NotSame(t, &shared[0], &own[0])
+	assert.Equal(t, int64(1), cache.Stats().Hits)
+
+	first.release()
+	after, err := cache.read(path, nil, second)
+	require.NoError(t, err)
+	assert.Same(t, &shared[0], &after[0])
+
+	// Entries are charged what their documents take decoded, not their size on disk
+	assert.Equal(t, decodedSize(shared), cache.Stats().Bytes)
+	assert.Greater(t, cache.Stats().Bytes, int64(len(`{"users": [{"id": 1}, {"id": 2}]}`)))
+}
+
+func TestDocumentCache_ConcurrentQueries(t *testing.T) {
+	tempDir := t.TempDir()
+	path := filepath.Join(tempDir, "users.json")
+	users := make([]string, 1000)
+	for i := range users {
+		users[i] = fmt.Sprintf(`{"id": %d}`, i)
+	}
+	require.NoError(t, os.WriteFile(path, []byte(`{"users": [`+strings.Join(users, ",")+`]}`), 0644))
+
+	// gojq normalizes its input in place, which the race detector catches if queries share it
+	opts := Options{Cache: NewDocumentCache(1 << 20)}
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				result, err := ExecuteJQFilesWithOptions(context.Background(), ".users | map(.id) | add", []string{path}, opts)
+				if assert.NoError(t, err) {
+					assert.Equal(t, "499500", result.Output)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Positive(t, opts.Cache.Stats().Hits)
+}
@@ -0,0 +1,185 @@
+package jq
+
+import (
+	"compress/bzip2"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// compressions maps the extensions of compressed files to the name of their compression
+var compressions = map[string]string{
+	".gz":  "gzip",
+	".tgz": "gzip",
+	".zst": "zstd",
+	".bz2": "bzip2",
+}
+
+// Compression returns the compression of path by its extension, such as "gzip", or "" if
+// the file is not compressed
+func Compression(path string) string {
+	return compressions[strings.ToLower(filepath.Ext(path))]
+}
+
+// FileExt returns the extension of path including any compression extension, such as
+// ".json.gz", in lower case
+func FileExt(path string) string {
+	ext := strings.ToLower(filepath.Ext(path))
+	if compressions[ext] == "" {
+		return ext
+	}
+	return strings.ToLower(filepath.Ext(strings.TrimSuffix(path, filepath.Ext(path)))) + ext
+}
+
+// dataExt returns the extension of the data inside path, ignoring any compression extension
+func dataExt(path string) string {
+	if Compression(path) != "" {
+		path = strings.TrimSuffix(path, filepath.Ext(path))
+	}
+	return strings.ToLower(filepath.Ext(path))
+}
+
+// OpenFile opens a data file, or a member of an archive named by a virtual path such as
+// "bundle.zip/2025/01.json", for reading, decompressing it if it is compressed
+func OpenFile(filePath string) (io.ReadCloser, error) {
+	var file io.ReadCloser
+	var err error
+	if archive, member, ok := SplitArchivePath(filePath); ok {
+		file, err = openArchiveMember(archive, member)
+	} else {
+		file, err = os.Open(filePath)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var reader io.ReadCloser
+	switch Compression(filePath) {
+	case "":
+		return file, nil
+	case "gzip":
+		var gzipReader *gzip.Reader
+		if gzipReader, err = gzip.NewReader(file); err == nil {
+			reader = gzipReader
+		}
+	case "zstd":
+		var zstdReader *zstd.Decoder
+		if zstdReader, err = zstd.NewReader(file, zstd.WithDecoderConcurrency(1)); err == nil {
+			reader = zstdReader.IOReadCloser()
+		}
+	case "bzip2":
+		reader = io.NopCloser(bzip2.NewReader(file))
+	}
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("error decompressing %s: %w", filePath, err)
+	}
+
+	return &decompressedFile{ReadCloser: reader, file: file}, nil
+}
+
+// decompressedFile reads through a decompressor and closes both it and the file beneath it
+type decompressedFile struct {
+	io.ReadCloser
+	file io.Closer
+}
+
+func (f *decompressedFile) Close() error {
+	err := f.ReadCloser.Close()
+	if fileErr := f.file.Close(); err == nil {
+		err = fileErr
+	}
+	return err
+}
+
+// maxDeflateRatio is the most deflate compresses data, so gzip files smaller than
+// 4 GiB / maxDeflateRatio cannot hold a member whose size wrapped in the trailer
+const maxDeflateRatio = 1032
+
+// frameSlack allows for the headers, block headers and checksums around compressed data
+// when comparing a recorded size with the size of the file holding it
+const frameSlack = 1024
+
+// UncompressedSize returns the size of the data in a compressed file when its format records
+// it exactly: the gzip trailer of a single-member file under 4 GiB, or the zstd frame header
+// of a single-frame file. Sizes smaller than the compressed data belong to the last of
+// several members or frames and are not returned. bzip2 files do not record their size, and
+// the sizes of compressed archive members are not read.
+func UncompressedSize(filePath string) (int64, bool) {
+	size, ok := recordedSize(filePath)
+	if !ok {
+		return 0, false
+	}
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return 0, false
+	}
+	// Stored blocks add 5 bytes per 64 KiB of gzip data and block headers 3 per 128 KiB of zstd data
+	if info.Size() > size+size/8192+frameSlack {
+		return 0, false
+	}
+	// The gzip trailer holds the size modulo 2^32, which wraps for larger data
+	if Compression(filePath) == "gzip" && info.Size() >= (1<<32)/maxDeflateRatio {
+		return 0, false
+	}
+	return size, true
+}
+
+// recordedSize returns the size a compressed file records for its data. It is a lower bound:
+// the gzip trailer holds the size modulo 2^32 of the last member only, and the zstd frame
+// header the size of the first frame only.
+func recordedSize(filePath string) (int64, bool) {
+	if _, _, ok := SplitArchivePath(filePath); ok {
+		return 0, false
+	}
+	file, err := os.Open(filePath)
+	if err != nil {
+		return 0, false
+	}
+	defer file.Close()
+
+	switch Compression(filePath) {
+	case "gzip":
+		info, err := file.Stat()
+		if err != nil || info.Size() < 18 {
+			return 0, false
+		}
+		trailer := make([]byte, 4)
+		if _, err := file.ReadAt(trailer, info.Size()-4); err != nil {
+			return 0, false
+		}
+		return int64(binary.LittleEndian.Uint32(trailer)), true
+	case "zstd":
+		header := make([]byte, zstd.HeaderMaxSize)
+		n, err := io.ReadFull(file, header)
+		if err != nil && err != io.ErrUnexpectedEOF {
+			return 0, false
+		}
+		var h zstd.Header
+		if err := h.Decode(header[:n]); err != nil || !h.HasFCS {
+			return 0, false
+		}
+		return int64(h.FrameContentSize), true
+	}
+	return 0, false
+}
+
+// dataSize returns at least how many bytes decoding filePath reads: the size a compressed
+// file records, or else its size on disk. Files of several gzip members or zstd frames hold
+// more, so reads are also limited as they decode.
+func dataSize(filePath string) (int64, error) {
+	if size, ok := recordedSize(filePath); ok {
+		return size, nil
+	}
+	info, err := statFile(filePath)
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
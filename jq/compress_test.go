@@ -0,0 +1,158 @@
+package jq
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// bzip2Fixture is {"format": "bzip2"} compressed with bzip2, which the standard library cannot write
+const bzip2Fixture = "QlpoOTFBWSZTWf81HUYAAAiZgFAAEBAxItQaIAAxTAATQpoyA9PVF1YhY61AUkntfF3JFOFCQ/zUdRg="
+
+func gzipData(t *testing.T, data string) []byte {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	_, err := w.Write([]byte(data))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func zstdData(t *testing.T, data string) []byte {
+	encoder, err := zstd.NewWriter(nil)
+	require.NoError(t, err)
+	defer encoder.Close()
+	return encoder.EncodeAll([]byte(data), nil)
+}
+
+func TestExecuteJQFiles_Compressed(t *testing.T) {
+	tempDir := t.TempDir()
+	bzip2Data, err := base64.StdEncoding.DecodeString(bzip2Fixture)
+	require.NoError(t, err)
+
+	files := map[string][]byte{
+		"data.json.gz":     gzipData(t, `{"format": "gzip"}`),
+		"events.jsonl.zst": zstdData(t, "{\"id\": 1}\n{\"id\": 2}\n"),
+		"data.json.bz2":    bzip2Data,
+		"app.yaml.gz":      gzipData(t, "format: yaml\n"),
+		"broken.json.gz":   []byte("not gzip"),
+	}
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(tempDir, name), content, 0644))
+	}
+	path := func(name string) string { return filepath.Join(tempDir, name) }
+
+	tests := []struct {
+		name      string
+		filter    string
+		paths     []string
+		expected  string
+		expectErr string
+	}{
+		{name: "gzip", filter: ".format", paths: []string{path("data.json.gz")}, expected: `"gzip"`},
+		{name: "bzip2", filter: ".format", paths: []string{path("data.json.bz2")}, expected: `"bzip2"`},
+		{name: "compressed YAML", filter: ".format", paths: []string{path("app.yaml.gz")}, expected: `"yaml"`},
+		{name: "zstd JSON Lines", filter: "[inputs.id] | add", paths: []string{path("events.jsonl.zst")}, expected: "3"},
+		{name: "invalid gzip", filter: ".", paths: []string{path("broken.json.gz")}, expectErr: "error decompressing"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := ExecuteJQFiles(tt.filter, tt.paths)
+			if tt.expectErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.expectErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, result)
+		})
+	}
+
+	count, err := CountJSONLines(path("events.jsonl.zst"))
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	result, err := ExecuteJQFilesWithOptions(context.Background(), "[inputs | select(length == 2)] | length", []string{path("data.json.gz")}, Options{Stream: true})
+	require.NoError(t, err)
+	assert.Equal(t, "1", result.Output)
+}
+
+func TestUncompressedSize(t *testing.T) {
+	tempDir := t.TempDir()
+	data := strings.Repeat(`{"id": 1}`, 1000)
+
+	gzipPath := filepath.Join(tempDir, "data.json.gz")
+	require.NoError(t, os.WriteFile(gzipPath, gzipData(t, data), 0644))
+	size, ok := UncompressedSize(gzipPath)
+	assert.True(t, ok)
+	assert.Equal(t, int64(len(data)), size)
+
+	zstdPath := filepath.Join(tempDir, "data.json.zst")
+	require.NoError(t, os.WriteFile(zstdPath, zstdData(t, data), 0644))
+	size, ok = UncompressedSize(zstdPath)
+	assert.True(t, ok)
+	assert.Equal(t, int64(len(data)), size)
+
+	bzip2Data, err := base64.StdEncoding.DecodeString(bzip2Fixture)
+	require.NoError(t, err)
+	bzip2Path := filepath.Join(tempDir, "data.json.bz2")
+	require.NoError(t, os.WriteFile(bzip2Path, bzip2Data, 0644))
+	_, ok = UncompressedSize(bzip2Path)
+	assert.False(t, ok)
+
+	// The memory limit applies to the uncompressed size
+	_, err = ExecuteJQFilesWithOptions(context.Background(), ".", []string{gzipPath}, Options{MemoryLimit: 4096})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "is 9000 bytes, larger than the memory limit")
+}
+
+func TestUncompressedSize_MultiMember(t *testing.T) {
+	tempDir := t.TempDir()
+	ids := make([]string, 20000)
+	for i := range ids {
+		ids[i] = strconv.Itoa(i)
+	}
+
+	// Concatenated members decode as one stream; the trailer only has the size of the last
+	path := filepath.Join(tempDir, "ids.json.gz")
+	data := append(gzipData(t, "["+strings.Join(ids, ",")), gzipData(t, "]")...)
+	require.NoError(t, os.WriteFile(path, data, 0644))
+
+	_, ok := UncompressedSize(path)
+	assert.False(t, ok)
+	size, err := dataSize(path)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), size)
+
+	result, err := ExecuteJQFilesWithOptions(context.Background(), "length", []string{path}, Options{})
+	require.NoError(t, err)
+	assert.Equal(t, "20000", result.Output)
+
+	// The trailer passes the size check, so the limit is enforced while decoding
+	_, err = ExecuteJQFilesWithOptions(context.Background(), "length", []string{path}, Options{MemoryLimit: 4096})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "exceeded the memory limit of 4096 bytes reading "+path)
+}
+
+func TestFileExt(t *testing.T) {
+	assert.Equal(t, ".json.gz", FileExt("archive/2025-01-01.JSON.GZ"))
+	assert.Equal(t, ".jsonl", FileExt("events.jsonl"))
+	assert.Equal(t, ".gz", FileExt("backup.gz"))
+	assert.Equal(t, "gzip", Compression("data.json.gz"))
+	assert.Equal(t, "", Compression("data.json"))
+
+	assert.True(t, IsSupportedFile("data.json.zst"))
+	assert.True(t, IsJSONLinesFile("events.ndjson.bz2"))
+	assert.False(t, IsSupportedFile("backup.gz"))
+}
@@ -0,0 +1,262 @@
+package jq
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// DecodeFunc decodes the contents of a file into the values it holds, one per document.
+// Values must be JSON-like: nil, bool, int, float64, *big.Int, string, []interface{} and
+// map[string]interface{}.
+type DecodeFunc func(data []byte) ([]interface{}, error)
+
+// FileFormat describes a file format the jq package can read
+type FileFormat struct {
+	// Name identifies the format in manifests, e.g. "yaml"
+	Name string
+	// Label names the format in messages, e.g. "YAML"
+	Label string
+	// MIMEType is reported for files of this format
+	MIMEType string
+	// Decode reads a whole file; it is nil for JSON Lines, which is read line by line
+	Decode DecodeFunc
+}
+
+var (
+	jsonFormat      = FileFormat{Name: "json", Label: "JSON", MIMEType: "application/json", Decode: decodeJSON}
+	jsonLinesFormat = FileFormat{Name: "jsonl", Label: "JSON Lines", MIMEType: "application/x-ndjson"}
+	yamlFormat      = FileFormat{Name: "yaml", Label: "YAML", MIMEType: "application/yaml", Decode: decodeYAML}
+	tomlFormat      = FileFormat{Name: "toml", Label: "TOML", MIMEType: "application/toml", Decode: decodeTOML}
+	csvFormat       = FileFormat{Name: "csv", Label: "CSV", MIMEType: "text/csv", Decode: decodeCSV}
+)
+
+var (
+	formatsMu sync.RWMutex
+	// formats maps lower-case file extensions to the format of files with that extension
+	formats = map[string]FileFormat{
+		".json":   jsonFormat,
+		".jsonl":  jsonLinesFormat,
+		".ndjson": jsonLinesFormat,
+		".yaml":   yamlFormat,
+		".yml":    yamlFormat,
+		".toml":   tomlFormat,
+		".csv":    csvFormat,
+	}
+)
+
+// RegisterFormat makes files with extension ext, such as ".xml", readable as format,
+// replacing any format already registered for ext. JSON Lines extensions cannot be replaced.
+func RegisterFormat(ext string, format FileFormat) error {
+	ext = strings.ToLower(ext)
+	if !strings.HasPrefix(ext, ".") {
+		return fmt.Errorf("file extension %q must start with a dot", ext)
+	}
+	if format.Name == "" || format.Decode == nil {
+		return fmt.Errorf("format for %s needs a name and a decoder", ext)
+	}
+	if ext == ".jsonl" || ext == ".ndjson" {
+		return fmt.Errorf("%s files are always read as JSON Lines", ext)
+	}
+	if format.Label == "" {
+		format.Label = strings.ToUpper(format.Name)
+	}
+
+	formatsMu.Lock()
+	defer formatsMu.Unlock()
+	formats[ext] = format
+	return nil
+}
+
+// LookupFormat returns the format of path by its extension, looking past compression
+// extensions so that "events.jsonl.gz" is JSON Lines
+func LookupFormat(path string) (FileFormat, bool) {
+	formatsMu.RLock()
+	defer formatsMu.RUnlock()
+	format, ok := formats[dataExt(path)]
+	return format, ok
+}
+
+// formatOf returns the format path is decoded as; files with unknown extensions are read as JSON
+func formatOf(path string) FileFormat {
+	if format, ok := LookupFormat(path); ok && format.Decode != nil {
+		return format
+	}
+	return jsonFormat
+}
+
+// readFile reads and decodes the documents of a file that is not JSON Lines. A nil guard
+// places no limit on the bytes read.
+func readFile(filePath string, guard *memoryGuard) ([]interface{}, error) {
+	data, err := readData(filePath, guard)
+	if err != nil {
+		return nil, err
+	}
+	return decodeData(filePath, data)
+}
+
+// readData reads the contents of a file, decompressed, charging them to guard
+func readData(filePath string, guard *memoryGuard) ([]byte, error) {
+	file, err := OpenFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("file %s is not readable: %w", filePath, err)
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(guard.limitReader(file))
+	if err != nil {
+		return nil, fmt.Errorf("file %s is not readable: %w", filePath, err)
+	}
+	if err := guard.charge(filePath, int64(len(data))); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// decodeData decodes the contents of a file in its format
+func decodeData(filePath string, data []byte) ([]interface{}, error) {
+	format := formatOf(filePath)
+	values, err := format.Decode(data)
+	if err != nil {
+		return nil, fmt.Errorf("file %s does not contain valid %s: %w", filePath, format.Label, err)
+	}
+	return values, nil
+}
+
+func decodeJSON(data []byte) ([]interface{}, error) {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return nil, err
+	}
+	return []interface{}{value}, nil
+}
+
+// decodeYAML returns one value per document of a YAML stream
+func decodeYAML(data []byte) ([]interface{}, error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	var values []interface{}
+	for {
+		var value interface{}
+		if err := decoder.Decode(&value); err == io.EOF {
+			return values, nil
+		} else if err != nil {
+			return nil, err
+		}
+		value, err := normalizeValue(value)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+}
+
+func decodeTOML(data []byte) ([]interface{}, error) {
+	var document map[string]interface{}
+	if err := toml.Unmarshal(data, &document); err != nil {
+		return nil, err
+	}
+	value, err := normalizeValue(document)
+	if err != nil {
+		return nil, err
+	}
+	return []interface{}{value}, nil
+}
+
+// decodeCSV returns the rows of a CSV file as an array of objects keyed by the header row.
+// Cells are kept as strings; use tonumber in the filter to compare them as numbers.
+func decodeCSV(data []byte) ([]interface{}, error) {
+	reader := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))))
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	rows := make([]interface{}, 0, len(records))
+	if len(records) == 0 {
+		return []interface{}{rows}, nil
+	}
+
+	header := records[0]
+	for _, record := range records[1:] {
+		row := make(map[string]interface{}, len(header))
+		for i, column := range header {
+			row[column] = record[i]
+		}
+		rows = append(rows, row)
+	}
+	return []interface{}{rows}, nil
+}
+
+// normalizeValue converts decoded YAML and TOML values to the types gojq works with.
+// Timestamps become RFC 3339 strings and non-string map keys are formatted as strings.
+func normalizeValue(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case nil, bool, string, int, float64, *big.Int:
+		return v, nil
+	case int64:
+		return int(v), nil
+	case uint64:
+		if v > math.MaxInt64 {
+			return new(big.Int).SetUint64(v), nil
+		}
+		return int(v), nil
+	case float32:
+		return float64(v), nil
+	case time.Time:
+		return v.Format(time.RFC3339Nano), nil
+	case []interface{}:
+		values := make([]interface{}, len(v))
+		for i, element := range v {
+			normalized, err := normalizeValue(element)
+			if err != nil {
+				return nil, err
+			}
+			values[i] = normalized
+		}
+		return values, nil
+	case []map[string]interface{}:
+		values := make([]interface{}, len(v))
+		for i, element := range v {
+			normalized, err := normalizeValue(element)
+			if err != nil {
+				return nil, err
+			}
+			values[i] = normalized
+		}
+		return values, nil
+	case map[string]interface{}:
+		object := make(map[string]interface{}, len(v))
+		for key, element := range v {
+			normalized, err := normalizeValue(element)
+			if err != nil {
+				return nil, err
+			}
+			object[key] = normalized
+		}
+		return object, nil
+	case map[interface{}]interface{}:
+		object := make(map[string]interface{}, len(v))
+		for key, element := range v {
+			normalized, err := normalizeValue(element)
+			if err != nil {
+				return nil, err
+			}
+			object[fmt.Sprint(key)] = normalized
+		}
+		return object, nil
+	case fmt.Stringer:
+		// TOML local dates and times
+		return v.String(), nil
+	}
+	return nil, fmt.Errorf("unsupported value of type %T", value)
+}
@@ -0,0 +1,147 @@
+package jq
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExecuteJQFilesWithOptions_Formats(t *testing.T) {
+	tempDir := t.TempDir()
+
+	files := map[string]string{
+		"app.yaml":      "name: app\nreplicas: 3\ncreated: 2025-01-15T10:00:00Z\nports:\n  - 80\n  - 443\n",
+		"multi.yml":     "name: first\n---\nname: second\n",
+		"settings.toml": "title = \"settings\"\n\n[database]\nport = 5432\n\n[[servers]]\nname = \"alpha\"\n\n[[servers]]\nname = \"beta\"\n",
+		"users.csv":     "\xef\xbb\xbfname,age\nAnn,41\n\"Bob, Jr.\",25\n",
+		"broken.yaml":   "name: [unclosed\n",
+		"ragged.csv":    "a,b\n1\n",
+		"data.txt":      `{"read": "as JSON"}`,
+	}
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(tempDir, name), []byte(content), 0644))
+	}
+	path := func(name string) string { return filepath.Join(tempDir, name) }
+
+	tests := []struct {
+		name      string
+		filter    string
+		paths     []string
+		opts      Options
+		expected  string
+		expectErr string
+	}{
+		{
+			name:     "YAML document",
+			filter:   `[.name, .replicas + 1, .created, .ports[1]]`,
+			paths:    []string{path("app.yaml")},
+			opts:     Options{OutputFormat: FormatCompact},
+			expected: `["app",4,"2025-01-15T10:00:00Z",443]`,
+		},
+		{
+			name:     "each YAML document is an input",
+			filter:   `[inputs.name]`,
+			paths:    []string{path("multi.yml")},
+			opts:     Options{OutputFormat: FormatCompact},
+			expected: `["first","second"]`,
+		},
+		{
+			name:     "TOML tables and arrays of tables",
+			filter:   `[.title, .database.port, (.servers | map(.name))]`,
+			paths:    []string{path("settings.toml")},
+			opts:     Options{OutputFormat: FormatCompact},
+			expected: `["settings",5432,["alpha","beta"]]`,
+		},
+		{
+			name:     "CSV rows keyed by header",
+			filter:   `map(select(.age | tonumber > 30) | .name)`,
+			paths:    []string{path("users.csv")},
+			opts:     Options{OutputFormat: FormatCompact},
+			expected: `["Ann"]`,
+		},
+		{
+			name:     "mixed formats through inputs",
+			filter:   `[inputs | type]`,
+			paths:    []string{path("app.yaml"), path("settings.toml"), path("users.csv")},
+			opts:     Options{OutputFormat: FormatCompact},
+			expected: `["object","object","array"]`,
+		},
+		{
+			name:     "stream mode re-encodes other formats",
+			filter:   `[inputs | select(length == 2) | .[0] | join(".")]`,
+			paths:    []string{path("settings.toml")},
+			opts:     Options{Stream: true, OutputFormat: FormatCompact},
+			expected: `["database.port","servers.0.name","servers.1.name","title"]`,
+		},
+		{
+			name:     "unknown extensions are read as JSON",
+			filter:   `.read`,
+			paths:    []string{path("data.txt")},
+			expected: `"as JSON"`,
+		},
+		{
+			name:      "invalid YAML",
+			filter:    `.`,
+			paths:     []string{path("broken.yaml")},
+			expectErr: "does not contain valid YAML",
+		},
+		{
+			name:      "CSV rows must match the header",
+			filter:    `.`,
+			paths:     []string{path("ragged.csv")},
+			expectErr: "does not contain valid CSV",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := ExecuteJQFilesWithOptions(context.Background(), tt.filter, tt.paths, tt.opts)
+			if tt.expectErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.expectErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, result.Output)
+		})
+	}
+}
+
+func TestRegisterFormat(t *testing.T) {
+	err := RegisterFormat(".lines", FileFormat{
+		Name: "lines",
+		Decode: func(data []byte) ([]interface{}, error) {
+			var values []interface{}
+			for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+				values = append(values, line)
+			}
+			return values, nil
+		},
+	})
+	require.NoError(t, err)
+	defer func() {
+		formatsMu.Lock()
+		delete(formats, ".lines")
+		formatsMu.Unlock()
+	}()
+
+	format, ok := LookupFormat("notes.LINES")
+	require.True(t, ok)
+	assert.Equal(t, "LINES", format.Label)
+	assert.True(t, IsSupportedFile("notes.lines"))
+
+	filePath := filepath.Join(t.TempDir(), "notes.lines")
+	require.NoError(t, os.WriteFile(filePath, []byte("a\nb\n"), 0644))
+	output, err := ExecuteJQFiles(`[inputs]`, []string{filePath})
+	require.NoError(t, err)
+	assert.Equal(t, "[\n  \"a\",\n  \"b\"\n]", output)
+
+	assert.Error(t, RegisterFormat("lines", FileFormat{Name: "lines", Decode: decodeJSON}))
+	assert.Error(t, RegisterFormat(".jsonl", FileFormat{Name: "json", Decode: decodeJSON}))
+	assert.Error(t, RegisterFormat(".x", FileFormat{Name: "x"}))
+}
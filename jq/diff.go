@@ -0,0 +1,439 @@
+package jq
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/itchyny/gojq"
+)
+
+const (
+	// maxSummaryChanges is the number of changes Diff.Summary lists one per line
+	maxSummaryChanges = 100
+	// maxSummaryValueLength is the number of bytes of a value's JSON Diff.Summary shows
+	maxSummaryValueLength = 120
+)
+
+// DiffSource is one side of a comparison: a file, relative to the data directory, and an
+// optional jq filter applied to it. Without a filter the side is the file's document, or an
+// array of its records or documents for JSON Lines and multi-document files.
+type DiffSource struct {
+	Path   string
+	Filter string
+}
+
+// Diff is the difference between two JSON values
+type Diff struct {
+	// Patch is an RFC 6902 JSON Patch that turns the left value into the right one
+	Patch []PatchOperation `json:"patch"`
+	// Changes lists the same differences with old and new values, for people to read
+	Changes []DiffChange `json:"changes"`
+}
+
+// PatchOperation is one RFC 6902 operation
+type PatchOperation struct {
+	Op    string
+	From  string
+	Path  string
+	Value interface{}
+}
+
+// MarshalJSON writes the members RFC 6902 defines for the operation, including a null value
+func (op PatchOperation) MarshalJSON() ([]byte, error) {
+	switch op.Op {
+	case "remove":
+		return json.Marshal(struct {
+			Op   string `json:"op"`
+			Path string `json:"path"`
+		}{op.Op, op.Path})
+	case "move":
+		return json.Marshal(struct {
+			Op   string `json:"op"`
+			From string `json:"from"`
+			Path string `json:"path"`
+		}{op.Op, op.From, op.Path})
+	}
+	return json.Marshal(struct {
+		Op    string      `json:"op"`
+		Path  string      `json:"path"`
+		Value interface{} `json:"value"`
+	}{op.Op, op.Path, op.Value})
+}
+
+// DiffChange is a value that was added, removed, changed or moved. Path is written like a
+// jq path; elements of arrays matched by key are written as [key=value].
+type DiffChange struct {
+	Kind string      `json:"kind"`
+	Path string      `json:"path"`
+	Old  interface{} `json:"old"`
+	New  interface{} `json:"new"`
+}
+
+// MarshalJSON writes old for every kind but added and new for every kind but removed, so
+// a null value is kept rather than read as a missing one
+func (c DiffChange) MarshalJSON() ([]byte, error) {
+	switch c.Kind {
+	case "added":
+		return json.Marshal(struct {
+			Kind string      `json:"kind"`
+			Path string      `json:"path"`
+			New  interface{} `json:"new"`
+		}{c.Kind, c.Path, c.New})
+	case "removed":
+		return json.Marshal(struct {
+			Kind string      `json:"kind"`
+			Path string      `json:"path"`
+			Old  interface{} `json:"old"`
+		}{c.Kind, c.Path, c.Old})
+	}
+	type change DiffChange
+	return json.Marshal(change(c))
+}
+
+// Summary describes the changes one per line, after a line counting them
+func (d *Diff) Summary() string {
+	if len(d.Changes) == 0 {
+		return "No differences"
+	}
+
+	counts := make(map[string]int)
+	for _, change := range d.Changes {
+		counts[change.Kind]++
+	}
+	var parts []string
+	for _, kind := range []string{"added", "removed", "changed", "moved"} {
+		if counts[kind] > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", counts[kind], kind))
+		}
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%d change(s): %s", len(d.Changes), strings.Join(parts, ", "))
+	for i, change := range d.Changes {
+		if i == maxSummaryChanges {
+			fmt.Fprintf(&sb, "\n… and %d more change(s); see the patch for all of them", len(d.Changes)-i)
+			break
+		}
+		switch change.Kind {
+		case "added":
+			fmt.Fprintf(&sb, "\n+ %s: %s", change.Path, summaryValue(change.New))
+		case "removed":
+			fmt.Fprintf(&sb, "\n- %s: %s", change.Path, summaryValue(change.Old))
+		case "changed":
+			fmt.Fprintf(&sb, "\n~ %s: %s → %s", change.Path, summaryValue(change.Old), summaryValue(change.New))
+		case "moved":
+			fmt.Fprintf(&sb, "\n↕ %s: index %v → %v", change.Path, change.Old, change.New)
+		}
+	}
+	return sb.String()
+}
+
+// summaryValue renders a value as compact JSON, cut short when long
+func summaryValue(value interface{}) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return truncateStrings(string(data), maxSummaryValueLength).(string)
+}
+
+// DiffJSON compares two sources in the data directory. When key is not empty, elements of
+// arrays of objects that all have a distinct scalar key field are matched by that field
+// instead of by position.
+func DiffJSON(ctx context.Context, dataPath string, left, right DiffSource, key string, opts Options) (*Diff, error) {
+	leftValue, err := diffSourceValue(ctx, dataPath, left, opts)
+	if err != nil {
+		return nil, fmt.Errorf("left: %w", err)
+	}
+	rightValue, err := diffSourceValue(ctx, dataPath, right, opts)
+	if err != nil {
+		return nil, fmt.Errorf("right: %w", err)
+	}
+	return DiffValues(leftValue, rightValue, key), nil
+}
+
+// diffSourceValue reads the value one side of a comparison stands for
+func diffSourceValue(ctx context.Context, dataPath string, source DiffSource, opts Options) (interface{}, error) {
+	filePaths, box, err := resolvePatterns([]string{source.Path}, dataPath, opts.Symlinks)
+	if err != nil {
+		return nil, err
+	}
+	if len(filePaths) != 1 {
+		return nil, fmt.Errorf("%s matches %d files; give a single file", source.Path, len(filePaths))
+	}
+	if opts.BaseDir == "" {
+		opts.BaseDir = box.Root()
+	}
+
+	// Parts of the value end up in the diff, which outlives any loan of a cached document,
+	// so the file is read without the cache
+	opts.Cache = nil
+	value, err := fileContents(filePaths[0], newMemoryGuard(opts.MemoryLimit), opts)
+	if err != nil {
+		return nil, err
+	}
+	if source.Filter == "" {
+		return value, nil
+	}
+
+	files, err := newQueryFiles(filePaths, opts.BaseDir)
+	if err != nil {
+		return nil, err
+	}
+	names, bindings, err := queryBindings(opts, files)
+	if err != nil {
+		return nil, err
+	}
+	compiled, err := compiledQueries.get(source.Filter, names, nil, files)
+	if err != nil {
+		return nil, err
+	}
+	defer compiledQueries.put(compiled)
+
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+
+	// A filter producing several results stands for an array of them
+	var results []interface{}
+	iter := compiled.code.RunWithContext(ctx, value, bindings...)
+	for {
+		v, ok := iter.Next()
+		if !ok {
+			break
+		}
+		if err, ok := v.(error); ok {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return nil, interrupted(ctxErr, opts)
+			}
+			return nil, fmt.Errorf("jq execution error: %w", err)
+		}
+		results = append(results, v)
+	}
+	if len(results) == 1 {
+		return results[0], nil
+	}
+	return results, nil
+}
+
+// DiffValues compares two JSON values; key matches array elements as in DiffJSON
+func DiffValues(left, right interface{}, key string) *Diff {
+	d := &differ{key: key, diff: &Diff{Patch: []PatchOperation{}, Changes: []DiffChange{}}}
+	d.compare(left, right, "", ".")
+	return d.diff
+}
+
+// differ walks two values, recording a patch that applies in order to the left value
+type differ struct {
+	key  string
+	diff *Diff
+}
+
+func (d *differ) add(pointer, path string, value interface{}) {
+	d.diff.Patch = append(d.diff.Patch, PatchOperation{Op: "add", Path: pointer, Value: value})
+	d.diff.Changes = append(d.diff.Changes, DiffChange{Kind: "added", Path: path, New: value})
+}
+
+func (d *differ) remove(pointer, path string, value interface{}) {
+	d.diff.Patch = append(d.diff.Patch, PatchOperation{Op: "remove", Path: pointer})
+	d.diff.Changes = append(d.diff.Changes, DiffChange{Kind: "removed", Path: path, Old: value})
+}
+
+func (d *differ) replace(pointer, path string, old, new interface{}) {
+	d.diff.Patch = append(d.diff.Patch, PatchOperation{Op: "replace", Path: pointer, Value: new})
+	d.diff.Changes = append(d.diff.Changes, DiffChange{Kind: "changed", Path: path, Old: old, New: new})
+}
+
+// compare records the differences between left and right, found at pointer. path is the
+// same location written for people.
+func (d *differ) compare(left, right interface{}, pointer, path string) {
+	switch l := left.(type) {
+	case map[string]interface{}:
+		if r, ok := right.(map[string]interface{}); ok {
+			d.compareObjects(l, r, pointer, path)
+			return
+		}
+	case []interface{}:
+		if r, ok := right.([]interface{}); ok {
+			if leftKeys, rightKeys, ok := d.arrayKeys(l, r); ok {
+				d.compareKeyedArrays(l, r, leftKeys, rightKeys, pointer, path)
+			} else {
+				d.compareArrays(l, r, pointer, path)
+			}
+			return
+		}
+	}
+	if !equalValues(left, right) {
+		d.replace(pointer, path, left, right)
+	}
+}
+
+func (d *differ) compareObjects(left, right map[string]interface{}, pointer, path string) {
+	keys := make([]string, 0, len(left)+len(right))
+	for key := range left {
+		keys = append(keys, key)
+	}
+	for key := range right {
+		if _, ok := left[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		childPointer, childPath := pointer+"/"+escapePointer(key), fieldPath(path, key)
+		l, inLeft := left[key]
+		r, inRight := right[key]
+		switch {
+		case !inRight:
+			d.remove(childPointer, childPath, l)
+		case !inLeft:
+			d.add(childPointer, childPath, r)
+		default:
+			d.compare(l, r, childPointer, childPath)
+		}
+	}
+}
+
+// compareArrays compares elements by position; surplus elements are removed from the end
+// or appended
+func (d *differ) compareArrays(left, right []interface{}, pointer, path string) {
+	common := min(len(left), len(right))
+	for i := 0; i < common; i++ {
+		d.compare(left[i], right[i], pointer+"/"+strconv.Itoa(i), indexPath(path, i))
+	}
+	for i := len(left) - 1; i >= common; i-- {
+		d.remove(pointer+"/"+strconv.Itoa(i), indexPath(path, i), left[i])
+	}
+	for i := common; i < len(right); i++ {
+		d.add(pointer+"/"+strconv.Itoa(i), indexPath(path, i), right[i])
+	}
+}
+
+// arrayKeys returns the key of every element of both arrays when they are objects with
+// distinct scalar values for the differ's key field
+func (d *differ) arrayKeys(left, right []interface{}) ([]string, []string, bool) {
+	if d.key == "" || len(left)+len(right) == 0 {
+		return nil, nil, false
+	}
+	leftKeys, ok := elementKeys(left, d.key)
+	if !ok {
+		return nil, nil, false
+	}
+	rightKeys, ok := elementKeys(right, d.key)
+	if !ok {
+		return nil, nil, false
+	}
+	return leftKeys, rightKeys, true
+}
+
+// elementKeys returns the key field of each element as JSON, or false if an element has no
+// scalar key or two elements share one
+func elementKeys(elements []interface{}, key string) ([]string, bool) {
+	keys := make([]string, len(elements))
+	seen := make(map[string]bool, len(elements))
+	for i, element := range elements {
+		object, ok := element.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		value, ok := object[key]
+		if !ok {
+			return nil, false
+		}
+		switch value.(type) {
+		case map[string]interface{}, []interface{}, nil:
+			return nil, false
+		}
+		data, err := json.Marshal(value)
+		if err != nil || seen[string(data)] {
+			return nil, false
+		}
+		seen[string(data)] = true
+		keys[i] = string(data)
+	}
+	return keys, true
+}
+
+// compareKeyedArrays matches elements by key. The patch removes the elements whose key is
+// gone, then builds the right array in order, moving kept elements into place and adding
+// new ones.
+func (d *differ) compareKeyedArrays(left, right []interface{}, leftKeys, rightKeys []string, pointer, path string) {
+	inRight := make(map[string]bool, len(rightKeys))
+	for _, key := range rightKeys {
+		inRight[key] = true
+	}
+	keyPath := func(key string) string {
+		return path + "[" + d.key + "=" + key + "]"
+	}
+	if path == "." {
+		keyPath = func(key string) string {
+			return ".[" + d.key + "=" + key + "]"
+		}
+	}
+
+	// working holds the keys of the array as the patch so far leaves it
+	var working []string
+	originals := make(map[string]int, len(leftKeys))
+	for i := len(left) - 1; i >= 0; i-- {
+		if !inRight[leftKeys[i]] {
+			d.remove(pointer+"/"+strconv.Itoa(i), keyPath(leftKeys[i]), left[i])
+		}
+	}
+	for i, key := range leftKeys {
+		if inRight[key] {
+			working = append(working, key)
+			originals[key] = i
+		}
+	}
+
+	for j, key := range rightKeys {
+		elementPointer := pointer + "/" + strconv.Itoa(j)
+		i, kept := originals[key]
+		if !kept {
+			d.add(elementPointer, keyPath(key), right[j])
+			working = append(working[:j], append([]string{key}, working[j:]...)...)
+			continue
+		}
+
+		// Kept elements after j are still in the working array at a later position
+		p := j
+		for working[p] != key {
+			p++
+		}
+		if p != j {
+			d.diff.Patch = append(d.diff.Patch, PatchOperation{Op: "move", From: pointer + "/" + strconv.Itoa(p), Path: elementPointer})
+			d.diff.Changes = append(d.diff.Changes, DiffChange{Kind: "moved", Path: keyPath(key), Old: i, New: j})
+			working = append(working[:p], working[p+1:]...)
+			working = append(working[:j], append([]string{key}, working[j:]...)...)
+		}
+		d.compare(left[i], right[j], elementPointer, keyPath(key))
+	}
+}
+
+// indexPath appends an array index to a jq path
+func indexPath(path string, i int) string {
+	if path == "." {
+		return fmt.Sprintf(".[%d]", i)
+	}
+	return fmt.Sprintf("%s[%d]", path, i)
+}
+
+// escapePointer escapes a key for use as a JSON Pointer reference token (RFC 6901)
+func escapePointer(key string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(key, "~", "~0"), "/", "~1")
+}
+
+// equalValues reports whether two scalar values are equal, treating numbers by value
+func equalValues(left, right interface{}) bool {
+	if gojq.TypeOf(left) != gojq.TypeOf(right) {
+		return false
+	}
+	return gojq.Compare(left, right) == 0
+}
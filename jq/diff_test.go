@@ -0,0 +1,172 @@
+package jq
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func decodeDiffValue(t *testing.T, data string) interface{} {
+	t.Helper()
+	var value interface{}
+	require.NoError(t, json.Unmarshal([]byte(data), &value))
+	return value
+}
+
+func TestDiffValues(t *testing.T) {
+	tests := []struct {
+		name    string
+		left    string
+		right   string
+		key     string
+		patch   string
+		summary string
+	}{
+		{
+			name:    "identical values",
+			left:    `{"a": [1, 2], "b": {"c": null}}`,
+			right:   `{"b": {"c": null}, "a": [1, 2.0]}`,
+			patch:   `[]`,
+			summary: "No differences",
+		},
+		{
+			name:    "object members",
+			left:    `{"name": "Alice", "age": 30, "a/b": 1, "old": null}`,
+			right:   `{"name": "Alice", "age": 31, "a/b": 2, "new": null}`,
+			patch:   `[{"op":"replace","path":"/a~1b","value":2},{"op":"replace","path":"/age","value":31},{"op":"add","path":"/new","value":null},{"op":"remove","path":"/old"}]`,
+			summary: "4 change(s): 1 added, 1 removed, 2 changed\n~ .[\"a/b\"]: 1 → 2\n~ .age: 30 → 31\n+ .new: null\n- .old: null",
+		},
+		{
+			name:    "arrays by position",
+			left:    `{"tags": ["a", "b", "c", "d"]}`,
+			right:   `{"tags": ["a", "x"]}`,
+			patch:   `[{"op":"replace","path":"/tags/1","value":"x"},{"op":"remove","path":"/tags/3"},{"op":"remove","path":"/tags/2"}]`,
+			summary: "3 change(s): 2 removed, 1 changed\n~ .tags[1]: \"b\" → \"x\"\n- .tags[3]: \"d\"\n- .tags[2]: \"c\"",
+		},
+		{
+			name:    "types differ",
+			left:    `[1, "1", {"a": 1}]`,
+			right:   `[1, 1, [1], 4]`,
+			patch:   `[{"op":"replace","path":"/1","value":1},{"op":"replace","path":"/2","value":[1]},{"op":"add","path":"/3","value":4}]`,
+			summary: "3 change(s): 1 added, 2 changed\n~ .[1]: \"1\" → 1\n~ .[2]: {\"a\":1} → [1]\n+ .[3]: 4",
+		},
+		{
+			name:    "arrays by key",
+			left:    `{"users": [{"id": 1, "age": 30}, {"id": 2, "age": 40}, {"id": 3, "age": 50}]}`,
+			right:   `{"users": [{"id": 3, "age": 50}, {"id": 1, "age": 31}, {"id": 4, "age": 20}]}`,
+			key:     "id",
+			patch:   `[{"op":"remove","path":"/users/1"},{"op":"move","from":"/users/1","path":"/users/0"},{"op":"replace","path":"/users/1/age","value":31},{"op":"add","path":"/users/2","value":{"age":20,"id":4}}]`,
+			summary: "4 change(s): 1 added, 1 removed, 1 changed, 1 moved\n- .users[id=2]: {\"age\":40,\"id\":2}\n↕ .users[id=3]: index 2 → 0\n~ .users[id=1].age: 30 → 31\n+ .users[id=4]: {\"age\":20,\"id\":4}",
+		},
+		{
+			name:    "keys that are not distinct fall back to positions",
+			left:    `[{"id": 1, "v": 1}, {"id": 1, "v": 2}]`,
+			right:   `[{"id": 1, "v": 2}]`,
+			key:     "id",
+			patch:   `[{"op":"replace","path":"/0/v","value":2},{"op":"remove","path":"/1"}]`,
+			summary: "2 change(s): 1 removed, 1 changed\n~ .[0].v: 1 → 2\n- .[1]: {\"id\":1,\"v\":2}",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diff := DiffValues(decodeDiffValue(t, tt.left), decodeDiffValue(t, tt.right), tt.key)
+			patch, err := json.Marshal(diff.Patch)
+			require.NoError(t, err)
+			assert.JSONEq(t, tt.patch, string(patch))
+			assert.Equal(t, tt.summary, diff.Summary())
+		})
+	}
+}
+
+func TestDiffValues_SummaryLimits(t *testing.T) {
+	left := make([]interface{}, maxSummaryChanges+5)
+	right := make([]interface{}, maxSummaryChanges+5)
+	for i := range left {
+		left[i] = i
+		right[i] = i + 1
+	}
+	right[0] = map[string]interface{}{"text": string(make([]byte, 2*maxSummaryValueLength))}
+
+	diff := DiffValues(left, right, "")
+	assert.Len(t, diff.Patch, maxSummaryChanges+5)
+	summary := diff.Summary()
+	assert.Contains(t, summary, "… and 5 more change(s)")
+	assert.Contains(t, summary, "…\n")
+}
+
+func TestDiffJSON(t *testing.T) {
+	tempDir := t.TempDir()
+	files := map[string]string{
+		"monday.json":    `{"date": "2024-01-01", "users": [{"id": 1, "name": "Alice"}, {"id": 2, "name": "Bob"}]}`,
+		"tuesday.json":   `{"date": "2024-01-02", "users": [{"id": 2, "name": "Bobby"}, {"id": 1, "name": "Alice"}]}`,
+		"events.jsonl":   "{\"id\": 1}\n{\"id\": 2}\n",
+		"settings.yaml":  "name: app\nreplicas: 2\n",
+		"settings2.yaml": "name: app\nreplicas: 3\n",
+	}
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(tempDir, name), []byte(content), 0644))
+	}
+	ctx := context.Background()
+
+	diff, err := DiffJSON(ctx, tempDir, DiffSource{Path: "monday.json"}, DiffSource{Path: "tuesday.json"}, "id", Options{})
+	require.NoError(t, err)
+	assert.Equal(t, []DiffChange{
+		{Kind: "changed", Path: ".date", Old: "2024-01-01", New: "2024-01-02"},
+		{Kind: "moved", Path: ".users[id=2]", Old: 1, New: 0},
+		{Kind: "changed", Path: ".users[id=2].name", Old: "Bob", New: "Bobby"},
+	}, diff.Changes)
+
+	// Filters select what is compared, and can see the file's name
+	diff, err = DiffJSON(ctx, tempDir,
+		DiffSource{Path: "monday.json", Filter: ".users[] | select(.id == 2) | .name"},
+		DiffSource{Path: "tuesday.json", Filter: `.users[].name, input_filename`}, "", Options{})
+	require.NoError(t, err)
+	assert.Equal(t, []DiffChange{{Kind: "changed", Path: ".", Old: "Bob", New: []interface{}{"Bobby", "Alice", "tuesday.json"}}}, diff.Changes)
+
+	// JSON Lines files are compared as arrays of records
+	diff, err = DiffJSON(ctx, tempDir, DiffSource{Path: "events.jsonl"}, DiffSource{Path: "events.jsonl", Filter: "[.[] | select(.id > 1)]"}, "id", Options{})
+	require.NoError(t, err)
+	assert.Equal(t, []DiffChange{{Kind: "removed", Path: ".[id=1]", Old: map[string]interface{}{"id": float64(1)}}}, diff.Changes)
+
+	diff, err = DiffJSON(ctx, tempDir, DiffSource{Path: "settings.yaml"}, DiffSource{Path: "settings2.yaml"}, "", Options{})
+	require.NoError(t, err)
+	assert.Equal(t, "1 change(s): 1 changed\n~ .replicas: 2 → 3", diff.Summary())
+
+	errorTests := []struct {
+		name     string
+		left     DiffSource
+		right    DiffSource
+		expected string
+	}{
+		{"several files", DiffSource{Path: "*.json"}, DiffSource{Path: "monday.json"}, "left: *.json matches 2 files; give a single file"},
+		{"missing file", DiffSource{Path: "monday.json"}, DiffSource{Path: "sunday.json"}, "right:"},
+		{"outside the data directory", DiffSource{Path: "../monday.json"}, DiffSource{Path: "monday.json"}, "left:"},
+		{"invalid filter", DiffSource{Path: "monday.json"}, DiffSource{Path: "tuesday.json", Filter: ".users["}, "right:"},
+		{"filter error", DiffSource{Path: "monday.json", Filter: ".date | keys"}, DiffSource{Path: "tuesday.json"}, "left: jq execution error"},
+	}
+	for _, tt := range errorTests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := DiffJSON(ctx, tempDir, tt.left, tt.right, "", Options{})
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.expected)
+		})
+	}
+}
+
+func TestDiffChange_MarshalJSON(t *testing.T) {
+	diff := DiffValues(decodeDiffValue(t, `{"a": null, "b": 1, "c": null}`), decodeDiffValue(t, `{"a": 1, "b": null, "d": null}`), "")
+	changes, err := json.Marshal(diff.Changes)
+	require.NoError(t, err)
+	assert.JSONEq(t, `[
+		{"kind": "changed", "path": ".a", "old": null, "new": 1},
+		{"kind": "changed", "path": ".b", "old": 1, "new": null},
+		{"kind": "removed", "path": ".c", "old": null},
+		{"kind": "added", "path": ".d", "new": null}
+	]`, string(changes))
+}
@@ -0,0 +1,285 @@
+package jq
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/itchyny/gojq"
+)
+
+// maxRangeSize is the number of values a range with constant bounds may produce before
+// ExplainFilter warns about it
+const maxRangeSize = 1_000_000
+
+// FilterError is why a filter cannot run. Line, Column and Snippet are only set for syntax
+// errors; Column counts characters, from 1.
+type FilterError struct {
+	Message string `json:"message"`
+	Line    int    `json:"line,omitempty"`
+	Column  int    `json:"column,omitempty"`
+	// Snippet is the line the error is on with a caret under the error
+	Snippet string `json:"snippet,omitempty"`
+}
+
+func (e *FilterError) Error() string {
+	if e.Line == 0 {
+		return e.Message
+	}
+	return fmt.Sprintf("%s at line %d, column %d:\n%s", e.Message, e.Line, e.Column, e.Snippet)
+}
+
+// syntaxError locates a parse error in the filter it came from
+func syntaxError(jqFilter string, err error) error {
+	var parseErr *gojq.ParseError
+	if !errors.As(err, &parseErr) {
+		return err
+	}
+
+	// The offset is just past the offending token
+	offset := max(min(parseErr.Offset-len(parseErr.Token), len(jqFilter)), 0)
+	lineStart := strings.LastIndexByte(jqFilter[:offset], '\n') + 1
+	lineEnd := strings.IndexByte(jqFilter[offset:], '\n')
+	if lineEnd < 0 {
+		lineEnd = len(jqFilter)
+	} else {
+		lineEnd += offset
+	}
+	line := jqFilter[lineStart:lineEnd]
+	column := utf8.RuneCountInString(jqFilter[lineStart:offset]) + 1
+
+	return &FilterError{
+		Message: parseErr.Error(),
+		Line:    strings.Count(jqFilter[:offset], "\n") + 1,
+		Column:  column,
+		Snippet: "  " + line + "\n  " + strings.Repeat(" ", column-1) + "^",
+	}
+}
+
+// FilterExplanation describes a filter without running it
+type FilterExplanation struct {
+	Valid bool         `json:"valid"`
+	Error *FilterError `json:"error,omitempty"`
+	// Functions are the functions the filter calls, as name/arity; Defined are those it
+	// defines itself with def
+	Functions []string `json:"functions"`
+	Defined   []string `json:"defined,omitempty"`
+	// Variables are the variables the filter refers to, including ones it binds itself
+	Variables []string `json:"variables"`
+	// UsesInputs is set when the filter reads further inputs with input or inputs
+	UsesInputs bool     `json:"uses_inputs"`
+	Warnings   []string `json:"warnings"`
+}
+
+// ExplainFilter parses and compiles jqFilter as a query would, with opts' variables bound,
+// and describes it. A filter that does not parse or compile is reported in the explanation;
+// the error is only for invalid variables.
+func ExplainFilter(jqFilter string, opts Options) (*FilterExplanation, error) {
+	names, _, err := queryBindings(opts, &queryFiles{})
+	if err != nil {
+		return nil, err
+	}
+
+	explanation := &FilterExplanation{Functions: []string{}, Variables: []string{}, Warnings: []string{}}
+	query, err := gojq.Parse(jqFilter)
+	if err != nil {
+		explanation.Error = syntaxError(jqFilter, err).(*FilterError)
+		return explanation, nil
+	}
+
+	e := &explainer{
+		functions:  map[string]bool{},
+		defined:    map[string]bool{},
+		parameters: map[string]bool{},
+		variables:  map[string]bool{},
+		warned:     map[string]bool{},
+	}
+	e.walk(reflect.ValueOf(query), false)
+	for parameter := range e.parameters {
+		delete(e.functions, parameter+"/0")
+	}
+	explanation.Functions = sortedKeys(e.functions)
+	explanation.Defined = sortedKeys(e.defined)
+	explanation.Variables = sortedKeys(e.variables)
+	explanation.UsesInputs = e.functions["input/0"] || e.functions["inputs/0"]
+	if e.warnings != nil {
+		explanation.Warnings = e.warnings
+	}
+
+	// Compiled as run_jq compiles it, so undefined functions and variables are reported
+	_, err = gojq.Compile(query,
+		gojq.WithVariables(names),
+		gojq.WithFunction("input_filename", 0, 0, func(interface{}, []interface{}) interface{} { return nil }),
+		gojq.WithInputIter(gojq.NewIter()),
+	)
+	if err != nil {
+		explanation.Error = &FilterError{Message: err.Error()}
+		return explanation, nil
+	}
+	explanation.Valid = true
+	return explanation, nil
+}
+
+// explainer collects what a parsed filter uses
+type explainer struct {
+	functions map[string]bool
+	defined   map[string]bool
+	// parameters are the names of function parameters, which are called like functions
+	parameters map[string]bool
+	variables  map[string]bool
+	warned     map[string]bool
+	warnings   []string
+}
+
+var (
+	funcType    = reflect.TypeOf(&gojq.Func{})
+	funcDefType = reflect.TypeOf(&gojq.FuncDef{})
+	keyValType  = reflect.TypeOf(&gojq.ObjectKeyVal{})
+	termType    = reflect.TypeOf(&gojq.Term{})
+)
+
+// warn records a warning once, however often the filter repeats what causes it
+func (e *explainer) warn(format string, args ...interface{}) {
+	warning := fmt.Sprintf(format, args...)
+	if !e.warned[warning] {
+		e.warned[warning] = true
+		e.warnings = append(e.warnings, warning)
+	}
+}
+
+// walk visits the parsed filter. gojq's syntax tree has a type for every kind of
+// expression, so it is walked by reflection, stopping at calls, definitions and
+// recursion. bounded is set inside the generator of limit and first, which stop early.
+func (e *explainer) walk(v reflect.Value, bounded bool) {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			return
+		}
+		switch v.Type() {
+		case funcType:
+			e.call(v.Interface().(*gojq.Func), bounded)
+			return
+		case funcDefType:
+			def := v.Interface().(*gojq.FuncDef)
+			e.defined[fmt.Sprintf("%s/%d", def.Name, len(def.Args))] = true
+			for _, arg := range def.Args {
+				if !strings.HasPrefix(arg, "$") {
+					e.parameters[arg] = true
+				}
+			}
+		case keyValType:
+			// {$name} is short for {name: $name}
+			if keyVal := v.Interface().(*gojq.ObjectKeyVal); strings.HasPrefix(keyVal.Key, "$") && keyVal.Val == nil {
+				e.variables[keyVal.Key] = true
+			}
+		case termType:
+			if v.Interface().(*gojq.Term).Type == gojq.TermTypeRecurse {
+				e.warn("'..' visits every value in the input, which is slow on large files; prefer a path such as .items[].name")
+			}
+		}
+		e.walk(v.Elem(), bounded)
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).IsExported() {
+				e.walk(v.Field(i), bounded)
+			}
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			e.walk(v.Index(i), bounded)
+		}
+	}
+}
+
+// call records a function call or variable reference and visits its arguments
+func (e *explainer) call(f *gojq.Func, bounded bool) {
+	if strings.HasPrefix(f.Name, "$") {
+		e.variables[f.Name] = true
+		return
+	}
+	name := fmt.Sprintf("%s/%d", f.Name, len(f.Args))
+	e.functions[name] = true
+
+	switch name {
+	case "recurse/0":
+		e.warn("'recurse' visits every value in the input, which is slow on large files; prefer a path such as .items[].name")
+	case "recurse/1":
+		if !bounded {
+			e.warn("'recurse(f)' runs until f produces no output; make sure it stops, or use recurse(f; cond) or limit(n; ...)")
+		}
+	case "repeat/1":
+		if !bounded {
+			e.warn("'repeat' never stops on its own; wrap it in limit(n; ...) or first(...)")
+		}
+	case "range/1", "range/2", "range/3":
+		if size, ok := rangeSize(f.Args); ok && size > maxRangeSize && !bounded {
+			e.warn("'%s' produces %s values; use smaller bounds or limit(n; ...)", f, formatRangeSize(size))
+		}
+	}
+
+	for i, arg := range f.Args {
+		// The first argument of limit is the count, not the generator
+		argBounded := bounded || name == "first/1" || name == "limit/2" && i == 1
+		e.walk(reflect.ValueOf(arg), argBounded)
+	}
+}
+
+// rangeSize returns how many values a range call produces when its bounds are constants
+func rangeSize(args []*gojq.Query) (float64, bool) {
+	bounds := make([]float64, len(args))
+	for i, arg := range args {
+		bound, ok := constantNumber(arg)
+		if !ok {
+			return 0, false
+		}
+		bounds[i] = bound
+	}
+
+	switch len(bounds) {
+	case 1:
+		return math.Max(bounds[0], 0), true
+	case 2:
+		return math.Max(bounds[1]-bounds[0], 0), true
+	default:
+		if bounds[2] == 0 {
+			return 0, false
+		}
+		return math.Max((bounds[1]-bounds[0])/bounds[2], 0), true
+	}
+}
+
+// constantNumber returns the value of a number literal, a negated one or infinite
+func constantNumber(query *gojq.Query) (float64, bool) {
+	if query == nil || query.Term == nil || len(query.Term.SuffixList) > 0 {
+		return 0, false
+	}
+	term := query.Term
+	switch {
+	case term.Type == gojq.TermTypeNumber:
+		n, err := strconv.ParseFloat(term.Number, 64)
+		return n, err == nil
+	case term.Type == gojq.TermTypeFunc && term.Func.Name == "infinite" && len(term.Func.Args) == 0:
+		return math.Inf(1), true
+	case term.Type == gojq.TermTypeUnary && len(term.Unary.Term.SuffixList) == 0:
+		n, ok := constantNumber(&gojq.Query{Term: term.Unary.Term})
+		switch term.Unary.Op {
+		case gojq.OpSub:
+			return -n, ok
+		case gojq.OpAdd:
+			return n, ok
+		}
+	}
+	return 0, false
+}
+
+func formatRangeSize(size float64) string {
+	if math.IsInf(size, 1) {
+		return "infinitely many"
+	}
+	return strconv.FormatFloat(size, 'f', -1, 64)
+}
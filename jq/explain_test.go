@@ -0,0 +1,128 @@
+package jq
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExplainFilter_SyntaxErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		filter   string
+		expected FilterError
+	}{
+		{
+			name:     "unexpected token",
+			filter:   ".users[]]",
+			expected: FilterError{Message: `unexpected token "]"`, Line: 1, Column: 9, Snippet: "  .users[]]\n          ^"},
+		},
+		{
+			name:     "unexpected end",
+			filter:   ".users[",
+			expected: FilterError{Message: "unexpected EOF", Line: 1, Column: 8, Snippet: "  .users[\n         ^"},
+		},
+		{
+			name:     "later line",
+			filter:   "{a: 1,\n b: ]}",
+			expected: FilterError{Message: `unexpected token "]"`, Line: 2, Column: 5, Snippet: "   b: ]}\n      ^"},
+		},
+		{
+			name:     "columns count characters",
+			filter:   `"é" | é`,
+			expected: FilterError{Message: `unexpected token "é"`, Line: 1, Column: 7, Snippet: "  \"é\" | é\n        ^"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			explanation, err := ExplainFilter(tt.filter, Options{})
+			require.NoError(t, err)
+			assert.False(t, explanation.Valid)
+			require.NotNil(t, explanation.Error)
+			assert.Equal(t, tt.expected, *explanation.Error)
+		})
+	}
+}
+
+func TestExplainFilter(t *testing.T) {
+	explanation, err := ExplainFilter(`def total(f): reduce f as $x (0; . + $x); [inputs | total(.items[].price)] | map(select(. > $min)) | {file: input_filename, $min, env: $ENV.HOME}`, Options{Variables: map[string]interface{}{"min": 10}})
+	require.NoError(t, err)
+	assert.True(t, explanation.Valid)
+	assert.Nil(t, explanation.Error)
+	assert.Equal(t, []string{"input_filename/0", "inputs/0", "map/1", "select/1", "total/1"}, explanation.Functions)
+	assert.Equal(t, []string{"total/1"}, explanation.Defined)
+	assert.Equal(t, []string{"$ENV", "$min", "$x"}, explanation.Variables)
+	assert.True(t, explanation.UsesInputs)
+	assert.Empty(t, explanation.Warnings)
+
+	explanation, err = ExplainFilter(".name", Options{})
+	require.NoError(t, err)
+	assert.True(t, explanation.Valid)
+	assert.Equal(t, []string{}, explanation.Functions)
+	assert.False(t, explanation.UsesInputs)
+
+	// Undefined names are found when compiling, without a position
+	explanation, err = ExplainFilter(".items | totl(.price) | $missing", Options{})
+	require.NoError(t, err)
+	assert.False(t, explanation.Valid)
+	assert.Equal(t, &FilterError{Message: "function not defined: totl/1"}, explanation.Error)
+	assert.Equal(t, []string{"$missing"}, explanation.Variables)
+
+	_, err = ExplainFilter(".", Options{Variables: map[string]interface{}{"bad-name": 1}})
+	assert.ErrorContains(t, err, "invalid variable name")
+}
+
+func TestExplainFilter_Warnings(t *testing.T) {
+	tests := []struct {
+		name     string
+		filter   string
+		warnings []string
+	}{
+		{"recursive descent", `[.. | numbers] | length, [recurse | strings]`, []string{
+			"'..' visits every value in the input, which is slow on large files; prefer a path such as .items[].name",
+			"'recurse' visits every value in the input, which is slow on large files; prefer a path such as .items[].name",
+		}},
+		{"recurse with a step", `recurse(.children[])`, []string{
+			"'recurse(f)' runs until f produces no output; make sure it stops, or use recurse(f; cond) or limit(n; ...)",
+		}},
+		{"recurse with a condition", `recurse(. * 2; . < 100)`, nil},
+		{"repeat", `[repeat(. * 2)]`, []string{"'repeat' never stops on its own; wrap it in limit(n; ...) or first(...)"}},
+		{"bounded repeat", `[limit(5; repeat(. * 2))], first(range(0; infinite))`, nil},
+		{"huge range", `range(1e9), range(-5; infinite), range(0; 10000000; 5)`, []string{
+			"'range(1e9)' produces 1000000000 values; use smaller bounds or limit(n; ...)",
+			"'range(-5; infinite)' produces infinitely many values; use smaller bounds or limit(n; ...)",
+			"'range(0; 10000000; 5)' produces 2000000 values; use smaller bounds or limit(n; ...)",
+		}},
+		{"small or computed range", `range(1000), range(0; length), range(10; 0)`, nil},
+		{"warned once", `.. , ..`, []string{
+			"'..' visits every value in the input, which is slow on large files; prefer a path such as .items[].name",
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			explanation, err := ExplainFilter(tt.filter, Options{})
+			require.NoError(t, err)
+			assert.True(t, explanation.Valid, explanation.Error)
+			if tt.warnings == nil {
+				assert.Empty(t, explanation.Warnings)
+			} else {
+				assert.Equal(t, tt.warnings, explanation.Warnings)
+			}
+		})
+	}
+}
+
+func TestProcessJQQuery_SyntaxErrorPosition(t *testing.T) {
+	tempDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "data.json"), []byte(`{}`), 0644))
+
+	_, err := ProcessJQQueryWithOptions(context.Background(), ".a | .b]", []string{"data.json"}, tempDir, Options{})
+	require.Error(t, err)
+	assert.Equal(t, "invalid jq filter: unexpected token \"]\" at line 1, column 8:\n  .a | .b]\n         ^", err.Error())
+}
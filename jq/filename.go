@@ -0,0 +1,70 @@
+package jq
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// fileVariable is bound to the size and modification time of each file a query reads,
+// keyed by the name input_filename returns for it
+const fileVariable = "$__file"
+
+// fileIter is implemented by input iterators that know which file their last value came from
+type fileIter interface {
+	currentFile() string
+}
+
+// queryFiles describes the files a query reads, for input_filename and $__file
+type queryFiles struct {
+	// base is the directory names are relative to; names are paths as given when it is empty
+	base string
+	// input is the file whose contents are the query's input when it has a single file
+	input    string
+	metadata map[string]interface{}
+}
+
+// newQueryFiles reads the metadata of filePaths, naming them relative to base
+func newQueryFiles(filePaths []string, base string) (*queryFiles, error) {
+	if base != "" {
+		absBase, err := filepath.Abs(base)
+		if err != nil {
+			return nil, fmt.Errorf("error resolving path %s: %w", base, err)
+		}
+		base = absBase
+	}
+
+	files := &queryFiles{base: base, metadata: make(map[string]interface{}, len(filePaths))}
+	if len(filePaths) == 1 {
+		files.input = filePaths[0]
+	}
+	for _, filePath := range filePaths {
+		info, err := statFile(filePath)
+		if err != nil {
+			return nil, fmt.Errorf("error accessing file %s: %w", filePath, err)
+		}
+		files.metadata[files.name(filePath)] = map[string]interface{}{
+			"size":     int(info.Size()),
+			"modified": info.ModTime().UTC().Format(time.RFC3339),
+		}
+	}
+	return files, nil
+}
+
+// name returns filePath relative to the base directory with forward slashes, or as given
+// when there is no base or the file is outside it
+func (f *queryFiles) name(filePath string) string {
+	if f.base == "" {
+		return filePath
+	}
+	absPath, err := filepath.Abs(filePath)
+	if err != nil {
+		return filePath
+	}
+	rel, err := filepath.Rel(f.base, absPath)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return filePath
+	}
+	return filepath.ToSlash(rel)
+}
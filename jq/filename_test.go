@@ -0,0 +1,114 @@
+package jq
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProcessJQQuery_InputFilename(t *testing.T) {
+	tempDir := t.TempDir()
+	files := map[string]string{
+		"conversions/2025-01.json":  `{"count": 1}`,
+		"conversions/2025-02.json":  `{"count": 2}`,
+		"conversions/events.jsonl":  "{\"count\": 3}\n{\"count\": 4}\n",
+		"conversions/settings.yaml": "count: 5\n---\ncount: 6\n",
+	}
+	for name, content := range files {
+		fullPath := filepath.Join(tempDir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(fullPath), 0755))
+		require.NoError(t, os.WriteFile(fullPath, []byte(content), 0644))
+	}
+	modified := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	require.NoError(t, os.Chtimes(filepath.Join(tempDir, "conversions/2025-01.json"), modified, modified))
+
+	tests := []struct {
+		name     string
+		filter   string
+		patterns []string
+		opts     Options
+		expected string
+	}{
+		{
+			name:     "inputs name their files",
+			filter:   "[inputs | input_filename]",
+			patterns: []string{"conversions/*"},
+			opts:     Options{OutputFormat: FormatCompact},
+			expected: `["conversions/2025-01.json","conversions/2025-02.json","conversions/events.jsonl","conversions/events.jsonl","conversions/settings.yaml","conversions/settings.yaml"]`,
+		},
+		{
+			name:     "grouping by file",
+			filter:   "reduce inputs as $row ({}; .[input_filename] += $row.count)",
+			patterns: []string{"conversions/2025-*.json", "conversions/events.jsonl"},
+			opts:     Options{OutputFormat: FormatCompact, Workers: 4},
+			expected: `{"conversions/2025-01.json":1,"conversions/2025-02.json":2,"conversions/events.jsonl":7}`,
+		},
+		{
+			name:     "single file",
+			filter:   "input_filename",
+			patterns: []string{"conversions/2025-01.json"},
+			expected: `"conversions/2025-01.json"`,
+		},
+		{
+			name:     "document stream in a single file",
+			filter:   "[inputs | input_filename] | unique",
+			patterns: []string{"conversions/settings.yaml"},
+			opts:     Options{OutputFormat: FormatCompact},
+			expected: `["conversions/settings.yaml"]`,
+		},
+		{
+			name:     "stream mode",
+			filter:   "[inputs | input_filename] | unique",
+			patterns: []string{"conversions/2025-*.json"},
+			opts:     Options{OutputFormat: FormatCompact, Stream: true},
+			expected: `["conversions/2025-01.json","conversions/2025-02.json"]`,
+		},
+		{
+			name:     "before the first input",
+			filter:   "input_filename",
+			patterns: []string{"conversions/2025-*.json"},
+			expected: "null",
+		},
+		{
+			name:     "file metadata",
+			filter:   `$__file["conversions/2025-01.json"]`,
+			patterns: []string{"conversions/*.json"},
+			opts:     Options{OutputFormat: FormatCompact},
+			expected: `{"modified":"2025-03-01T12:00:00Z","size":12}`,
+		},
+		{
+			name:     "metadata of the current input",
+			filter:   "[inputs | $__file[input_filename].size]",
+			patterns: []string{"conversions/2025-*.json"},
+			opts:     Options{OutputFormat: FormatCompact},
+			expected: "[12,12]",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := ProcessJQQueryWithOptions(context.Background(), tt.filter, tt.patterns, tempDir, tt.opts)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, result.Output)
+		})
+	}
+
+	// Without a base directory files are named as given, like jq
+	filePath := filepath.Join(tempDir, "conversions", "2025-02.json")
+	result, err := ExecuteJQFiles("input_filename", []string{filePath})
+	require.NoError(t, err)
+	assert.Equal(t, `"`+filePath+`"`, result)
+
+	// Values that do not come from files have no name
+	result, err = ExecuteJQ("input_filename", map[string]interface{}{})
+	require.NoError(t, err)
+	assert.Equal(t, "null", result)
+
+	_, err = ExecuteJQFilesWithOptions(context.Background(), ".", []string{filePath}, Options{Variables: map[string]interface{}{"__file": 1}})
+	assert.EqualError(t, err, `variable name "__file" is reserved`)
+}
@@ -0,0 +1,374 @@
+package jq
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// OutputFormat selects how query results are rendered
+type OutputFormat string
+
+const (
+	// FormatJSON renders results as indented JSON
+	FormatJSON OutputFormat = "json"
+	// FormatCompact renders results as single-line JSON
+	FormatCompact OutputFormat = "compact"
+	// FormatRaw renders one result per line, with strings unquoted like jq -r
+	FormatRaw OutputFormat = "raw"
+	// FormatJSONL renders one single-line JSON result per line
+	FormatJSONL OutputFormat = "jsonl"
+	// FormatCSV renders results as comma-separated rows
+	FormatCSV OutputFormat = "csv"
+	// FormatTSV renders results as tab-separated rows
+	FormatTSV OutputFormat = "tsv"
+	// FormatMarkdownTable renders results as a Markdown table
+	FormatMarkdownTable OutputFormat = "markdown_table"
+)
+
+// OutputFormats lists every supported output format
+var OutputFormats = []OutputFormat{FormatJSON, FormatCompact, FormatRaw, FormatJSONL, FormatCSV, FormatTSV, FormatMarkdownTable}
+
+// ParseOutputFormat validates an output format name; an empty name selects FormatJSON
+func ParseOutputFormat(name string) (OutputFormat, error) {
+	if name == "" {
+		return FormatJSON, nil
+	}
+
+	names := make([]string, len(OutputFormats))
+	for i, format := range OutputFormats {
+		if string(format) == name {
+			return format, nil
+		}
+		names[i] = string(format)
+	}
+	return "", fmt.Errorf("unsupported output format %q; use one of %s", name, strings.Join(names, ", "))
+}
+
+// formatResults renders the kept results in opts.OutputFormat, leaving out results that
+// would make the output longer than opts.MaxResultBytes
+func formatResults(result *Result, values []interface{}, opts Options) error {
+	switch opts.OutputFormat {
+	case "", FormatJSON, FormatCompact:
+		return formatJSON(result, values, opts)
+	case FormatRaw, FormatJSONL:
+		lines := make([]string, len(values))
+		for i, value := range values {
+			if s, ok := value.(string); ok && opts.OutputFormat == FormatRaw {
+				lines[i] = s
+				continue
+			}
+			line, err := json.Marshal(value)
+			if err != nil {
+				return fmt.Errorf("error formatting results: %w", err)
+			}
+			lines[i] = string(line)
+		}
+		writeRows(result, nil, lines, true, opts.MaxResultBytes)
+		return nil
+	case FormatCSV, FormatTSV, FormatMarkdownTable:
+		return formatTable(result, values, opts)
+	}
+	return fmt.Errorf("unsupported output format %q", opts.OutputFormat)
+}
+
+// formatJSON renders a lone result on its own unless paging or an array was requested;
+// otherwise results are rendered as an array
+func formatJSON(result *Result, values []interface{}, opts Options) error {
+	compact := opts.OutputFormat == FormatCompact
+	marshal := func(value interface{}, prefix string) ([]byte, error) {
+		if compact {
+			return json.Marshal(value)
+		}
+		return json.MarshalIndent(value, prefix, "  ")
+	}
+
+	if !opts.paged() && !opts.AlwaysArray && result.Total <= 1 && len(values) <= 1 {
+		var value interface{}
+		if len(values) == 1 {
+			value = values[0]
+			result.Returned = 1
+		}
+		output, err := marshal(value, "")
+		if err != nil {
+			return fmt.Errorf("error formatting results: %w", err)
+		}
+		result.Output = string(output)
+		cutOutput(result, opts.MaxResultBytes)
+		return nil
+	}
+
+	if len(values) == 0 {
+		result.Output = "[]"
+		return nil
+	}
+
+	lead, end := "\n  ", "\n]"
+	if compact {
+		lead, end = "", "]"
+	}
+
+	var b strings.Builder
+	b.WriteString("[")
+	for i, value := range values {
+		item, err := marshal(value, "  ")
+		if err != nil {
+			return fmt.Errorf("error formatting results: %w", err)
+		}
+
+		// Always keep the first result so there is something to show; it is cut below if needed
+		if i > 0 && opts.MaxResultBytes > 0 && b.Len()+1+len(lead)+len(item)+len(end) > opts.MaxResultBytes {
+			result.Truncated = true
+			break
+		}
+		if i > 0 {
+			b.WriteString(",")
+		}
+		b.WriteString(lead)
+		b.Write(item)
+		result.Returned++
+	}
+	b.WriteString(end)
+
+	result.Output = b.String()
+	if result.Total < 0 || result.Offset+result.Returned < result.Total {
+		result.Truncated = true
+	}
+	cutOutput(result, opts.MaxResultBytes)
+	return nil
+}
+
+// formatTable renders results as rows of a CSV, TSV or Markdown table. A lone result
+// that is an array of objects or arrays, such as '.users', is rendered one element per row.
+func formatTable(result *Result, values []interface{}, opts Options) error {
+	rows := values
+	rowsAreResults := true
+	if len(values) == 1 && result.Total == 1 && !opts.paged() {
+		if elements, ok := values[0].([]interface{}); ok && len(elements) > 0 && allContainers(elements) {
+			rows = elements
+			rowsAreResults = false
+		}
+	}
+
+	columns, cells, err := tabulate(rows)
+	if err != nil {
+		return err
+	}
+	if len(cells) == 0 {
+		writeRows(result, nil, nil, true, opts.MaxResultBytes)
+		return nil
+	}
+
+	var header []string
+	lines := make([]string, len(cells))
+	switch opts.OutputFormat {
+	case FormatCSV:
+		if columns != nil {
+			header = []string{csvLine(columns)}
+		}
+		for i, row := range cells {
+			lines[i] = csvLine(row)
+		}
+	case FormatTSV:
+		if columns != nil {
+			header = []string{tsvLine(columns)}
+		}
+		for i, row := range cells {
+			lines[i] = tsvLine(row)
+		}
+	case FormatMarkdownTable:
+		if columns == nil {
+			columns = numberedColumns(cells)
+		}
+		separator := make([]string, len(columns))
+		for i := range separator {
+			separator[i] = "---"
+		}
+		header = []string{markdownLine(columns, len(columns)), markdownLine(separator, len(columns))}
+		for i, row := range cells {
+			lines[i] = markdownLine(row, len(columns))
+		}
+	}
+
+	writeRows(result, header, lines, rowsAreResults, opts.MaxResultBytes)
+	return nil
+}
+
+// writeRows joins header and rows into the output, leaving out rows that would make it
+// longer than maxBytes. When each row is a result the left-out rows are reported as
+// truncated results; otherwise the lone result they came from is reported as cut.
+func writeRows(result *Result, header []string, rows []string, rowsAreResults bool, maxBytes int) {
+	var b strings.Builder
+	for _, line := range header {
+		b.WriteString(line)
+		b.WriteString("\n")
+	}
+
+	written := 0
+	for _, row := range rows {
+		// Always keep the first row so there is something to show; it is cut below if needed
+		if written > 0 && maxBytes > 0 && b.Len()+len(row) > maxBytes {
+			break
+		}
+		b.WriteString(row)
+		b.WriteString("\n")
+		written++
+	}
+	result.Output = strings.TrimSuffix(b.String(), "\n")
+
+	if rowsAreResults {
+		result.Returned = written
+		if result.Total < 0 || written < len(rows) || result.Offset+written < result.Total {
+			result.Truncated = true
+		}
+	} else {
+		result.Returned = 1
+		if written < len(rows) {
+			result.Cut = true
+			result.Size = len(strings.Join(append(append([]string{}, header...), rows...), "\n"))
+		}
+	}
+	cutOutput(result, maxBytes)
+}
+
+// allContainers reports whether every value is an object or an array
+func allContainers(values []interface{}) bool {
+	for _, value := range values {
+		switch value.(type) {
+		case map[string]interface{}, []interface{}:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// tabulate turns rows into cells. When every row is an object the columns are the keys of
+// all rows; otherwise arrays are spread over cells and other values fill a single cell.
+func tabulate(rows []interface{}) ([]string, [][]string, error) {
+	objects := len(rows) > 0
+	for _, row := range rows {
+		if _, ok := row.(map[string]interface{}); !ok {
+			objects = false
+			break
+		}
+	}
+
+	cells := make([][]string, len(rows))
+
+	if objects {
+		var columns []string
+		seen := make(map[string]bool)
+		for _, row := range rows {
+			var keys []string
+			for key := range row.(map[string]interface{}) {
+				if !seen[key] {
+					keys = append(keys, key)
+					seen[key] = true
+				}
+			}
+			sort.Strings(keys)
+			columns = append(columns, keys...)
+		}
+
+		for i, row := range rows {
+			object := row.(map[string]interface{})
+			cells[i] = make([]string, len(columns))
+			for j, column := range columns {
+				cell, err := formatCell(object[column])
+				if err != nil {
+					return nil, nil, err
+				}
+				cells[i][j] = cell
+			}
+		}
+		return columns, cells, nil
+	}
+
+	for i, row := range rows {
+		elements, ok := row.([]interface{})
+		if !ok {
+			elements = []interface{}{row}
+		}
+		cells[i] = make([]string, len(elements))
+		for j, element := range elements {
+			cell, err := formatCell(element)
+			if err != nil {
+				return nil, nil, err
+			}
+			cells[i][j] = cell
+		}
+	}
+	return nil, cells, nil
+}
+
+// formatCell renders a value as table cell text: strings as is, null as empty and
+// everything else as compact JSON
+func formatCell(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	}
+	cell, err := json.Marshal(value)
+	if err != nil {
+		return "", fmt.Errorf("error formatting results: %w", err)
+	}
+	return string(cell), nil
+}
+
+// numberedColumns names the columns of header-less rows 1, 2, 3, ...
+func numberedColumns(cells [][]string) []string {
+	width := 0
+	for _, row := range cells {
+		if len(row) > width {
+			width = len(row)
+		}
+	}
+	columns := make([]string, width)
+	for i := range columns {
+		columns[i] = fmt.Sprint(i + 1)
+	}
+	return columns
+}
+
+func csvLine(cells []string) string {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write(cells)
+	w.Flush()
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// tsvLine joins cells with tabs, escaping them the way jq's @tsv does
+func tsvLine(cells []string) string {
+	escaped := make([]string, len(cells))
+	for i, cell := range cells {
+		escaped[i] = tsvEscaper.Replace(cell)
+	}
+	return strings.Join(escaped, "\t")
+}
+
+var tsvEscaper = strings.NewReplacer("\\", "\\\\", "\t", "\\t", "\n", "\\n", "\r", "\\r")
+
+// markdownLine renders cells as a table row padded to width columns
+func markdownLine(cells []string, width int) string {
+	var b strings.Builder
+	b.WriteString("|")
+	for i := 0; i < width; i++ {
+		cell := ""
+		if i < len(cells) {
+			cell = markdownEscaper.Replace(cells[i])
+		}
+		b.WriteString(" ")
+		b.WriteString(cell)
+		b.WriteString(" |")
+	}
+	return b.String()
+}
+
+var markdownEscaper = strings.NewReplacer("|", "\\|", "\r\n", "<br>", "\n", "<br>", "\r", "")
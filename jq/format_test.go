@@ -0,0 +1,153 @@
+package jq
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatResults(t *testing.T) {
+	input := map[string]interface{}{
+		"users": []interface{}{
+			map[string]interface{}{"name": "Alice", "age": 30, "note": "says \"hi\", | waves"},
+			map[string]interface{}{"name": "Bob", "age": 35, "tags": []interface{}{"a", "b"}},
+		},
+		"lines": []interface{}{"one\ttwo", "three\nfour"},
+	}
+
+	tests := []struct {
+		name     string
+		filter   string
+		opts     Options
+		expected string
+	}{
+		{
+			name:     "json single result",
+			filter:   ".users[0].name",
+			opts:     Options{OutputFormat: FormatJSON},
+			expected: `"Alice"`,
+		},
+		{
+			name:     "json always array",
+			filter:   ".users[0].name",
+			opts:     Options{OutputFormat: FormatJSON, AlwaysArray: true},
+			expected: "[\n  \"Alice\"\n]",
+		},
+		{
+			name:     "json always array without results",
+			filter:   "empty",
+			opts:     Options{AlwaysArray: true},
+			expected: "[]",
+		},
+		{
+			name:     "compact",
+			filter:   ".users[] | {name, age}",
+			opts:     Options{OutputFormat: FormatCompact},
+			expected: `[{"age":30,"name":"Alice"},{"age":35,"name":"Bob"}]`,
+		},
+		{
+			name:     "compact single result",
+			filter:   ".users[1].tags",
+			opts:     Options{OutputFormat: FormatCompact},
+			expected: `["a","b"]`,
+		},
+		{
+			name:     "raw",
+			filter:   ".users[] | .name, .age",
+			opts:     Options{OutputFormat: FormatRaw},
+			expected: "Alice\n30\nBob\n35",
+		},
+		{
+			name:     "jsonl",
+			filter:   ".users[] | {name}",
+			opts:     Options{OutputFormat: FormatJSONL},
+			expected: "{\"name\":\"Alice\"}\n{\"name\":\"Bob\"}",
+		},
+		{
+			name:     "csv from objects",
+			filter:   ".users[]",
+			opts:     Options{OutputFormat: FormatCSV},
+			expected: "age,name,note,tags\n30,Alice,\"says \"\"hi\"\", | waves\",\n35,Bob,,\"[\"\"a\"\",\"\"b\"\"]\"",
+		},
+		{
+			name:     "csv from a single array of objects",
+			filter:   "[.users[] | {name}]",
+			opts:     Options{OutputFormat: FormatCSV},
+			expected: "name\nAlice\nBob",
+		},
+		{
+			name:     "csv from arrays",
+			filter:   ".users[] | [.name, .age]",
+			opts:     Options{OutputFormat: FormatCSV},
+			expected: "Alice,30\nBob,35",
+		},
+		{
+			name:     "tsv escapes tabs and newlines",
+			filter:   ".lines",
+			opts:     Options{OutputFormat: FormatTSV},
+			expected: "one\\ttwo\tthree\\nfour",
+		},
+		{
+			name:     "markdown table",
+			filter:   ".users[] | {name, note}",
+			opts:     Options{OutputFormat: FormatMarkdownTable},
+			expected: "| name | note |\n| --- | --- |\n| Alice | says \"hi\", \\| waves |\n| Bob |  |",
+		},
+		{
+			name:     "markdown table from arrays",
+			filter:   ".users[] | [.name, .age], [.name]",
+			opts:     Options{OutputFormat: FormatMarkdownTable},
+			expected: "| 1 | 2 |\n| --- | --- |\n| Alice | 30 |\n| Alice |  |\n| Bob | 35 |\n| Bob |  |",
+		},
+		{
+			name:     "table without results",
+			filter:   "empty",
+			opts:     Options{OutputFormat: FormatMarkdownTable},
+			expected: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := execute(context.Background(), tt.filter, input, nil, nil, tt.opts)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, result.Output)
+		})
+	}
+}
+
+func TestFormatResults_Limits(t *testing.T) {
+	input := []interface{}{
+		map[string]interface{}{"id": 1}, map[string]interface{}{"id": 2}, map[string]interface{}{"id": 3},
+	}
+
+	// Rows that are separate results are paged
+	result, err := execute(context.Background(), ".[]", input, nil, nil, Options{OutputFormat: FormatCSV, MaxResultBytes: 6})
+	require.NoError(t, err)
+	assert.Equal(t, "id\n1\n2", result.Output)
+	assert.True(t, result.Truncated)
+	assert.Equal(t, 2, result.Returned)
+
+	// Rows of a single result are cut
+	result, err = execute(context.Background(), ".", input, nil, nil, Options{OutputFormat: FormatCSV, MaxResultBytes: 6})
+	require.NoError(t, err)
+	assert.Equal(t, "id\n1\n2", result.Output)
+	assert.True(t, result.Cut)
+	assert.Equal(t, 8, result.Size)
+}
+
+func TestParseOutputFormat(t *testing.T) {
+	format, err := ParseOutputFormat("")
+	require.NoError(t, err)
+	assert.Equal(t, FormatJSON, format)
+
+	format, err = ParseOutputFormat("markdown_table")
+	require.NoError(t, err)
+	assert.Equal(t, FormatMarkdownTable, format)
+
+	_, err = ParseOutputFormat("xml")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "use one of json, compact, raw")
+}
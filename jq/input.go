@@ -0,0 +1,250 @@
+package jq
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+)
+
+// IsJSONLinesFile reports whether path names a JSON Lines / NDJSON file, compressed or not
+func IsJSONLinesFile(path string) bool {
+	ext := dataExt(path)
+	return ext == ".jsonl" || ext == ".ndjson"
+}
+
+// IsSupportedFile reports whether path has an extension the jq package has a format for
+func IsSupportedFile(path string) bool {
+	_, ok := LookupFormat(path)
+	return ok
+}
+
+// CountJSONLines returns the number of records in a JSON Lines file without decoding them
+func CountJSONLines(filePath string) (int, error) {
+	file, err := OpenFile(filePath)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	count := 0
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadSlice('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			count++
+		}
+		// Long lines are returned in chunks; only count the first chunk of each
+		for err == bufio.ErrBufferFull {
+			_, err = reader.ReadSlice('\n')
+		}
+		if err == io.EOF {
+			return count, nil
+		}
+		if err != nil {
+			return count, err
+		}
+	}
+}
+
+// jsonLinesIter yields the records of a JSON Lines file one line at a time
+type jsonLinesIter struct {
+	path   string
+	file   io.ReadCloser
+	reader *bufio.Reader
+	line   int
+	guard  *memoryGuard
+}
+
+// openJSONLines opens a JSON Lines file for iteration. Records are charged to guard as they
+// are read; a nil guard places no limit on them.
+func openJSONLines(filePath string, guard *memoryGuard) (*jsonLinesIter, error) {
+	file, err := OpenFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("file %s is not readable: %w", filePath, err)
+	}
+	return &jsonLinesIter{path: filePath, file: file, reader: bufio.NewReader(file), guard: guard}, nil
+}
+
+// Next returns the next record, or an error value if a line is not valid JSON
+func (it *jsonLinesIter) Next() (interface{}, bool) {
+	for it.file != nil {
+		line, err := it.reader.ReadBytes('\n')
+		if len(line) > 0 {
+			it.line++
+			if trimmed := bytes.TrimSpace(line); len(trimmed) > 0 {
+				if err := it.guard.charge(it.path, int64(len(trimmed))); err != nil {
+					it.Close()
+					return err, true
+				}
+				var record interface{}
+				if err := json.Unmarshal(trimmed, &record); err != nil {
+					it.Close()
+					return fmt.Errorf("file %s line %d does not contain valid JSON: %w", it.path, it.line, err), true
+				}
+				return record, true
+			}
+		}
+		if err == io.EOF {
+			it.Close()
+			return nil, false
+		}
+		if err != nil {
+			it.Close()
+			return fmt.Errorf("file %s is not readable: %w", it.path, err), true
+		}
+	}
+	return nil, false
+}
+
+// Close releases the underlying file
+func (it *jsonLinesIter) Close() error {
+	if it.file == nil {
+		return nil
+	}
+	err := it.file.Close()
+	it.file = nil
+	return err
+}
+
+// inputIter feeds the contents of files to gojq one value at a time. Files yield each of
+// their documents; JSON Lines files yield one value per record, read as the query consumes them.
+type inputIter struct {
+	paths    []string
+	next     int
+	lines    *jsonLinesIter
+	pending  []interface{}
+	current  string
+	guard    *memoryGuard
+	retain   bool // every value read is kept, so JSON Lines records count against guard too
+	cache    *DocumentCache
+	leases   *cacheLeases
+	workers  int
+	prefetch *prefetcher
+}
+
+// newInputIter creates an iterator over the values of filePaths, in order. A nil guard
+// places no limit on memory. Files are read through opts.Cache and, when opts.Workers is
+// above one, decoded ahead of the query that many at a time.
+func newInputIter(filePaths []string, guard *memoryGuard, opts Options) *inputIter {
+	return &inputIter{paths: filePaths, guard: guard, cache: opts.Cache, leases: opts.leases, workers: opts.Workers}
+}
+
+// decode reads the documents of a file that is not JSON Lines
+func (it *inputIter) decode(filePath string) ([]interface{}, error) {
+	if err := it.guard.checkFileSize(filePath); err != nil {
+		return nil, err
+	}
+	return it.cache.read(filePath, it.guard, it.leases)
+}
+
+// Next returns the next input value, or an error value if a file cannot be decoded
+func (it *inputIter) Next() (interface{}, bool) {
+	for {
+		if len(it.pending) > 0 {
+			v := it.pending[0]
+			it.pending = it.pending[1:]
+			return v, true
+		}
+		if it.lines != nil {
+			if v, ok := it.lines.Next(); ok {
+				return v, true
+			}
+			it.lines = nil
+		}
+
+		// Through inputs the query is done with a file once it asks for what follows
+		if !it.retain && it.current != "" {
+			it.guard.release(it.current)
+		}
+		if it.next >= len(it.paths) {
+			return nil, false
+		}
+		// Decoding starts with the first read, so filters that fail to compile decode nothing
+		if it.prefetch == nil && it.workers > 1 && len(it.paths) > 1 {
+			it.prefetch = newPrefetcher(it.paths, it.workers, it.decode)
+		}
+		filePath := it.paths[it.next]
+		it.next++
+
+		if IsJSONLinesFile(filePath) {
+			var guard *memoryGuard
+			if it.retain {
+				guard = it.guard
+			}
+			lines, err := openJSONLines(filePath, guard)
+			if err != nil {
+				return err, true
+			}
+			it.lines = lines
+			it.current = filePath
+			continue
+		}
+
+		var values []interface{}
+		var err error
+		if it.prefetch != nil {
+			values, err = it.prefetch.take(it.next - 1)
+			if err != nil {
+				// Files decoded ahead can crowd the memory limit, so a file that failed is
+				// read again on its own with the rest read one at a time
+				it.stopPrefetch()
+				values, err = it.decode(filePath)
+			}
+		} else {
+			values, err = it.decode(filePath)
+		}
+		if err != nil {
+			return err, true
+		}
+		it.pending = values
+		it.current = filePath
+	}
+}
+
+// stopPrefetch stops decoding files ahead and drops what was decoded from the next file on
+func (it *inputIter) stopPrefetch() {
+	it.prefetch.stop()
+	it.prefetch = nil
+	it.workers = 1
+	for _, filePath := range it.paths[it.next-1:] {
+		it.guard.release(filePath)
+	}
+}
+
+// currentFile returns the path of the file the last value came from
+func (it *inputIter) currentFile() string {
+	return it.current
+}
+
+// Close releases any file still open and stops decoding files ahead
+func (it *inputIter) Close() error {
+	if it.prefetch != nil {
+		it.prefetch.close()
+	}
+	if it.lines != nil {
+		return it.lines.Close()
+	}
+	return nil
+}
+
+// checkFiles verifies that every path exists and is a regular file
+func checkFiles(filePaths []string) error {
+	for _, filePath := range filePaths {
+		fileInfo, err := statFile(filePath)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return fmt.Errorf("file does not exist: %s", filePath)
+			}
+			return fmt.Errorf("error accessing file %s: %w", filePath, err)
+		}
+
+		if fileInfo.IsDir() {
+			return fmt.Errorf("path is a directory, not a file: %s", filePath)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package jq
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateAndReadJSONFiles_JSONLines(t *testing.T) {
+	tempDir := t.TempDir()
+
+	eventsPath := filepath.Join(tempDir, "events.jsonl")
+	err := os.WriteFile(eventsPath, []byte("{\"id\": 1}\n\n{\"id\": 2}\r\n{\"id\": 3}"), 0644)
+	require.NoError(t, err)
+
+	invalidPath := filepath.Join(tempDir, "invalid.ndjson")
+	err = os.WriteFile(invalidPath, []byte("{\"id\": 1}\n\n{\"id\": \n"), 0644)
+	require.NoError(t, err)
+
+	data, err := ValidateAndReadJSONFiles([]string{eventsPath})
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"id": float64(1)},
+		map[string]interface{}{"id": float64(2)},
+		map[string]interface{}{"id": float64(3)},
+	}, data)
+
+	_, err = ValidateAndReadJSONFiles([]string{invalidPath})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "line 3 does not contain valid JSON")
+}
+
+func TestExecuteJQFiles(t *testing.T) {
+	tempDir := t.TempDir()
+
+	files := map[string]string{
+		"single.json":  `{"name": "single"}`,
+		"events.jsonl": "{\"level\": \"info\"}\n{\"level\": \"error\"}\n{\"level\": \"error\"}\n",
+		"more.ndjson":  "{\"level\": \"warn\"}\n",
+		"broken.jsonl": "{\"level\": \"info\"}\nnot json\n",
+	}
+	for name, content := range files {
+		err := os.WriteFile(filepath.Join(tempDir, name), []byte(content), 0644)
+		require.NoError(t, err)
+	}
+	path := func(name string) string { return filepath.Join(tempDir, name) }
+
+	tests := []struct {
+		name      string
+		filter    string
+		paths     []string
+		expected  string
+		expectErr string
+	}{
+		{
+			name:     "single JSON file is the input",
+			filter:   ".name",
+			paths:    []string{path("single.json")},
+			expected: `"single"`,
+		},
+		{
+			name:     "JSON Lines records are inputs",
+			filter:   `[inputs | select(.level == "error")] | length`,
+			paths:    []string{path("events.jsonl")},
+			expected: "2",
+		},
+		{
+			name:     "records follow documents in file order",
+			filter:   "[inputs | .level // .name]",
+			paths:    []string{path("single.json"), path("events.jsonl"), path("more.ndjson")},
+			expected: "[\n  \"single\",\n  \"info\",\n  \"error\",\n  \"error\",\n  \"warn\"\n]",
+		},
+		{
+			name:     "records are read on demand",
+			filter:   "first(inputs) | .level",
+			paths:    []string{path("broken.jsonl")},
+			expected: `"info"`,
+		},
+		{
+			name:      "invalid record",
+			filter:    "[inputs]",
+			paths:     []string{path("broken.jsonl")},
+			expectErr: "line 2 does not contain valid JSON",
+		},
+		{
+			name:      "missing file",
+			filter:    "[inputs]",
+			paths:     []string{path("events.jsonl"), path("missing.jsonl")},
+			expectErr: "file does not exist",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := ExecuteJQFiles(tt.filter, tt.paths)
+			if tt.expectErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.expectErr)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestCountJSONLines(t *testing.T) {
+	tempDir := t.TempDir()
+
+	filePath := filepath.Join(tempDir, "events.jsonl")
+	longLine := `{"payload": "` + strings.Repeat("x", 10000) + `"}`
+	err := os.WriteFile(filePath, []byte("{\"id\": 1}\n  \n"+longLine+"\n{\"id\": 3}"), 0644)
+	require.NoError(t, err)
+
+	count, err := CountJSONLines(filePath)
+	require.NoError(t, err)
+	assert.Equal(t, 3, count)
+
+	_, err = CountJSONLines(filepath.Join(tempDir, "missing.jsonl"))
+	assert.Error(t, err)
+}
+
+func TestIsSupportedFile(t *testing.T) {
+	assert.True(t, IsSupportedFile("data/file.json"))
+	assert.True(t, IsSupportedFile("data/events.JSONL"))
+	assert.True(t, IsSupportedFile("data/events.ndjson"))
+	assert.True(t, IsSupportedFile("config/app.yml"))
+	assert.True(t, IsSupportedFile("config/settings.toml"))
+	assert.True(t, IsSupportedFile("exports/users.CSV"))
+	assert.False(t, IsSupportedFile("data/notes.txt"))
+	assert.True(t, IsJSONLinesFile("events.ndjson"))
+	assert.False(t, IsJSONLinesFile("file.json"))
+}
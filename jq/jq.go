@@ -1,32 +1,109 @@
 package jq
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
-	"os"
 	"path/filepath"
 	"sort"
 	"strings"
+	"time"
 
+	"github.com/berrydev-ai/gojq-mcp/sandbox"
+	"github.com/bmatcuk/doublestar/v4"
 	"github.com/itchyny/gojq"
 )
 
-// ExpandGlobPatterns expands glob patterns into file paths
+// Options controls how files are decoded when running a query
+type Options struct {
+	// Stream feeds files to the query as jq --stream events instead of whole documents
+	Stream bool
+	// Mode selects how documents are given to the filter; empty makes a single file '.' and
+	// reads several files through 'inputs'. Stream mode reads through 'inputs' only.
+	Mode Mode
+	// MemoryLimit is the number of bytes of file data a query may hold decoded; zero
+	// disables the limit. Through inputs only the files being read count, one after
+	// another; values read one at a time and values the filter builds are not counted.
+	MemoryLimit int64
+	// Timeout bounds how long a query may run; zero disables the timeout
+	Timeout time.Duration
+	// Offset is the number of results to skip
+	Offset int
+	// Limit is the number of results to return after Offset; zero returns them all
+	Limit int
+	// MaxResultItems caps the number of results returned regardless of Limit; zero disables the cap
+	MaxResultItems int
+	// MaxResultBytes caps the size of the formatted output; zero disables the cap
+	MaxResultBytes int
+	// OutputFormat selects how results are rendered; empty selects FormatJSON
+	OutputFormat OutputFormat
+	// AlwaysArray renders JSON results as an array even when there is only one
+	AlwaysArray bool
+	// Symlinks controls whether ProcessJQQueryWithOptions reads files through symbolic links
+	Symlinks sandbox.SymlinkPolicy
+	// Variables are bound as $name in the filter, like jq --arg and --argjson. $ENV stays
+	// empty; gojq does not expose the process environment.
+	Variables map[string]interface{}
+	// Workers is the number of files of a multi-file query decoded at the same time, ahead
+	// of the filter reading them; zero or one decodes each file when the filter reaches it
+	Workers int
+	// BaseDir is the directory input_filename and $__file name files relative to; files are
+	// named as given when it is empty. ProcessJQQueryWithOptions uses the data directory.
+	BaseDir string
+	// Cache serves the documents of files read before; nil reads every file from disk.
+	// JSON Lines files and stream mode always read from disk.
+	Cache *DocumentCache
+
+	// leases holds the cache entries lent to the query running with these options
+	leases *cacheLeases
+}
+
+// DefaultTimeout is the per-query timeout applied when none is configured
+const DefaultTimeout = 30 * time.Second
+
+const (
+	// DefaultMaxResultBytes is the output size at which server results are truncated
+	DefaultMaxResultBytes = 256 << 10
+	// DefaultMaxResultItems is the number of results at which server results are truncated
+	DefaultMaxResultItems = 1000
+)
+
+var (
+	// ErrQueryTimeout is returned when a query does not finish before its deadline
+	ErrQueryTimeout = errors.New("query timed out")
+	// ErrQueryCancelled is returned when a query is cancelled before it finishes
+	ErrQueryCancelled = errors.New("query cancelled")
+)
+
+// ExpandGlobPatterns expands glob patterns into file paths. Patterns may use ** to match
+// any number of directories and {a,b} alternatives. A pattern starting with ! removes the
+// files it matches from those matched by the other patterns.
 func ExpandGlobPatterns(patterns []string) ([]string, error) {
 	var expandedPaths []string
+	var excludes []string
 
 	for _, pattern := range patterns {
-		matches, err := filepath.Glob(pattern)
+		if exclude, ok := strings.CutPrefix(pattern, "!"); ok {
+			exclude = filepath.Clean(exclude)
+			if !doublestar.ValidatePathPattern(exclude) {
+				return nil, fmt.Errorf("error expanding glob pattern %q: %w", pattern, doublestar.ErrBadPattern)
+			}
+			excludes = append(excludes, exclude)
+			continue
+		}
+
+		matches, err := doublestar.FilepathGlob(pattern, doublestar.WithFilesOnly())
 		if err != nil {
 			return nil, fmt.Errorf("error expanding glob pattern %q: %w", pattern, err)
 		}
 		expandedPaths = append(expandedPaths, matches...)
+		expandedPaths = append(expandedPaths, archiveMatches(pattern)...)
 	}
 
 	seen := make(map[string]bool)
 	var uniquePaths []string
 	for _, path := range expandedPaths {
-		if !seen[path] {
+		if !seen[path] && !matchesAny(excludes, path) {
 			seen[path] = true
 			uniquePaths = append(uniquePaths, path)
 		}
@@ -36,48 +113,64 @@ func ExpandGlobPatterns(patterns []string) ([]string, error) {
 	return uniquePaths, nil
 }
 
-// ValidateAndReadJSONFiles validates and reads JSON files
-func ValidateAndReadJSONFiles(filePaths []string) ([]interface{}, error) {
-	var jsonData []interface{}
-
-	for _, filePath := range filePaths {
-		fileInfo, err := os.Stat(filePath)
-		if err != nil {
-			if os.IsNotExist(err) {
-				return nil, fmt.Errorf("file does not exist: %s", filePath)
-			}
-			return nil, fmt.Errorf("error accessing file %s: %w", filePath, err)
-		}
-
-		if fileInfo.IsDir() {
-			return nil, fmt.Errorf("path is a directory, not a file: %s", filePath)
+// archiveMatches returns the members of archives that pattern matches as virtual paths,
+// such as "data/bundle.zip/2025/01.json". Archives are found by globbing each leading part
+// of the pattern that ends in an archive name, such as "data/*.zip", so patterns that name
+// no archive, such as "**/*.json", do not look inside them. Archives that cannot be read
+// are skipped.
+func archiveMatches(pattern string) []string {
+	var matches []string
+	seen := make(map[string]bool)
+	for i := 1; i < len(pattern); i++ {
+		if pattern[i] != filepath.Separator || !IsArchive(pattern[:i]) {
+			continue
 		}
-
-		data, err := os.ReadFile(filePath)
+		// A prefix that cuts through a brace expansion is not a valid pattern
+		archives, err := doublestar.FilepathGlob(pattern[:i], doublestar.WithFilesOnly())
 		if err != nil {
-			return nil, fmt.Errorf("file %s is not readable: %w", filePath, err)
+			continue
 		}
+		for _, archive := range archives {
+			if !IsArchive(archive) || seen[archive] {
+				continue
+			}
+			seen[archive] = true
 
-		var parsedData interface{}
-		if err := json.Unmarshal(data, &parsedData); err != nil {
-			return nil, fmt.Errorf("file %s does not contain valid JSON: %w", filePath, err)
+			members, err := ArchiveMembers(archive)
+			if err != nil {
+				continue
+			}
+			for _, member := range members {
+				path := filepath.Join(archive, filepath.FromSlash(member.Name))
+				if doublestar.PathMatchUnvalidated(pattern, path) {
+					matches = append(matches, path)
+				}
+			}
 		}
-
-		jsonData = append(jsonData, parsedData)
 	}
+	return matches
+}
 
-	return jsonData, nil
+// matchesAny reports whether path matches one of the validated patterns
+func matchesAny(patterns []string, path string) bool {
+	for _, pattern := range patterns {
+		if doublestar.PathMatchUnvalidated(pattern, path) {
+			return true
+		}
+	}
+	return false
 }
 
-// ExecuteJQ executes a jq filter on a single JSON data object
-func ExecuteJQ(jqFilter string, jsonData interface{}) (string, error) {
-	query, err := gojq.Parse(jqFilter)
-	if err != nil {
-		return "", fmt.Errorf("invalid jq filter: %w", err)
+// ValidateAndReadJSONFiles validates and reads JSON files. Each record of a JSON Lines
+// file is returned as a separate value.
+func ValidateAndReadJSONFiles(filePaths []string) ([]interface{}, error) {
+	if err := checkFiles(filePaths); err != nil {
+		return nil, err
 	}
 
-	iter := query.Run(jsonData)
-	var results []interface{}
+	var jsonData []interface{}
+	iter := newInputIter(filePaths, nil, Options{Workers: DefaultWorkers})
+	defer iter.Close()
 
 	for {
 		v, ok := iter.Next()
@@ -85,119 +178,265 @@ func ExecuteJQ(jqFilter string, jsonData interface{}) (string, error) {
 			break
 		}
 		if err, ok := v.(error); ok {
-			if haltErr, ok := err.(*gojq.HaltError); ok && haltErr.Value() == nil {
-				break
-			}
-			return "", fmt.Errorf("jq execution error: %w", err)
+			return nil, err
 		}
-		results = append(results, v)
+		jsonData = append(jsonData, v)
 	}
 
-	var output []byte
-	if len(results) == 1 {
-		output, err = json.MarshalIndent(results[0], "", "  ")
-	} else {
-		output, err = json.MarshalIndent(results, "", "  ")
-	}
+	return jsonData, nil
+}
 
+// ExecuteJQ executes a jq filter on a single JSON data object
+func ExecuteJQ(jqFilter string, jsonData interface{}) (string, error) {
+	result, err := execute(context.Background(), jqFilter, jsonData, nil, nil, Options{})
 	if err != nil {
-		return "", fmt.Errorf("error formatting results: %w", err)
+		return "", err
 	}
-
-	return string(output), nil
+	return result.Output, nil
 }
 
 // ExecuteJQMultiFiles executes a jq filter on multiple JSON data objects
 func ExecuteJQMultiFiles(jqFilter string, jsonData []interface{}) (string, error) {
-	query, err := gojq.Parse(jqFilter)
+	result, err := execute(context.Background(), jqFilter, nil, gojq.NewIter(jsonData...), nil, Options{})
+	if err != nil {
+		return "", err
+	}
+	return result.Output, nil
+}
+
+// ExecuteJQFiles executes a jq filter on files. A single JSON file is the filter's input;
+// otherwise every document and JSON Lines record is read on demand through 'inputs'.
+func ExecuteJQFiles(jqFilter string, filePaths []string) (string, error) {
+	result, err := ExecuteJQFilesWithOptions(context.Background(), jqFilter, filePaths, Options{})
 	if err != nil {
-		return "", fmt.Errorf("invalid jq filter: %w", err)
+		return "", err
+	}
+	return result.Output, nil
+}
+
+// ExecuteJQFilesWithOptions executes a jq filter on files as ExecuteJQFiles does, unless
+// opts.Mode selects another way of giving it their documents. In stream mode the stream
+// events of every file are read through 'inputs' instead. The query stops when ctx is
+// cancelled or opts.Timeout elapses.
+func ExecuteJQFilesWithOptions(ctx context.Context, jqFilter string, filePaths []string, opts Options) (*Result, error) {
+	if err := checkFiles(filePaths); err != nil {
+		return nil, err
 	}
 
-	inputIter := gojq.NewIter(jsonData...)
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
 
-	code, err := gojq.Compile(query, gojq.WithInputIter(inputIter))
+	result, err := executeFiles(ctx, jqFilter, filePaths, opts)
+	if errors.Is(err, ErrQueryTimeout) && opts.Timeout > 0 {
+		return nil, fmt.Errorf("%w after %s; narrow the filter or the files it reads", ErrQueryTimeout, opts.Timeout)
+	}
+	return result, err
+}
+
+// executeFiles chooses how filePaths are fed to the query
+func executeFiles(ctx context.Context, jqFilter string, filePaths []string, opts Options) (*Result, error) {
+	guard := newMemoryGuard(opts.MemoryLimit)
+	opts.leases = opts.Cache.leases()
+	defer opts.leases.release()
+	files, err := newQueryFiles(filePaths, opts.BaseDir)
 	if err != nil {
-		return "", fmt.Errorf("failed to compile jq query: %w", err)
+		return nil, err
+	}
+
+	if opts.Stream {
+		if opts.Mode != "" && opts.Mode != ModeInputs {
+			return nil, fmt.Errorf("stream mode reads files through inputs and cannot be combined with %s mode", opts.Mode)
+		}
+		streamIter := newStreamIter(filePaths, guard)
+		defer streamIter.Close()
+		return execute(ctx, jqFilter, nil, streamIter, files, opts)
 	}
 
-	iter := code.Run(nil)
-	var results []interface{}
+	switch opts.Mode {
+	case ModeSlurp:
+		documents, err := slurpFiles(filePaths, guard, opts)
+		if err != nil {
+			return nil, err
+		}
+		return execute(ctx, jqFilter, documents, nil, files, opts)
+	case ModeEach:
+		return executeEach(ctx, jqFilter, filePaths, guard, files, opts)
+	}
 
+	if len(filePaths) == 1 && !IsJSONLinesFile(filePaths[0]) && opts.Mode == "" {
+		if err := guard.checkFileSize(filePaths[0]); err != nil {
+			return nil, err
+		}
+		values, err := opts.Cache.read(filePaths[0], guard, opts.leases)
+		if err != nil {
+			return nil, err
+		}
+		// A file holding several documents, such as a YAML stream, is read through inputs
+		if len(values) != 1 {
+			return execute(ctx, jqFilter, nil, gojq.NewIter(values...), files, opts)
+		}
+		return execute(ctx, jqFilter, values[0], nil, files, opts)
+	}
+
+	inputIter := newInputIter(filePaths, guard, opts)
+	defer inputIter.Close()
+
+	return execute(ctx, jqFilter, nil, inputIter, files, opts)
+}
+
+// execute runs a jq filter on input until it completes or ctx is done. When inputIter is
+// not nil its values are available to the filter through 'input' and 'inputs'. When files
+// is not nil, input_filename names the file each input came from and $__file holds their
+// metadata.
+func execute(ctx context.Context, jqFilter string, input interface{}, inputIter gojq.Iter, files *queryFiles, opts Options) (*Result, error) {
+	names, bindings, err := queryBindings(opts, files)
+	if err != nil {
+		return nil, err
+	}
+
+	compiled, err := compiledQueries.get(jqFilter, names, inputIter, files)
+	if err != nil {
+		return nil, err
+	}
+	defer compiledQueries.put(compiled)
+
+	results := newCollector(opts)
+	if _, err := results.collect(compiled.code.RunWithContext(ctx, input, bindings...), nil); err != nil {
+		return nil, err
+	}
+	return results.finish(opts)
+}
+
+// queryBindings returns the variables bound in a query: opts.Variables, and $__file when
+// the query reads files
+func queryBindings(opts Options, files *queryFiles) ([]string, []interface{}, error) {
+	names, bindings, err := variableBindings(opts.Variables)
+	if err != nil {
+		return nil, nil, err
+	}
+	if files != nil {
+		names = append(names, fileVariable)
+		bindings = append(bindings, files.metadata)
+	}
+	return names, bindings, nil
+}
+
+// collector keeps the page of results a query returns; results past the page are counted
+// but not kept
+type collector struct {
+	result *Result
+	offset int
+	limit  int
+	values []interface{}
+}
+
+func newCollector(opts Options) *collector {
+	return &collector{result: &Result{Offset: opts.Offset}, offset: opts.Offset, limit: opts.pageLimit()}
+}
+
+// collect reads the results of iter, passing each through tag when it is not nil. It
+// returns true when the query must not run any further: it halted, or its deadline passed
+// once the page was full.
+func (c *collector) collect(iter gojq.Iter, tag func(interface{}) interface{}) (bool, error) {
 	for {
 		v, ok := iter.Next()
 		if !ok {
-			break
+			return false, nil
 		}
 		if err, ok := v.(error); ok {
 			if haltErr, ok := err.(*gojq.HaltError); ok && haltErr.Value() == nil {
-				break
+				return true, nil
+			}
+			if errors.Is(err, context.DeadlineExceeded) {
+				if c.limit > 0 && len(c.values) == c.limit {
+					c.result.Total = -1
+					return true, nil
+				}
+				return true, ErrQueryTimeout
+			}
+			if errors.Is(err, context.Canceled) {
+				return true, ErrQueryCancelled
 			}
-			return "", fmt.Errorf("jq execution error: %w", err)
+			return true, fmt.Errorf("jq execution error: %w", err)
+		}
+
+		c.result.Total++
+		if c.result.Total > c.offset && (c.limit == 0 || len(c.values) < c.limit) {
+			if tag != nil {
+				v = tag(v)
+			}
+			c.values = append(c.values, v)
 		}
-		results = append(results, v)
 	}
+}
 
-	var output []byte
-	if len(results) == 1 {
-		output, err = json.MarshalIndent(results[0], "", "  ")
-	} else {
-		output, err = json.MarshalIndent(results, "", "  ")
+// finish renders the kept results
+func (c *collector) finish(opts Options) (*Result, error) {
+	if err := formatResults(c.result, c.values, opts); err != nil {
+		return nil, err
 	}
+	return c.result, nil
+}
 
+// ProcessJQQuery processes a jq query on files specified by patterns
+func ProcessJQQuery(jqFilter string, patterns []string, dataPath string) (string, error) {
+	result, err := ProcessJQQueryWithOptions(context.Background(), jqFilter, patterns, dataPath, Options{})
 	if err != nil {
-		return "", fmt.Errorf("error formatting results: %w", err)
+		return "", err
+	}
+	return result.Output, nil
+}
+
+// ProcessJQQueryWithOptions processes a jq query on files specified by patterns using opts
+func ProcessJQQueryWithOptions(ctx context.Context, jqFilter string, patterns []string, dataPath string, opts Options) (*Result, error) {
+	expandedPaths, box, err := resolvePatterns(patterns, dataPath, opts.Symlinks)
+	if err != nil {
+		return nil, err
 	}
 
-	return string(output), nil
+	if opts.BaseDir == "" {
+		opts.BaseDir = box.Root()
+	}
+	return ExecuteJQFilesWithOptions(ctx, jqFilter, expandedPaths, opts)
 }
 
-// ProcessJQQuery processes a jq query on files specified by patterns
-func ProcessJQQuery(jqFilter string, patterns []string, dataPath string) (string, error) {
+// resolvePatterns expands patterns relative to dataPath into the files they match, rejecting
+// any that leave the data directory. It also returns the sandbox of the data directory.
+func resolvePatterns(patterns []string, dataPath string, symlinks sandbox.SymlinkPolicy) ([]string, *sandbox.Sandbox, error) {
 	if len(patterns) == 0 {
-		return "", fmt.Errorf("no file patterns provided")
+		return nil, nil, fmt.Errorf("no file patterns provided")
 	}
 
-	// Convert relative paths to absolute paths
+	box, err := sandbox.New(dataPath, symlinks)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Convert relative paths to absolute paths, rejecting any that leave the data directory
 	absolutePatterns := make([]string, len(patterns))
 	for i, pattern := range patterns {
-		if !filepath.IsAbs(pattern) {
-			absolutePatterns[i] = filepath.Join(dataPath, pattern)
-		} else {
-			// Security check: verify absolute path is within data directory
-			absDataPath, _ := filepath.Abs(dataPath)
-			absPattern, _ := filepath.Abs(pattern)
-			if !strings.HasPrefix(absPattern, absDataPath) {
-				return "", fmt.Errorf("access denied: path %s is outside data directory", pattern)
-			}
-			absolutePatterns[i] = pattern
+		if absolutePatterns[i], err = box.Pattern(pattern); err != nil {
+			return nil, nil, err
 		}
 	}
 
 	expandedPaths, err := ExpandGlobPatterns(absolutePatterns)
 	if err != nil {
-		return "", fmt.Errorf("error expanding glob patterns: %w", err)
+		return nil, nil, fmt.Errorf("error expanding glob patterns: %w", err)
 	}
 
 	if len(expandedPaths) == 0 {
-		return "", fmt.Errorf("no files found matching the provided patterns")
-	}
-
-	jsonDataList, err := ValidateAndReadJSONFiles(expandedPaths)
-	if err != nil {
-		return "", err
+		return nil, nil, fmt.Errorf("no files found matching the provided patterns")
 	}
 
-	var results string
-	if len(jsonDataList) == 1 {
-		results, err = ExecuteJQ(jqFilter, jsonDataList[0])
-	} else {
-		results, err = ExecuteJQMultiFiles(jqFilter, jsonDataList)
-	}
-
-	if err != nil {
-		return "", err
+	// Matches can still reach outside the data directory through symbolic links
+	for _, path := range expandedPaths {
+		if err := box.Check(path); err != nil {
+			return nil, nil, err
+		}
 	}
-
-	return results, nil
+	return expandedPaths, box, nil
 }
@@ -1,10 +1,13 @@
 package jq
 
 import (
+	"context"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/berrydev-ai/gojq-mcp/sandbox"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -148,6 +151,13 @@ func TestProcessJQQuery(t *testing.T) {
 			expected:  "[\n  \"file1\",\n  \"file2\"\n]",
 			expectErr: false,
 		},
+		{
+			name:      "negated relative pattern",
+			filter:    ".name",
+			patterns:  []string{"**/*.json", "!test2.json"},
+			expected:  "\"file1\"",
+			expectErr: false,
+		},
 		{
 			name:      "no files found",
 			filter:    ".",
@@ -210,6 +220,32 @@ func TestExpandGlobPatterns(t *testing.T) {
 			patterns: []string{filepath.Join(tempDir, "**/*.json")},
 			expected: []string{
 				filepath.Join(tempDir, "subdir/test3.json"),
+				filepath.Join(tempDir, "test1.json"),
+				filepath.Join(tempDir, "test2.json"),
+			},
+		},
+		{
+			name:     "brace expansion",
+			patterns: []string{filepath.Join(tempDir, "{test1,subdir/test3}.json")},
+			expected: []string{
+				filepath.Join(tempDir, "subdir/test3.json"),
+				filepath.Join(tempDir, "test1.json"),
+			},
+		},
+		{
+			name:     "negated pattern",
+			patterns: []string{filepath.Join(tempDir, "**/*.json"), "!" + filepath.Join(tempDir, "subdir/**")},
+			expected: []string{
+				filepath.Join(tempDir, "test1.json"),
+				filepath.Join(tempDir, "test2.json"),
+			},
+		},
+		{
+			name:     "directories are not matched",
+			patterns: []string{filepath.Join(tempDir, "*")},
+			expected: []string{
+				filepath.Join(tempDir, "test1.json"),
+				filepath.Join(tempDir, "test2.json"),
 			},
 		},
 	}
@@ -222,3 +258,63 @@ func TestExpandGlobPatterns(t *testing.T) {
 		})
 	}
 }
+
+func TestExecuteJQFilesWithOptions_Timeout(t *testing.T) {
+	tempDir := t.TempDir()
+	filePath := filepath.Join(tempDir, "data.json")
+	require.NoError(t, os.WriteFile(filePath, []byte(`{"n": 1}`), 0644))
+
+	_, err := ExecuteJQFilesWithOptions(context.Background(), "[range(1e12)] | length", []string{filePath}, Options{Timeout: 50 * time.Millisecond})
+	require.Error(t, err)
+	assert.ErrorIs(t, err, ErrQueryTimeout)
+	assert.Contains(t, err.Error(), "query timed out after 50ms")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = ExecuteJQFilesWithOptions(ctx, "[range(1e12)] | length", []string{filePath, filePath}, Options{})
+	assert.ErrorIs(t, err, ErrQueryCancelled)
+
+	result, err := ExecuteJQFilesWithOptions(context.Background(), ".n", []string{filePath}, Options{Timeout: time.Second})
+	require.NoError(t, err)
+	assert.Equal(t, "1", result.Output)
+}
+
+func TestProcessJQQuery_Sandbox(t *testing.T) {
+	base := t.TempDir()
+	dataDir := filepath.Join(base, "data")
+	for path, content := range map[string]string{
+		"data/users.json":       `{"name": "data"}`,
+		"data-secret/keys.json": `{"name": "secret"}`,
+	} {
+		fullPath := filepath.Join(base, path)
+		require.NoError(t, os.MkdirAll(filepath.Dir(fullPath), 0755))
+		require.NoError(t, os.WriteFile(fullPath, []byte(content), 0644))
+	}
+	require.NoError(t, os.Symlink(filepath.Join(base, "data-secret"), filepath.Join(dataDir, "linked")))
+
+	tests := []struct {
+		name     string
+		patterns []string
+		opts     Options
+		expected string
+		denied   bool
+	}{
+		{name: "sibling sharing the prefix", patterns: []string{filepath.Join(base, "data-secret", "keys.json")}, denied: true},
+		{name: "relative traversal", patterns: []string{"../data-secret/*.json"}, denied: true},
+		{name: "glob reaching through a link", patterns: []string{"**/*.json"}, denied: true},
+		{name: "link excluded", patterns: []string{"**/*.json", "!linked/**"}, expected: `"data"`},
+		{name: "follow policy", patterns: []string{"linked/keys.json"}, opts: Options{Symlinks: sandbox.SymlinksFollow}, expected: `"secret"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := ProcessJQQueryWithOptions(context.Background(), ".name", tt.patterns, dataDir, tt.opts)
+			if tt.denied {
+				assert.ErrorIs(t, err, sandbox.ErrAccessDenied)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, result.Output)
+		})
+	}
+}
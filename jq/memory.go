@@ -0,0 +1,98 @@
+package jq
+
+import (
+	"fmt"
+	"io"
+	"sync"
+)
+
+// DefaultMemoryLimit is the memory ceiling applied to queries when none is configured
+const DefaultMemoryLimit int64 = 1 << 30
+
+// memoryGuard limits the file data one query holds decoded. Files read whole are charged
+// as they are read; through inputs a file is released once the query moves past it, so only
+// the files being read and decoded ahead count, while slurp and each mode and single
+// documents keep their charge until the query ends. JSON Lines records count only when
+// every record is kept. Each query has its own guard, so queries running at the same time
+// and documents kept in the cache do not count against it. Stream events are not counted,
+// and neither are the values the filter builds, such as [range(1e9)] or [inputs]; the
+// query timeout bounds those.
+type memoryGuard struct {
+	limit int64
+	// mu guards the charges made by the workers decoding files ahead of the query
+	mu    sync.Mutex
+	used  int64
+	files map[string]int64
+}
+
+// newMemoryGuard returns a guard enforcing limit, or nil if limit is not positive
+func newMemoryGuard(limit int64) *memoryGuard {
+	if limit <= 0 {
+		return nil
+	}
+	return &memoryGuard{limit: limit, files: make(map[string]int64)}
+}
+
+// usedBytes returns the bytes currently charged
+func (g *memoryGuard) usedBytes() int64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.used
+}
+
+// checkFileSize rejects files that cannot be decoded whole within what is left of the
+// limit. Compressed files are measured by the uncompressed size they record, when they
+// record one.
+func (g *memoryGuard) checkFileSize(filePath string) error {
+	if g == nil {
+		return nil
+	}
+	size, err := dataSize(filePath)
+	if err != nil {
+		return fmt.Errorf("error accessing file %s: %w", filePath, err)
+	}
+	if size > g.limit {
+		return fmt.Errorf("file %s is %d bytes, larger than the memory limit of %d bytes; use stream mode to query it", filePath, size, g.limit)
+	}
+	if g.usedBytes()+size > g.limit {
+		return fmt.Errorf("reading %s would take the query past the memory limit of %d bytes; query fewer files or use stream mode", filePath, g.limit)
+	}
+	return nil
+}
+
+// limitReader caps how much is read from r, which decodes a file, at one byte past what is
+// left of the limit. The size a compressed file records is only a lower bound, so
+// checkFileSize can pass files that decode to more.
+func (g *memoryGuard) limitReader(r io.Reader) io.Reader {
+	if g == nil {
+		return r
+	}
+	return io.LimitReader(r, max(g.limit-g.usedBytes(), 0)+1)
+}
+
+// charge counts size bytes decoded from filePath against the limit, returning an error
+// once the query has decoded more than the limit
+func (g *memoryGuard) charge(filePath string, size int64) error {
+	if g == nil {
+		return nil
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.used += size
+	g.files[filePath] += size
+	if g.used > g.limit {
+		return fmt.Errorf("query exceeded the memory limit of %d bytes reading %s; query fewer files or use stream mode", g.limit, filePath)
+	}
+	return nil
+}
+
+// release drops the charges for filePath once the query no longer holds its data
+func (g *memoryGuard) release(filePath string) {
+	if g == nil {
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.used -= g.files[filePath]
+	delete(g.files, filePath)
+}
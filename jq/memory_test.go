@@ -0,0 +1,67 @@
+package jq
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryGuard(t *testing.T) {
+	tempDir := t.TempDir()
+	record := `{"id": 1, "name": "0123456789"}`
+	var paths []string
+	for _, name := range []string{"a.json", "b.json", "c.json"} {
+		path := filepath.Join(tempDir, name)
+		require.NoError(t, os.WriteFile(path, []byte("["+strings.Repeat(record+",", 15)+record+"]"), 0644))
+		paths = append(paths, path)
+	}
+	fileSize := int64(16*len(record) + 17)
+	eventsPath := filepath.Join(tempDir, "events.jsonl")
+	require.NoError(t, os.WriteFile(eventsPath, []byte(strings.Repeat(record+"\n", 100)), 0644))
+
+	run := func(filter string, paths []string, opts Options) (string, error) {
+		result, err := ExecuteJQFilesWithOptions(context.Background(), filter, paths, opts)
+		if err != nil {
+			return "", err
+		}
+		return result.Output, nil
+	}
+
+	// Through inputs each file is released once the query moves past it, so files that
+	// together pass the limit can be read one after another, decoded ahead or not
+	for _, workers := range []int{1, 4} {
+		output, err := run("[inputs | length] | add", paths, Options{MemoryLimit: fileSize + 10, Workers: workers})
+		require.NoError(t, err)
+		assert.Equal(t, "48", output)
+	}
+
+	// Slurp mode keeps every file, so they must fit together
+	output, err := run("map(length) | add", paths[:2], Options{Mode: ModeSlurp, MemoryLimit: 2*fileSize + 10})
+	require.NoError(t, err)
+	assert.Equal(t, "32", output)
+	_, err = run("map(length) | add", paths, Options{Mode: ModeSlurp, MemoryLimit: 2*fileSize + 10})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), fmt.Sprintf("past the memory limit of %d bytes", 2*fileSize+10))
+
+	// The limit is per query: a cached file counts against each query that reads it
+	opts := Options{Mode: ModeSlurp, MemoryLimit: 2*fileSize + 10, Cache: NewDocumentCache(1 << 20)}
+	for i := 0; i < 3; i++ {
+		output, err = run("map(length) | add", paths[:2], opts)
+		require.NoError(t, err)
+		assert.Equal(t, "32", output)
+	}
+
+	// JSON Lines records read one at a time are not counted, kept ones are
+	output, err = run("reduce inputs as $r (0; . + $r.id)", []string{eventsPath}, Options{MemoryLimit: fileSize})
+	require.NoError(t, err)
+	assert.Equal(t, "100", output)
+	_, err = run("length", []string{eventsPath}, Options{Mode: ModeSlurp, MemoryLimit: fileSize})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "exceeded the memory limit")
+}
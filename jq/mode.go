@@ -0,0 +1,118 @@
+package jq
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Mode selects how the documents of the files a query reads are given to the filter
+type Mode string
+
+const (
+	// ModeInputs runs the filter once with null as '.' and every document available
+	// through 'input' and 'inputs', however many files there are
+	ModeInputs Mode = "inputs"
+	// ModeSlurp runs the filter once with an array of every document as '.', like jq --slurp
+	ModeSlurp Mode = "slurp"
+	// ModeEach runs the filter once per file with the file's contents as '.' and tags each
+	// result with the file it came from
+	ModeEach Mode = "each"
+)
+
+// Modes lists every supported mode
+var Modes = []Mode{ModeInputs, ModeSlurp, ModeEach}
+
+// ParseMode validates a mode name. An empty name selects the default, where a single file
+// is '.' and several files are read through 'inputs'.
+func ParseMode(name string) (Mode, error) {
+	if name == "" {
+		return "", nil
+	}
+
+	names := make([]string, len(Modes))
+	for i, mode := range Modes {
+		if string(mode) == name {
+			return mode, nil
+		}
+		names[i] = string(mode)
+	}
+	return "", fmt.Errorf("unsupported mode %q; use one of %s", name, strings.Join(names, ", "))
+}
+
+// slurpFiles reads every document of filePaths, in order, into one array
+func slurpFiles(filePaths []string, guard *memoryGuard, opts Options) ([]interface{}, error) {
+	iter := newInputIter(filePaths, guard, opts)
+	iter.retain = true
+	defer iter.Close()
+
+	documents := make([]interface{}, 0, len(filePaths))
+	for {
+		v, ok := iter.Next()
+		if !ok {
+			return documents, nil
+		}
+		if err, ok := v.(error); ok {
+			return nil, err
+		}
+		documents = append(documents, v)
+	}
+}
+
+// fileContents returns what '.' is for a file in ModeEach: its document, or an array of
+// its records or documents for JSON Lines files and multi-document files
+func fileContents(filePath string, guard *memoryGuard, opts Options) (interface{}, error) {
+	if IsJSONLinesFile(filePath) {
+		return slurpFiles([]string{filePath}, guard, opts)
+	}
+
+	if err := guard.checkFileSize(filePath); err != nil {
+		return nil, err
+	}
+	values, err := opts.Cache.read(filePath, guard, opts.leases)
+	if err != nil {
+		return nil, err
+	}
+	if len(values) == 1 {
+		return values[0], nil
+	}
+	return values, nil
+}
+
+// executeEach runs a jq filter once per file, reading each file only when its turn comes.
+// Every result becomes {"file": name, "result": value}.
+func executeEach(ctx context.Context, jqFilter string, filePaths []string, guard *memoryGuard, files *queryFiles, opts Options) (*Result, error) {
+	names, bindings, err := queryBindings(opts, files)
+	if err != nil {
+		return nil, err
+	}
+
+	// Compiled without an input iterator, so filters using input or inputs are rejected
+	compiled, err := compiledQueries.get(jqFilter, names, nil, files)
+	if err != nil {
+		return nil, err
+	}
+	defer compiledQueries.put(compiled)
+
+	results := newCollector(opts)
+	for _, filePath := range filePaths {
+		input, err := fileContents(filePath, guard, opts)
+		if err != nil {
+			return nil, err
+		}
+
+		files.input = filePath
+		name := files.name(filePath)
+		tag := func(v interface{}) interface{} {
+			return map[string]interface{}{"file": name, "result": v}
+		}
+		stop, err := results.collect(compiled.code.RunWithContext(ctx, input, bindings...), tag)
+		if err != nil {
+			return nil, err
+		}
+		if stop {
+			break
+		}
+	}
+	return results.finish(opts)
+}
@@ -0,0 +1,122 @@
+package jq
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseMode(t *testing.T) {
+	for _, mode := range Modes {
+		parsed, err := ParseMode(string(mode))
+		require.NoError(t, err)
+		assert.Equal(t, mode, parsed)
+	}
+
+	parsed, err := ParseMode("")
+	require.NoError(t, err)
+	assert.Equal(t, Mode(""), parsed)
+
+	_, err = ParseMode("merge")
+	assert.EqualError(t, err, `unsupported mode "merge"; use one of inputs, slurp, each`)
+}
+
+func TestProcessJQQuery_Modes(t *testing.T) {
+	tempDir := t.TempDir()
+	files := map[string]string{
+		"orders/a.json":       `{"total": 1}`,
+		"orders/b.json":       `{"total": 2}`,
+		"orders/events.jsonl": "{\"total\": 3}\n{\"total\": 4}\n",
+		"orders/refunds.yaml": "total: 5\n---\ntotal: 6\n",
+	}
+	for name, content := range files {
+		fullPath := filepath.Join(tempDir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(fullPath), 0755))
+		require.NoError(t, os.WriteFile(fullPath, []byte(content), 0644))
+	}
+
+	tests := []struct {
+		name     string
+		filter   string
+		patterns []string
+		opts     Options
+		expected string
+	}{
+		{
+			name:     "inputs with a single file",
+			filter:   "[inputs.total]",
+			patterns: []string{"orders/a.json"},
+			opts:     Options{Mode: ModeInputs, OutputFormat: FormatCompact},
+			expected: "[1]",
+		},
+		{
+			name:     "inputs leaves . null",
+			filter:   ".",
+			patterns: []string{"orders/a.json"},
+			opts:     Options{Mode: ModeInputs},
+			expected: "null",
+		},
+		{
+			name:     "slurp across files",
+			filter:   "map(.total)",
+			patterns: []string{"orders/*"},
+			opts:     Options{Mode: ModeSlurp, OutputFormat: FormatCompact},
+			expected: "[1,2,3,4,5,6]",
+		},
+		{
+			name:     "slurp with a single file",
+			filter:   "map(.total)",
+			patterns: []string{"orders/a.json"},
+			opts:     Options{Mode: ModeSlurp, OutputFormat: FormatCompact},
+			expected: "[1]",
+		},
+		{
+			name:     "each file",
+			filter:   `if type == "array" then map(.total) else .total end`,
+			patterns: []string{"orders/*"},
+			opts:     Options{Mode: ModeEach, OutputFormat: FormatCompact},
+			expected: `[{"file":"orders/a.json","result":1},{"file":"orders/b.json","result":2},{"file":"orders/events.jsonl","result":[3,4]},{"file":"orders/refunds.yaml","result":[5,6]}]`,
+		},
+		{
+			name:     "each with a single file",
+			filter:   ".total",
+			patterns: []string{"orders/b.json"},
+			opts:     Options{Mode: ModeEach, OutputFormat: FormatCompact},
+			expected: `{"file":"orders/b.json","result":2}`,
+		},
+		{
+			name:     "each names its file",
+			filter:   "input_filename",
+			patterns: []string{"orders/*.json"},
+			opts:     Options{Mode: ModeEach, OutputFormat: FormatCompact},
+			expected: `[{"file":"orders/a.json","result":"orders/a.json"},{"file":"orders/b.json","result":"orders/b.json"}]`,
+		},
+		{
+			name:     "each pages across files",
+			filter:   `if type == "array" then .[].total else .total end`,
+			patterns: []string{"orders/*"},
+			opts:     Options{Mode: ModeEach, OutputFormat: FormatCompact, Offset: 2, Limit: 2},
+			expected: `[{"file":"orders/events.jsonl","result":3},{"file":"orders/events.jsonl","result":4}]`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := ProcessJQQueryWithOptions(context.Background(), tt.filter, tt.patterns, tempDir, tt.opts)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, result.Output)
+		})
+	}
+
+	// Each file is '.', so there are no inputs to read
+	_, err := ProcessJQQueryWithOptions(context.Background(), "[inputs]", []string{"orders/*"}, tempDir, Options{Mode: ModeEach})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "input(s)/0 is not allowed")
+
+	_, err = ProcessJQQueryWithOptions(context.Background(), ".", []string{"orders/*.json"}, tempDir, Options{Mode: ModeSlurp, Stream: true})
+	assert.EqualError(t, err, "stream mode reads files through inputs and cannot be combined with slurp mode")
+}
@@ -0,0 +1,81 @@
+package jq
+
+import "sync"
+
+// DefaultWorkers is the number of files decoded at the same time when none is configured
+const DefaultWorkers = 4
+
+// prefetcher decodes files ahead of the query on up to workers goroutines. Decoded files
+// are handed out in path order, and at most workers of them are held at once, so memory use
+// stays bounded however far the query is behind.
+type prefetcher struct {
+	// results holds one channel per path; it is nil for JSON Lines files, which are read in order
+	results   []chan decodedFile
+	slots     chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+	running   sync.WaitGroup
+}
+
+// decodedFile is the outcome of decoding one file
+type decodedFile struct {
+	values []interface{}
+	err    error
+}
+
+// newPrefetcher starts decoding the files among paths that are not JSON Lines with decode
+func newPrefetcher(paths []string, workers int, decode func(string) ([]interface{}, error)) *prefetcher {
+	p := &prefetcher{
+		results: make([]chan decodedFile, len(paths)),
+		slots:   make(chan struct{}, workers),
+		done:    make(chan struct{}),
+	}
+	for i, path := range paths {
+		if !IsJSONLinesFile(path) {
+			p.results[i] = make(chan decodedFile, 1)
+		}
+	}
+	p.running.Add(1)
+	go p.dispatch(paths, decode)
+	return p
+}
+
+// dispatch starts a goroutine for each file in order as slots become free
+func (p *prefetcher) dispatch(paths []string, decode func(string) ([]interface{}, error)) {
+	defer p.running.Done()
+	for i, path := range paths {
+		if p.results[i] == nil {
+			continue
+		}
+		select {
+		case p.slots <- struct{}{}:
+		case <-p.done:
+			return
+		}
+		p.running.Add(1)
+		go func(result chan<- decodedFile, path string) {
+			defer p.running.Done()
+			values, err := decode(path)
+			result <- decodedFile{values: values, err: err}
+		}(p.results[i], path)
+	}
+}
+
+// take waits for the file at index i to be decoded and frees its slot. Files must be taken
+// in path order.
+func (p *prefetcher) take(i int) ([]interface{}, error) {
+	result := <-p.results[i]
+	<-p.slots
+	return result.values, result.err
+}
+
+// close stops decoding further files; files being decoded are finished and dropped
+func (p *prefetcher) close() {
+	p.closeOnce.Do(func() { close(p.done) })
+}
+
+// stop stops decoding further files and waits for the files being decoded to finish
+func (p *prefetcher) stop() {
+	p.close()
+	p.running.Wait()
+}
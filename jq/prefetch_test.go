@@ -0,0 +1,87 @@
+package jq
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeDailyFiles writes one JSON file per day holding records transactions and returns
+// their sorted paths
+func writeDailyFiles(t testing.TB, dir string, days, records int) []string {
+	var paths []string
+	for day := 0; day < days; day++ {
+		var transactions []string
+		for i := 0; i < records; i++ {
+			transactions = append(transactions, fmt.Sprintf(`{"id": %d, "amount": %d.5, "memo": "transaction %d of day %d"}`, i, i, i, day))
+		}
+		path := filepath.Join(dir, fmt.Sprintf("day-%03d.json", day))
+		content := fmt.Sprintf(`{"day": %d, "transactions": [%s]}`, day, strings.Join(transactions, ","))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+		paths = append(paths, path)
+	}
+	return paths
+}
+
+func TestExecuteJQFilesWithOptions_Workers(t *testing.T) {
+	tempDir := t.TempDir()
+	paths := writeDailyFiles(t, tempDir, 30, 5)
+
+	// JSON Lines files are read in order between the decoded files
+	eventsPath := filepath.Join(tempDir, "day-010.5.jsonl")
+	require.NoError(t, os.WriteFile(eventsPath, []byte("{\"day\": 10.5}\n"), 0644))
+	mixed := append(append(append([]string{}, paths[:11]...), eventsPath), paths[11:]...)
+
+	brokenPath := filepath.Join(tempDir, "day-020.5.json")
+	require.NoError(t, os.WriteFile(brokenPath, []byte(`{"day": `), 0644))
+	broken := append(append(append([]string{}, paths[:21]...), brokenPath), paths[21:]...)
+
+	tests := []struct {
+		name      string
+		filter    string
+		paths     []string
+		expected  string
+		expectErr string
+	}{
+		{name: "order is kept", filter: "[inputs.day] | . == sort and length == 30", paths: paths, expected: "true"},
+		{name: "JSON Lines in between", filter: "[inputs.day][10:13]", paths: mixed, expected: "[\n  10,\n  10.5,\n  11\n]"},
+		{name: "stopping early", filter: "input.day", paths: paths, expected: "0"},
+		{name: "error in a later file", filter: "[inputs.day] | length", paths: broken, expectErr: "day-020.5.json does not contain valid JSON"},
+	}
+
+	for _, tt := range tests {
+		for _, workers := range []int{1, 4} {
+			t.Run(fmt.Sprintf("%s/workers-%d", tt.name, workers), func(t *testing.T) {
+				result, err := ExecuteJQFilesWithOptions(context.Background(), tt.filter, tt.paths, Options{Workers: workers})
+				if tt.expectErr != "" {
+					require.Error(t, err)
+					assert.Contains(t, err.Error(), tt.expectErr)
+					return
+				}
+				require.NoError(t, err)
+				assert.Equal(t, tt.expected, result.Output)
+			})
+		}
+	}
+}
+
+func BenchmarkExecuteJQFiles_Workers(b *testing.B) {
+	paths := writeDailyFiles(b, b.TempDir(), 365, 200)
+
+	for _, workers := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("workers-%d", workers), func(b *testing.B) {
+			opts := Options{Workers: workers}
+			for i := 0; i < b.N; i++ {
+				if _, err := ExecuteJQFilesWithOptions(context.Background(), "[inputs.transactions[].amount] | add", paths, opts); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,168 @@
+package jq
+
+import (
+	"container/list"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/itchyny/gojq"
+)
+
+// queryCacheSize is the number of distinct filters whose compiled code is kept
+const queryCacheSize = 256
+
+// compiledQueries is shared by every query the package runs
+var compiledQueries = newQueryCache(queryCacheSize)
+
+// compiledQuery is a filter compiled to read 'input', 'inputs' and 'input_filename' from a
+// proxy, so the same code can be run again on other inputs
+type compiledQuery struct {
+	key    string
+	code   *gojq.Code
+	inputs *inputProxy
+}
+
+// inputProxy passes 'input' and 'inputs' on to the iterator of the run using the code, and
+// answers 'input_filename' from the files that run reads
+type inputProxy struct {
+	iter  gojq.Iter
+	files *queryFiles
+}
+
+func (p *inputProxy) Next() (interface{}, bool) {
+	if p.iter == nil {
+		return nil, false
+	}
+	return p.iter.Next()
+}
+
+// filename returns the name of the file the last input came from, or null when the input
+// did not come from a file
+func (p *inputProxy) filename(interface{}, []interface{}) interface{} {
+	if p.files == nil {
+		return nil
+	}
+	path := p.files.input
+	if iter, ok := p.iter.(fileIter); ok {
+		path = iter.currentFile()
+	}
+	if path == "" {
+		return nil
+	}
+	return p.files.name(path)
+}
+
+// queryCache keeps the compiled code of recently used filters, least recently used first
+// out. gojq fixes the input iterator when a filter is compiled, so each filter keeps a pool
+// of compiled copies and a run takes one for itself, letting sessions run the same filter
+// at the same time.
+type queryCache struct {
+	mu      sync.Mutex
+	size    int
+	order   *list.List // most recently used first
+	entries map[string]*list.Element
+	hits    int64
+	misses  int64
+}
+
+// queryEntry is the parsed filter for a key and its compiled copies not in use
+type queryEntry struct {
+	key   string
+	query *gojq.Query
+	idle  []*compiledQuery
+}
+
+func newQueryCache(size int) *queryCache {
+	return &queryCache{
+		size:    size,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// queryKey identifies compiled code by the filter and the options it was compiled with
+func queryKey(jqFilter string, variables []string, withInputs bool) string {
+	return fmt.Sprintf("%t\x00%s\x00%s", withInputs, strings.Join(variables, ","), jqFilter)
+}
+
+// get returns compiled code for jqFilter, binding variables, that reads 'inputs' from
+// inputs when it is not nil and names files from files. The code must be handed back with
+// put once the run is over.
+func (c *queryCache) get(jqFilter string, variables []string, inputs gojq.Iter, files *queryFiles) (*compiledQuery, error) {
+	key := queryKey(jqFilter, variables, inputs != nil)
+
+	c.mu.Lock()
+	var query *gojq.Query
+	if element, ok := c.entries[key]; ok {
+		c.hits++
+		c.order.MoveToFront(element)
+		entry := element.Value.(*queryEntry)
+		if n := len(entry.idle); n > 0 {
+			compiled := entry.idle[n-1]
+			entry.idle = entry.idle[:n-1]
+			c.mu.Unlock()
+			compiled.inputs.iter = inputs
+			compiled.inputs.files = files
+			return compiled, nil
+		}
+		query = entry.query
+	} else {
+		c.misses++
+	}
+	c.mu.Unlock()
+
+	if query == nil {
+		var err error
+		if query, err = gojq.Parse(jqFilter); err != nil {
+			return nil, fmt.Errorf("invalid jq filter: %w", syntaxError(jqFilter, err))
+		}
+	}
+
+	compiled := &compiledQuery{key: key, inputs: &inputProxy{iter: inputs, files: files}}
+	compilerOpts := []gojq.CompilerOption{
+		gojq.WithVariables(variables),
+		gojq.WithFunction("input_filename", 0, 0, compiled.inputs.filename),
+	}
+	if inputs != nil {
+		compilerOpts = append(compilerOpts, gojq.WithInputIter(compiled.inputs))
+	}
+	code, err := gojq.Compile(query, compilerOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile jq query: %w", err)
+	}
+	compiled.code = code
+
+	c.add(key, query)
+	return compiled, nil
+}
+
+// add records the parsed filter for key, evicting the least recently used filter when full
+func (c *queryCache) add(key string, query *gojq.Query) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.entries[key]; ok {
+		return
+	}
+	for c.order.Len() >= c.size {
+		evicted := c.order.Remove(c.order.Back()).(*queryEntry)
+		delete(c.entries, evicted.key)
+	}
+	c.entries[key] = c.order.PushFront(&queryEntry{key: key, query: query})
+}
+
+// put makes compiled code available to later runs of the same filter
+func (c *queryCache) put(compiled *compiledQuery) {
+	compiled.inputs.iter = nil
+	compiled.inputs.files = nil
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Code for a filter evicted while it ran is dropped
+	if element, ok := c.entries[compiled.key]; ok {
+		entry := element.Value.(*queryEntry)
+		entry.idle = append(entry.idle, compiled)
+	}
+}
@@ -0,0 +1,80 @@
+package jq
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryCache(t *testing.T) {
+	cache := newQueryCache(2)
+
+	compiled, err := cache.get(".a", nil, nil, nil)
+	require.NoError(t, err)
+	cache.put(compiled)
+	reused, err := cache.get(".a", nil, nil, nil)
+	require.NoError(t, err)
+	assert.Same(t, compiled, reused, "idle code is reused")
+
+	// Code in use is not handed out twice
+	other, err := cache.get(".a", nil, nil, nil)
+	require.NoError(t, err)
+	assert.NotSame(t, reused, other)
+	cache.put(reused)
+	cache.put(other)
+
+	// Variables and inputs are part of the key
+	withVariables, err := cache.get(".a", []string{"$x"}, nil, nil)
+	require.NoError(t, err)
+	assert.NotSame(t, compiled, withVariables)
+	cache.put(withVariables)
+	assert.Equal(t, int64(2), cache.hits)
+	assert.Equal(t, int64(2), cache.misses)
+
+	// Filters that do not parse or compile are not kept
+	_, err = cache.get(".[", nil, nil, nil)
+	assert.ErrorContains(t, err, "invalid jq filter")
+	_, err = cache.get("input", nil, nil, nil)
+	assert.ErrorContains(t, err, "failed to compile jq query")
+	assert.Equal(t, 2, cache.order.Len())
+
+	// The least recently used filter is evicted
+	compiled, err = cache.get(".b", nil, nil, nil)
+	require.NoError(t, err)
+	cache.put(compiled)
+	assert.Equal(t, 2, cache.order.Len())
+	assert.NotContains(t, cache.entries, queryKey(".a", nil, false))
+	assert.Contains(t, cache.entries, queryKey(".a", []string{"$x"}, false))
+}
+
+func TestExecute_ReusesCompiledQueries(t *testing.T) {
+	filter := "[inputs.n] | add"
+	var wg sync.WaitGroup
+	results := make([]string, 20)
+	errs := make([]error, 20)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			inputs := []interface{}{map[string]interface{}{"n": i}, map[string]interface{}{"n": 1000}}
+			results[i], errs[i] = ExecuteJQMultiFiles(filter, inputs)
+		}(i)
+	}
+	wg.Wait()
+
+	for i, result := range results {
+		require.NoError(t, errs[i])
+		assert.Equal(t, fmt.Sprint(i+1000), result)
+	}
+
+	// Bindings are per run even though the code is shared
+	for _, name := range []string{"ada", "grace"} {
+		result, err := execute(context.Background(), "$name", nil, nil, nil, Options{Variables: map[string]interface{}{"name": name}})
+		require.NoError(t, err)
+		assert.Equal(t, fmt.Sprintf("%q", name), result.Output)
+	}
+}
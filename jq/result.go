@@ -0,0 +1,69 @@
+package jq
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+// Result is the formatted output of a query together with what was left out of it
+type Result struct {
+	Output string
+	// Total is the number of results the query produced, or -1 if it ran out of time while counting
+	Total int
+	// Offset is the number of results skipped before the first one in Output
+	Offset int
+	// Returned is the number of results in Output
+	Returned int
+	// Truncated is set when results after the returned ones were left out
+	Truncated bool
+	// Cut is set when a single result was longer than the byte limit and its text was shortened
+	Cut bool
+	// Size is the length in bytes of the result text before it was cut
+	Size int
+}
+
+// Summary describes the part of the results that Output holds, or returns "" when Output holds them all
+func (r *Result) Summary() string {
+	switch {
+	case r.Cut:
+		return fmt.Sprintf("Result truncated to %d of %d bytes. Select fewer fields or filter the data to see the rest.", len(r.Output), r.Size)
+	case r.Truncated && r.Total < 0:
+		return fmt.Sprintf("Showing %d results starting at offset %d; more results are available but counting them timed out. Use offset=%d to see more.",
+			r.Returned, r.Offset, r.Offset+r.Returned)
+	case r.Truncated:
+		return fmt.Sprintf("Showing %d of %d results starting at offset %d. Use offset=%d to see more.",
+			r.Returned, r.Total, r.Offset, r.Offset+r.Returned)
+	case r.Offset > 0 && r.Returned == 0:
+		return fmt.Sprintf("No results at offset %d; the query produced %d results.", r.Offset, r.Total)
+	}
+	return ""
+}
+
+// pageLimit returns the maximum number of results to keep, or zero for no limit
+func (opts Options) pageLimit() int {
+	limit := opts.Limit
+	if opts.MaxResultItems > 0 && (limit == 0 || opts.MaxResultItems < limit) {
+		limit = opts.MaxResultItems
+	}
+	return limit
+}
+
+// paged reports whether results were requested a page at a time
+func (opts Options) paged() bool {
+	return opts.Offset > 0 || opts.Limit > 0
+}
+
+// cutOutput shortens the output to maxBytes, keeping it valid UTF-8
+func cutOutput(result *Result, maxBytes int) {
+	if maxBytes <= 0 || len(result.Output) <= maxBytes {
+		return
+	}
+	result.Size = len(result.Output)
+	result.Cut = true
+
+	output := result.Output[:maxBytes]
+	for len(output) > 0 && !utf8.ValidString(output) {
+		output = output[:len(output)-1]
+	}
+	result.Output = output
+}
@@ -0,0 +1,146 @@
+package jq
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExecuteJQFilesWithOptions_Pagination(t *testing.T) {
+	tempDir := t.TempDir()
+	filePath := filepath.Join(tempDir, "numbers.json")
+	require.NoError(t, os.WriteFile(filePath, []byte(`[0, 1, 2, 3, 4, 5, 6, 7, 8, 9]`), 0644))
+
+	tests := []struct {
+		name      string
+		filter    string
+		opts      Options
+		expected  []interface{}
+		total     int
+		truncated bool
+		summary   string
+	}{
+		{
+			name:      "first page",
+			filter:    ".[]",
+			opts:      Options{Limit: 3},
+			expected:  []interface{}{0.0, 1.0, 2.0},
+			total:     10,
+			truncated: true,
+			summary:   "Showing 3 of 10 results starting at offset 0. Use offset=3 to see more.",
+		},
+		{
+			name:      "middle page",
+			filter:    ".[]",
+			opts:      Options{Offset: 3, Limit: 3},
+			expected:  []interface{}{3.0, 4.0, 5.0},
+			total:     10,
+			truncated: true,
+			summary:   "Use offset=6 to see more.",
+		},
+		{
+			name:     "last page",
+			filter:   ".[]",
+			opts:     Options{Offset: 8, Limit: 3},
+			expected: []interface{}{8.0, 9.0},
+			total:    10,
+		},
+		{
+			name:     "past the end",
+			filter:   ".[]",
+			opts:     Options{Offset: 20},
+			expected: []interface{}{},
+			total:    10,
+			summary:  "No results at offset 20; the query produced 10 results.",
+		},
+		{
+			name:     "single result is an array when paging",
+			filter:   ".[0]",
+			opts:     Options{Limit: 5},
+			expected: []interface{}{0.0},
+			total:    1,
+		},
+		{
+			name:      "item cap applies without a limit",
+			filter:    ".[]",
+			opts:      Options{MaxResultItems: 4},
+			expected:  []interface{}{0.0, 1.0, 2.0, 3.0},
+			total:     10,
+			truncated: true,
+		},
+		{
+			name:      "item cap overrides a larger limit",
+			filter:    ".[]",
+			opts:      Options{Limit: 8, MaxResultItems: 2},
+			expected:  []interface{}{0.0, 1.0},
+			total:     10,
+			truncated: true,
+		},
+		{
+			name:      "byte cap keeps whole results",
+			filter:    ".[]",
+			opts:      Options{MaxResultBytes: 20},
+			expected:  []interface{}{0.0, 1.0, 2.0},
+			total:     10,
+			truncated: true,
+			summary:   "Showing 3 of 10 results starting at offset 0. Use offset=3 to see more.",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := ExecuteJQFilesWithOptions(context.Background(), tt.filter, []string{filePath}, tt.opts)
+			require.NoError(t, err)
+
+			var values []interface{}
+			require.NoError(t, json.Unmarshal([]byte(result.Output), &values))
+			assert.Equal(t, tt.expected, values)
+			assert.Equal(t, tt.total, result.Total)
+			assert.Equal(t, len(tt.expected), result.Returned)
+			assert.Equal(t, tt.truncated, result.Truncated)
+			if tt.opts.MaxResultBytes > 0 {
+				assert.LessOrEqual(t, len(result.Output), tt.opts.MaxResultBytes)
+			}
+			if tt.summary != "" {
+				assert.Contains(t, result.Summary(), tt.summary)
+			} else if !tt.truncated {
+				assert.Empty(t, result.Summary())
+			}
+		})
+	}
+}
+
+func TestExecuteJQFilesWithOptions_CutResult(t *testing.T) {
+	tempDir := t.TempDir()
+	filePath := filepath.Join(tempDir, "large.json")
+	require.NoError(t, os.WriteFile(filePath, []byte(`{"text": "`+strings.Repeat("é", 1000)+`"}`), 0644))
+
+	// The cut falls in the middle of a two-byte character, which is dropped
+	result, err := ExecuteJQFilesWithOptions(context.Background(), ".", []string{filePath}, Options{MaxResultBytes: 100})
+	require.NoError(t, err)
+	assert.True(t, result.Cut)
+	assert.Equal(t, "{\n  \"text\": \""+strings.Repeat("é", 43), result.Output)
+	assert.Equal(t, 2016, result.Size)
+	assert.Contains(t, result.Summary(), "Result truncated to 99 of 2016 bytes")
+}
+
+func TestExecuteJQFilesWithOptions_CountTimeout(t *testing.T) {
+	tempDir := t.TempDir()
+	filePath := filepath.Join(tempDir, "data.json")
+	require.NoError(t, os.WriteFile(filePath, []byte(`null`), 0644))
+
+	// A full page is returned even if counting the remaining results runs out of time
+	result, err := ExecuteJQFilesWithOptions(context.Background(), "range(1e12)", []string{filePath}, Options{Limit: 3, Timeout: 50 * time.Millisecond})
+	require.NoError(t, err)
+	assert.Equal(t, "[\n  0,\n  1,\n  2\n]", result.Output)
+	assert.Equal(t, -1, result.Total)
+	assert.True(t, result.Truncated)
+	assert.Contains(t, result.Summary(), "counting them timed out. Use offset=3 to see more.")
+}
@@ -0,0 +1,310 @@
+package jq
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+)
+
+// SampleMethod selects which records a sample keeps
+type SampleMethod string
+
+const (
+	// SampleHead keeps the first records and stops reading once it has them
+	SampleHead SampleMethod = "head"
+	// SampleTail keeps the last records
+	SampleTail SampleMethod = "tail"
+	// SampleRandom picks records uniformly at random, counting the records in a first pass
+	// and collecting the chosen ones in a second
+	SampleRandom SampleMethod = "random"
+	// SampleReservoir picks records uniformly at random in a single pass, holding no more
+	// than the sample in memory
+	SampleReservoir SampleMethod = "reservoir"
+)
+
+// SampleMethods lists every supported sample method
+var SampleMethods = []SampleMethod{SampleHead, SampleTail, SampleRandom, SampleReservoir}
+
+const (
+	// DefaultSampleCount is the number of records sampled when none is given
+	DefaultSampleCount = 10
+	// MaxSampleCount is the largest number of records a sample may hold
+	MaxSampleCount = 1000
+	// DefaultSampleStringLength is the number of bytes sampled strings are cut to when no
+	// length is given
+	DefaultSampleStringLength = 200
+)
+
+// ParseSampleMethod validates a sample method name; an empty name selects SampleHead
+func ParseSampleMethod(name string) (SampleMethod, error) {
+	if name == "" {
+		return SampleHead, nil
+	}
+
+	names := make([]string, len(SampleMethods))
+	for i, method := range SampleMethods {
+		if string(method) == name {
+			return method, nil
+		}
+		names[i] = string(method)
+	}
+	return "", fmt.Errorf("unsupported sample method %q; use one of %s", name, strings.Join(names, ", "))
+}
+
+// SampleOptions selects the records a sample is drawn from and how
+type SampleOptions struct {
+	// Path is a jq expression evaluated on each document; the elements of the arrays it
+	// produces are the records, and any other value it produces is a record itself.
+	// Empty selects '.'.
+	Path   string
+	Count  int
+	Method SampleMethod
+	// Seed makes random and reservoir samples repeatable
+	Seed int64
+	// MaxStringLength is the number of bytes strings in records are cut to; zero keeps them whole
+	MaxStringLength int
+}
+
+// Sample is the records drawn from the files a query matched
+type Sample struct {
+	Method SampleMethod `json:"method"`
+	// Seed is the seed random and reservoir samples were drawn with
+	Seed *int64 `json:"seed,omitempty"`
+	// Total is the number of records read; head samples stop early and do not report it
+	Total   *int           `json:"total,omitempty"`
+	Records []SampleRecord `json:"records"`
+}
+
+// SampleRecord is one sampled record with the file it came from and its position among the
+// records of that file
+type SampleRecord struct {
+	File   string      `json:"file"`
+	Index  int         `json:"index"`
+	Record interface{} `json:"record"`
+}
+
+// SampleRecords draws a sample of the records in the files patterns match, relative to
+// dataPath. Records are returned in the order they appear in the files.
+func SampleRecords(ctx context.Context, patterns []string, dataPath string, sample SampleOptions, opts Options) (*Sample, error) {
+	filePaths, box, err := resolvePatterns(patterns, dataPath, opts.Symlinks)
+	if err != nil {
+		return nil, err
+	}
+	if opts.BaseDir == "" {
+		opts.BaseDir = box.Root()
+	}
+	return sampleFiles(ctx, filePaths, sample, opts)
+}
+
+// sampleFiles draws a sample of the records of filePaths
+func sampleFiles(ctx context.Context, filePaths []string, sample SampleOptions, opts Options) (*Sample, error) {
+	if sample.Count < 1 || sample.Count > MaxSampleCount {
+		return nil, fmt.Errorf("sample count must be between 1 and %d", MaxSampleCount)
+	}
+	if sample.Path == "" {
+		sample.Path = "."
+	}
+	if err := checkFiles(filePaths); err != nil {
+		return nil, err
+	}
+	files, err := newQueryFiles(filePaths, opts.BaseDir)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+
+	// Kept records are copied as their strings are cut, so they may come from the cache
+	opts.leases = opts.Cache.leases()
+	defer opts.leases.release()
+	r := &recordReader{filePaths: filePaths, path: sample.Path, files: files, opts: opts}
+	result := &Sample{Method: sample.Method, Records: []SampleRecord{}}
+	keep := func(record SampleRecord) SampleRecord {
+		record.Record = truncateStrings(record.Record, sample.MaxStringLength)
+		return record
+	}
+
+	var kept []SampleRecord
+	total := 0
+	switch sample.Method {
+	case SampleHead, "":
+		result.Method = SampleHead
+		err = r.each(ctx, func(record SampleRecord) bool {
+			kept = append(kept, keep(record))
+			return len(kept) == sample.Count
+		})
+
+	case SampleTail:
+		// kept is a ring buffer holding the last records read
+		err = r.each(ctx, func(record SampleRecord) bool {
+			if len(kept) < sample.Count {
+				kept = append(kept, keep(record))
+			} else {
+				kept[total%sample.Count] = keep(record)
+			}
+			total++
+			return false
+		})
+		if err == nil && total > sample.Count {
+			start := total % sample.Count
+			kept = append(kept[start:], kept[:start]...)
+		}
+
+	case SampleRandom:
+		err = r.each(ctx, func(SampleRecord) bool {
+			total++
+			return false
+		})
+		if err != nil {
+			break
+		}
+		chosen := chooseIndexes(rand.New(rand.NewSource(sample.Seed)), total, sample.Count)
+		seen := 0
+		err = r.each(ctx, func(record SampleRecord) bool {
+			if chosen[seen] {
+				kept = append(kept, keep(record))
+			}
+			seen++
+			return len(kept) == len(chosen)
+		})
+
+	case SampleReservoir:
+		// Algorithm R; each kept record remembers when it was read so the sample can be put
+		// back in file order
+		rng := rand.New(rand.NewSource(sample.Seed))
+		var order []int
+		err = r.each(ctx, func(record SampleRecord) bool {
+			if len(kept) < sample.Count {
+				kept = append(kept, keep(record))
+				order = append(order, total)
+			} else if j := rng.Int63n(int64(total) + 1); j < int64(sample.Count) {
+				kept[j] = keep(record)
+				order[j] = total
+			}
+			total++
+			return false
+		})
+		sort.Sort(byOrder{records: kept, order: order})
+
+	default:
+		return nil, fmt.Errorf("unsupported sample method %q", sample.Method)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if kept != nil {
+		result.Records = kept
+	}
+	if result.Method != SampleHead {
+		result.Total = &total
+	}
+	if result.Method == SampleRandom || result.Method == SampleReservoir {
+		result.Seed = &sample.Seed
+	}
+	return result, nil
+}
+
+// chooseIndexes picks count of the indexes below total uniformly at random with Floyd's
+// algorithm, or all of them when there are no more than count
+func chooseIndexes(rng *rand.Rand, total, count int) map[int]bool {
+	chosen := make(map[int]bool, min(total, count))
+	if total <= count {
+		for i := 0; i < total; i++ {
+			chosen[i] = true
+		}
+		return chosen
+	}
+	for j := total - count; j < total; j++ {
+		if t := rng.Intn(j + 1); chosen[t] {
+			chosen[j] = true
+		} else {
+			chosen[t] = true
+		}
+	}
+	return chosen
+}
+
+// byOrder sorts reservoir records by when they were read
+type byOrder struct {
+	records []SampleRecord
+	order   []int
+}
+
+func (b byOrder) Len() int           { return len(b.records) }
+func (b byOrder) Less(i, j int) bool { return b.order[i] < b.order[j] }
+func (b byOrder) Swap(i, j int) {
+	b.records[i], b.records[j] = b.records[j], b.records[i]
+	b.order[i], b.order[j] = b.order[j], b.order[i]
+}
+
+// recordReader reads the records a path selects from the documents of files
+type recordReader struct {
+	filePaths []string
+	path      string
+	files     *queryFiles
+	opts      Options
+}
+
+// each passes every record to visit in file order until visit returns true
+func (r *recordReader) each(ctx context.Context, visit func(SampleRecord) bool) error {
+	compiled, err := compiledQueries.get(r.path, nil, nil, r.files)
+	if err != nil {
+		return err
+	}
+	defer compiledQueries.put(compiled)
+
+	iter := newInputIter(r.filePaths, newMemoryGuard(r.opts.MemoryLimit), r.opts)
+	defer iter.Close()
+
+	var file string
+	index := 0
+	for {
+		if err := ctx.Err(); err != nil {
+			return interrupted(err, r.opts)
+		}
+		document, ok := iter.Next()
+		if !ok {
+			return nil
+		}
+		if err, ok := document.(error); ok {
+			return err
+		}
+		if current := iter.currentFile(); current != file {
+			file = current
+			index = 0
+		}
+		r.files.input = file
+
+		results := compiled.code.RunWithContext(ctx, document)
+		for {
+			v, ok := results.Next()
+			if !ok {
+				break
+			}
+			if err, ok := v.(error); ok {
+				if ctxErr := ctx.Err(); ctxErr != nil {
+					return interrupted(ctxErr, r.opts)
+				}
+				return fmt.Errorf("error evaluating path %s: %w", r.path, err)
+			}
+
+			records, ok := v.([]interface{})
+			if !ok {
+				records = []interface{}{v}
+			}
+			for _, record := range records {
+				if visit(SampleRecord{File: r.files.name(file), Index: index, Record: record}) {
+					return nil
+				}
+				index++
+			}
+		}
+	}
+}
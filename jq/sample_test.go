@@ -0,0 +1,123 @@
+package jq
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSampleMethod(t *testing.T) {
+	for _, method := range SampleMethods {
+		parsed, err := ParseSampleMethod(string(method))
+		require.NoError(t, err)
+		assert.Equal(t, method, parsed)
+	}
+
+	parsed, err := ParseSampleMethod("")
+	require.NoError(t, err)
+	assert.Equal(t, SampleHead, parsed)
+
+	_, err = ParseSampleMethod("stratified")
+	assert.EqualError(t, err, `unsupported sample method "stratified"; use one of head, tail, random, reservoir`)
+}
+
+// recordIDs returns the id of each sampled record
+func recordIDs(sample *Sample) []interface{} {
+	ids := make([]interface{}, len(sample.Records))
+	for i, record := range sample.Records {
+		ids[i] = record.Record.(map[string]interface{})["id"]
+	}
+	return ids
+}
+
+func TestSampleRecords(t *testing.T) {
+	tempDir := t.TempDir()
+
+	// Two files of 50 orders each, ids 0-99, and a JSON Lines file of events
+	for file := 0; file < 2; file++ {
+		var orders []string
+		for i := 0; i < 50; i++ {
+			orders = append(orders, fmt.Sprintf(`{"id": %d, "note": "%s"}`, file*50+i, strings.Repeat("x", 300)))
+		}
+		content := `{"orders": [` + strings.Join(orders, ",") + `]}`
+		require.NoError(t, os.WriteFile(filepath.Join(tempDir, fmt.Sprintf("orders-%d.json", file)), []byte(content), 0644))
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "events.jsonl"), []byte("{\"id\": \"a\"}\n{\"id\": \"b\"}\n{\"id\": \"c\"}\n"), 0644))
+
+	ctx := context.Background()
+	patterns := []string{"orders-*.json"}
+
+	sample, err := SampleRecords(ctx, patterns, tempDir, SampleOptions{Path: ".orders", Count: 3, MaxStringLength: 10}, Options{})
+	require.NoError(t, err)
+	assert.Equal(t, SampleHead, sample.Method)
+	assert.Nil(t, sample.Total)
+	assert.Nil(t, sample.Seed)
+	assert.Equal(t, []interface{}{0.0, 1.0, 2.0}, recordIDs(sample))
+	assert.Equal(t, SampleRecord{File: "orders-0.json", Index: 1, Record: map[string]interface{}{"id": 1.0, "note": "xxxxxxxxxx…"}}, sample.Records[1])
+
+	sample, err = SampleRecords(ctx, patterns, tempDir, SampleOptions{Path: ".orders", Count: 3, Method: SampleTail}, Options{})
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{97.0, 98.0, 99.0}, recordIDs(sample))
+	assert.Equal(t, "orders-1.json", sample.Records[0].File)
+	assert.Equal(t, 47, sample.Records[0].Index)
+	assert.Equal(t, 100, *sample.Total)
+	assert.Len(t, sample.Records[0].Record.(map[string]interface{})["note"], 300)
+
+	for _, method := range []SampleMethod{SampleRandom, SampleReservoir} {
+		t.Run(string(method), func(t *testing.T) {
+			options := SampleOptions{Path: ".orders", Count: 10, Method: method, Seed: 42}
+			sample, err := SampleRecords(ctx, patterns, tempDir, options, Options{Workers: 4})
+			require.NoError(t, err)
+			assert.Equal(t, 100, *sample.Total)
+			assert.Equal(t, int64(42), *sample.Seed)
+			require.Len(t, sample.Records, 10)
+
+			// Records are distinct and in file order
+			ids := recordIDs(sample)
+			for i := 1; i < len(ids); i++ {
+				assert.Less(t, ids[i-1].(float64), ids[i].(float64))
+			}
+
+			// The same seed draws the same sample, another seed a different one
+			again, err := SampleRecords(ctx, patterns, tempDir, options, Options{})
+			require.NoError(t, err)
+			assert.Equal(t, ids, recordIDs(again))
+			options.Seed = 7
+			other, err := SampleRecords(ctx, patterns, tempDir, options, Options{})
+			require.NoError(t, err)
+			assert.NotEqual(t, ids, recordIDs(other))
+
+			// Asking for more records than there are returns them all
+			options.Count = 200
+			all, err := SampleRecords(ctx, patterns, tempDir, options, Options{})
+			require.NoError(t, err)
+			assert.Len(t, all.Records, 100)
+		})
+	}
+
+	// Without a path each JSON Lines record is a record
+	sample, err = SampleRecords(ctx, []string{"events.jsonl"}, tempDir, SampleOptions{Count: 2, Method: SampleTail}, Options{})
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{"b", "c"}, recordIDs(sample))
+	assert.Equal(t, 2, sample.Records[1].Index)
+
+	_, err = SampleRecords(ctx, patterns, tempDir, SampleOptions{Path: ".orders", Count: 0}, Options{})
+	assert.EqualError(t, err, "sample count must be between 1 and 1000")
+
+	_, err = SampleRecords(ctx, patterns, tempDir, SampleOptions{Path: ".orders[", Count: 1}, Options{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid jq filter")
+
+	_, err = SampleRecords(ctx, patterns, tempDir, SampleOptions{Path: ".orders.id", Count: 1}, Options{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "error evaluating path .orders.id")
+
+	_, err = SampleRecords(ctx, []string{"../*.json"}, tempDir, SampleOptions{Count: 1}, Options{})
+	assert.Error(t, err)
+}
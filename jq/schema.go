@@ -0,0 +1,369 @@
+package jq
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/itchyny/gojq"
+)
+
+// JSONSchemaDraft is the dialect of the documents Schema.JSONSchema produces
+const JSONSchemaDraft = "https://json-schema.org/draft/2020-12/schema"
+
+const (
+	// maxSchemaFields bounds the paths a schema describes, so objects keyed by ids do not
+	// produce a path for every id
+	maxSchemaFields = 1000
+	// maxSchemaExamples is the number of distinct example values kept for each path
+	maxSchemaExamples = 3
+	// maxExampleLength is the number of bytes example strings are cut to
+	maxExampleLength = 80
+)
+
+// schemaTypes lists jq's type names in the order schemas report them
+var schemaTypes = []string{"null", "boolean", "number", "string", "array", "object"}
+
+// Schema is the structure shared by the documents of one or more files
+type Schema struct {
+	// Files are the files read, named as input_filename names them
+	Files []string `json:"files"`
+	// Documents counts the documents read; each JSON Lines record is a document
+	Documents int           `json:"documents"`
+	Fields    []SchemaField `json:"fields"`
+	// Truncated is set when the documents have more paths than a schema describes
+	Truncated bool `json:"truncated,omitempty"`
+
+	root *schemaNode
+}
+
+// SchemaField describes the values found at one path, written as a jq path such as
+// .users[].name
+type SchemaField struct {
+	Path string `json:"path"`
+	// Types counts the values seen of each jq type
+	Types map[string]int `json:"types"`
+	// Optional is set when some of the objects holding the field do not have it
+	Optional bool `json:"optional,omitempty"`
+	Nullable bool `json:"nullable,omitempty"`
+	// ArrayLength is the range of lengths of the arrays seen
+	ArrayLength *LengthRange  `json:"array_length,omitempty"`
+	Examples    []interface{} `json:"examples,omitempty"`
+}
+
+// LengthRange is the shortest and longest length seen
+type LengthRange struct {
+	Min int `json:"min"`
+	Max int `json:"max"`
+}
+
+// schemaNode collects the values seen at one path
+type schemaNode struct {
+	types map[string]int
+	// fractional is set once a number that is not an integer is seen
+	fractional bool
+	lengths    *LengthRange
+	examples   []interface{}
+	fields     map[string]*schemaNode
+	items      *schemaNode
+}
+
+// schemaBuilder merges documents into a tree of schema nodes
+type schemaBuilder struct {
+	root      *schemaNode
+	nodes     int
+	truncated bool
+}
+
+func newSchemaBuilder() *schemaBuilder {
+	return &schemaBuilder{root: &schemaNode{types: make(map[string]int)}, nodes: 1}
+}
+
+// newNode returns a node for a new path, or nil once the schema has as many as it describes
+func (b *schemaBuilder) newNode() *schemaNode {
+	if b.nodes >= maxSchemaFields {
+		b.truncated = true
+		return nil
+	}
+	b.nodes++
+	return &schemaNode{types: make(map[string]int)}
+}
+
+// add records value as seen at node's path
+func (b *schemaBuilder) add(node *schemaNode, value interface{}) {
+	node.types[gojq.TypeOf(value)]++
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if node.fields == nil {
+			node.fields = make(map[string]*schemaNode)
+		}
+		for key, element := range v {
+			child, ok := node.fields[key]
+			if !ok {
+				if child = b.newNode(); child == nil {
+					continue
+				}
+				node.fields[key] = child
+			}
+			b.add(child, element)
+		}
+	case []interface{}:
+		if node.lengths == nil {
+			node.lengths = &LengthRange{Min: len(v), Max: len(v)}
+		}
+		node.lengths.Min = min(node.lengths.Min, len(v))
+		node.lengths.Max = max(node.lengths.Max, len(v))
+		if len(v) > 0 && node.items == nil {
+			node.items = b.newNode()
+		}
+		if node.items != nil {
+			for _, element := range v {
+				b.add(node.items, element)
+			}
+		}
+	case nil:
+	default:
+		if f, ok := v.(float64); ok && f != math.Trunc(f) {
+			node.fractional = true
+		}
+		node.addExample(v)
+	}
+}
+
+// addExample keeps value as an example unless the node has enough or has seen it already
+func (n *schemaNode) addExample(value interface{}) {
+	if len(n.examples) >= maxSchemaExamples {
+		return
+	}
+	value = truncateStrings(value, maxExampleLength)
+	for _, example := range n.examples {
+		if gojq.Compare(example, value) == 0 {
+			return
+		}
+	}
+	n.examples = append(n.examples, value)
+}
+
+// truncateStrings returns value with every string longer than maxLength bytes cut short and
+// marked with an ellipsis; a maxLength of zero keeps strings whole
+func truncateStrings(value interface{}, maxLength int) interface{} {
+	switch v := value.(type) {
+	case string:
+		if maxLength <= 0 || len(v) <= maxLength {
+			return v
+		}
+		// Cut before a UTF-8 continuation byte so the string stays valid
+		n := maxLength
+		for n > 0 && v[n]&0xC0 == 0x80 {
+			n--
+		}
+		return v[:n] + "…"
+	case []interface{}:
+		values := make([]interface{}, len(v))
+		for i, element := range v {
+			values[i] = truncateStrings(element, maxLength)
+		}
+		return values
+	case map[string]interface{}:
+		object := make(map[string]interface{}, len(v))
+		for key, element := range v {
+			object[key] = truncateStrings(element, maxLength)
+		}
+		return object
+	}
+	return value
+}
+
+// schema lists the paths collected, parents before their fields and fields in key order
+func (b *schemaBuilder) schema() *Schema {
+	schema := &Schema{Fields: []SchemaField{}, Truncated: b.truncated, root: b.root}
+	if len(b.root.types) > 0 {
+		schema.Fields = b.root.appendFields(schema.Fields, ".", false)
+	}
+	return schema
+}
+
+func (n *schemaNode) appendFields(fields []SchemaField, path string, optional bool) []SchemaField {
+	field := SchemaField{
+		Path:        path,
+		Types:       n.types,
+		Optional:    optional,
+		Nullable:    n.types["null"] > 0,
+		ArrayLength: n.lengths,
+		Examples:    n.examples,
+	}
+	fields = append(fields, field)
+
+	for _, key := range sortedKeys(n.fields) {
+		child := n.fields[key]
+		fields = child.appendFields(fields, fieldPath(path, key), child.count() < n.types["object"])
+	}
+	if n.items != nil {
+		itemsPath := path + "[]"
+		if path == "." {
+			itemsPath = ".[]"
+		}
+		fields = n.items.appendFields(fields, itemsPath, false)
+	}
+	return fields
+}
+
+// count returns the number of values seen at the node's path
+func (n *schemaNode) count() int {
+	total := 0
+	for _, count := range n.types {
+		total += count
+	}
+	return total
+}
+
+// fieldPath appends key to a jq path, quoting keys that are not identifiers
+func fieldPath(path, key string) string {
+	if path == "." {
+		path = ""
+	}
+	if variableNamePattern.MatchString(key) {
+		return path + "." + key
+	}
+	quoted, _ := json.Marshal(key)
+	if path == "" {
+		return ".[" + string(quoted) + "]"
+	}
+	return path + "[" + string(quoted) + "]"
+}
+
+// sortedKeys returns the keys of a map in order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// JSONSchema returns a JSON Schema draft 2020-12 document every document read satisfies.
+// Properties are required when every object seen has them; numbers are integers when no
+// fractional number was seen.
+func (s *Schema) JSONSchema() map[string]interface{} {
+	schema := s.root.jsonSchema()
+	schema["$schema"] = JSONSchemaDraft
+	return schema
+}
+
+func (n *schemaNode) jsonSchema() map[string]interface{} {
+	schema := make(map[string]interface{})
+
+	var types []interface{}
+	for _, name := range schemaTypes {
+		if n.types[name] == 0 {
+			continue
+		}
+		if name == "number" && !n.fractional {
+			name = "integer"
+		}
+		types = append(types, name)
+	}
+	switch len(types) {
+	case 0:
+	case 1:
+		schema["type"] = types[0]
+	default:
+		schema["type"] = types
+	}
+
+	if len(n.fields) > 0 {
+		properties := make(map[string]interface{}, len(n.fields))
+		var required []interface{}
+		for _, key := range sortedKeys(n.fields) {
+			child := n.fields[key]
+			properties[key] = child.jsonSchema()
+			if child.count() == n.types["object"] {
+				required = append(required, key)
+			}
+		}
+		schema["properties"] = properties
+		if len(required) > 0 {
+			schema["required"] = required
+		}
+	}
+	if n.items != nil {
+		schema["items"] = n.items.jsonSchema()
+	}
+	return schema
+}
+
+// InferSchema reads the documents of the files patterns match, relative to dataPath, and
+// merges their structure into one schema. It honours the limits and cache in opts.
+func InferSchema(ctx context.Context, patterns []string, dataPath string, opts Options) (*Schema, error) {
+	filePaths, box, err := resolvePatterns(patterns, dataPath, opts.Symlinks)
+	if err != nil {
+		return nil, err
+	}
+	if opts.BaseDir == "" {
+		opts.BaseDir = box.Root()
+	}
+	return inferFileSchema(ctx, filePaths, opts)
+}
+
+// interrupted returns the error reported when reading files stops because ctx ended
+func interrupted(err error, opts Options) error {
+	if !errors.Is(err, context.DeadlineExceeded) {
+		return ErrQueryCancelled
+	}
+	if opts.Timeout > 0 {
+		return fmt.Errorf("%w after %s; narrow the files the patterns match", ErrQueryTimeout, opts.Timeout)
+	}
+	return ErrQueryTimeout
+}
+
+// inferFileSchema merges the structure of every document of filePaths
+func inferFileSchema(ctx context.Context, filePaths []string, opts Options) (*Schema, error) {
+	if err := checkFiles(filePaths); err != nil {
+		return nil, err
+	}
+	files, err := newQueryFiles(filePaths, opts.BaseDir)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+
+	opts.leases = opts.Cache.leases()
+	defer opts.leases.release()
+	iter := newInputIter(filePaths, newMemoryGuard(opts.MemoryLimit), opts)
+	defer iter.Close()
+
+	builder := newSchemaBuilder()
+	documents := 0
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, interrupted(err, opts)
+		}
+		v, ok := iter.Next()
+		if !ok {
+			break
+		}
+		if err, ok := v.(error); ok {
+			return nil, err
+		}
+		builder.add(builder.root, v)
+		documents++
+	}
+
+	schema := builder.schema()
+	schema.Documents = documents
+	schema.Files = make([]string, len(filePaths))
+	for i, filePath := range filePaths {
+		schema.Files[i] = files.name(filePath)
+	}
+	return schema, nil
+}
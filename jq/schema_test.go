@@ -0,0 +1,120 @@
+package jq
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInferSchema(t *testing.T) {
+	tempDir := t.TempDir()
+	files := map[string]string{
+		"users/2025-01.json":   `{"users": [{"name": "Alice", "age": 30, "tags": ["admin"]}, {"name": "Bob", "age": 35.5, "email": null, "tags": []}]}`,
+		"users/2025-02.json":   `{"users": [{"name": "Alice", "age": 31, "email": "carol@example.com", "tags": ["a", "b", "c"]}], "note": "late"}`,
+		"events/events.jsonl":  "{\"level\": \"error\", \"first name\": \"Ann\"}\n{\"level\": \"info\"}\n",
+		"events/settings.yaml": "level: warn\n",
+	}
+	for name, content := range files {
+		fullPath := filepath.Join(tempDir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(fullPath), 0755))
+		require.NoError(t, os.WriteFile(fullPath, []byte(content), 0644))
+	}
+
+	schema, err := InferSchema(context.Background(), []string{"users/*.json"}, tempDir, Options{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"users/2025-01.json", "users/2025-02.json"}, schema.Files)
+	assert.Equal(t, 2, schema.Documents)
+	assert.False(t, schema.Truncated)
+
+	fields := make(map[string]SchemaField)
+	var paths []string
+	for _, field := range schema.Fields {
+		fields[field.Path] = field
+		paths = append(paths, field.Path)
+	}
+	assert.Equal(t, []string{".", ".note", ".users", ".users[]", ".users[].age", ".users[].email", ".users[].name", ".users[].tags", ".users[].tags[]"}, paths)
+
+	assert.Equal(t, map[string]int{"object": 2}, fields["."].Types)
+	assert.True(t, fields[".note"].Optional)
+	assert.Equal(t, &LengthRange{Min: 1, Max: 2}, fields[".users"].ArrayLength)
+	assert.Equal(t, []interface{}{30.0, 35.5, 31.0}, fields[".users[].age"].Examples)
+	assert.Equal(t, map[string]int{"null": 1, "string": 1}, fields[".users[].email"].Types)
+	assert.True(t, fields[".users[].email"].Optional)
+	assert.True(t, fields[".users[].email"].Nullable)
+	assert.False(t, fields[".users[].name"].Optional)
+	assert.Equal(t, []interface{}{"Alice", "Bob"}, fields[".users[].name"].Examples)
+	assert.Equal(t, &LengthRange{Min: 0, Max: 3}, fields[".users[].tags"].ArrayLength)
+	assert.Equal(t, map[string]int{"string": 4}, fields[".users[].tags[]"].Types)
+
+	expected := `{
+		"$schema": "https://json-schema.org/draft/2020-12/schema",
+		"type": "object",
+		"properties": {
+			"note": {"type": "string"},
+			"users": {
+				"type": "array",
+				"items": {
+					"type": "object",
+					"properties": {
+						"age": {"type": "number"},
+						"email": {"type": ["null", "string"]},
+						"name": {"type": "string"},
+						"tags": {"type": "array", "items": {"type": "string"}}
+					},
+					"required": ["age", "name", "tags"]
+				}
+			}
+		},
+		"required": ["users"]
+	}`
+	actual, err := json.Marshal(schema.JSONSchema())
+	require.NoError(t, err)
+	assert.JSONEq(t, expected, string(actual))
+
+	// JSON Lines records and YAML documents are documents of their own
+	schema, err = InferSchema(context.Background(), []string{"events/*"}, tempDir, Options{})
+	require.NoError(t, err)
+	assert.Equal(t, 3, schema.Documents)
+	require.Len(t, schema.Fields, 3)
+	assert.Equal(t, `.["first name"]`, schema.Fields[1].Path)
+	assert.True(t, schema.Fields[1].Optional)
+	assert.Equal(t, ".level", schema.Fields[2].Path)
+	assert.Equal(t, []interface{}{"error", "info", "warn"}, schema.Fields[2].Examples)
+
+	_, err = InferSchema(context.Background(), []string{"../*.json"}, tempDir, Options{})
+	assert.Error(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = InferSchema(ctx, []string{"users/*.json"}, tempDir, Options{})
+	assert.ErrorIs(t, err, ErrQueryCancelled)
+}
+
+func TestInferSchema_Limits(t *testing.T) {
+	tempDir := t.TempDir()
+
+	// Objects keyed by ids stop adding paths once the schema has as many as it describes
+	byID := make(map[string]interface{})
+	for i := 0; i < 2*maxSchemaFields; i++ {
+		byID[fmt.Sprintf("id%d", i)] = i
+	}
+	data, err := json.Marshal(map[string]interface{}{"by_id": byID})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "b.json"), data, 0644))
+	data, err = json.Marshal(map[string]interface{}{"bio": strings.Repeat("é", maxExampleLength)})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "a.json"), data, 0644))
+
+	schema, err := InferSchema(context.Background(), []string{"*.json"}, tempDir, Options{})
+	require.NoError(t, err)
+	assert.True(t, schema.Truncated)
+	assert.Len(t, schema.Fields, maxSchemaFields)
+	assert.Equal(t, []interface{}{strings.Repeat("é", maxExampleLength/2) + "…"}, schema.Fields[1].Examples)
+}
@@ -0,0 +1,186 @@
+package jq
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// streamFrame is an array or object being decoded by streamIter
+type streamFrame struct {
+	array     bool
+	index     int
+	key       string
+	expectKey bool
+}
+
+// streamIter decodes files token by token and yields jq --stream events: [path, leaf] for
+// every scalar or empty container, and [path] when an array or object closes. Only the
+// current path is held in memory, so files larger than available memory can be queried.
+type streamIter struct {
+	paths   []string
+	next    int
+	path    string
+	file    io.ReadCloser
+	decoder *json.Decoder
+	frames  []streamFrame
+	guard   *memoryGuard
+}
+
+// newStreamIter creates an iterator over the stream events of filePaths, in order
+func newStreamIter(filePaths []string, guard *memoryGuard) *streamIter {
+	return &streamIter{paths: filePaths, guard: guard}
+}
+
+// Next returns the next event, or an error value if a file is not valid JSON
+func (it *streamIter) Next() (interface{}, bool) {
+	for {
+		if it.decoder == nil {
+			if it.next >= len(it.paths) {
+				return nil, false
+			}
+			if err := it.open(it.paths[it.next]); err != nil {
+				it.next++
+				return err, true
+			}
+			it.next++
+		}
+
+		event, err := it.decode()
+		if err == io.EOF {
+			it.Close()
+			continue
+		}
+		if err != nil {
+			it.Close()
+			return fmt.Errorf("file %s does not contain valid JSON: %w", it.path, err), true
+		}
+		return event, true
+	}
+}
+
+// currentFile returns the path of the file the last event came from
+func (it *streamIter) currentFile() string {
+	return it.path
+}
+
+// open starts decoding filePath. Files in formats other than JSON are decoded whole and
+// re-encoded as JSON, so only JSON and JSON Lines files are streamed without loading them.
+func (it *streamIter) open(filePath string) error {
+	if format := formatOf(filePath); format.Name != jsonFormat.Name && !IsJSONLinesFile(filePath) {
+		if err := it.guard.checkFileSize(filePath); err != nil {
+			return err
+		}
+		values, err := readFile(filePath, it.guard)
+		if err != nil {
+			return err
+		}
+		var buf bytes.Buffer
+		encoder := json.NewEncoder(&buf)
+		for _, value := range values {
+			if err := encoder.Encode(value); err != nil {
+				return fmt.Errorf("file %s could not be converted to JSON: %w", filePath, err)
+			}
+		}
+		it.path = filePath
+		it.decoder = json.NewDecoder(&buf)
+		it.frames = it.frames[:0]
+		return nil
+	}
+
+	file, err := OpenFile(filePath)
+	if err != nil {
+		return fmt.Errorf("file %s is not readable: %w", filePath, err)
+	}
+	it.path = filePath
+	it.file = file
+	it.decoder = json.NewDecoder(bufio.NewReader(file))
+	it.frames = it.frames[:0]
+	return nil
+}
+
+// decode reads tokens until the next event is complete
+func (it *streamIter) decode() (interface{}, error) {
+	for {
+		token, err := it.decoder.Token()
+		if err != nil {
+			if err == io.EOF && len(it.frames) > 0 {
+				return nil, io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+
+		switch token {
+		case json.Delim('{'), json.Delim('['):
+			it.beginValue()
+			if !it.decoder.More() {
+				// Empty containers are leaves
+				if _, err := it.decoder.Token(); err != nil {
+					return nil, err
+				}
+				if token == json.Delim('{') {
+					return []interface{}{it.currentPath(), map[string]interface{}{}}, nil
+				}
+				return []interface{}{it.currentPath(), []interface{}{}}, nil
+			}
+			it.frames = append(it.frames, streamFrame{
+				array:     token == json.Delim('['),
+				index:     -1,
+				expectKey: token == json.Delim('{'),
+			})
+
+		case json.Delim('}'), json.Delim(']'):
+			// The closing event carries the path of the container's last element
+			path := it.currentPath()
+			it.frames = it.frames[:len(it.frames)-1]
+			return []interface{}{path}, nil
+
+		default:
+			if n := len(it.frames); n > 0 && it.frames[n-1].expectKey {
+				it.frames[n-1].key = token.(string)
+				it.frames[n-1].expectKey = false
+				continue
+			}
+			it.beginValue()
+			return []interface{}{it.currentPath(), token}, nil
+		}
+	}
+}
+
+// beginValue advances the enclosing container to the value about to be decoded
+func (it *streamIter) beginValue() {
+	if n := len(it.frames); n > 0 {
+		frame := &it.frames[n-1]
+		if frame.array {
+			frame.index++
+		} else {
+			frame.expectKey = true
+		}
+	}
+}
+
+// currentPath returns the path of the value being decoded
+func (it *streamIter) currentPath() []interface{} {
+	path := make([]interface{}, len(it.frames))
+	for i, frame := range it.frames {
+		if frame.array {
+			path[i] = frame.index
+		} else {
+			path[i] = frame.key
+		}
+	}
+	return path
+}
+
+// Close releases the file being decoded
+func (it *streamIter) Close() error {
+	it.decoder = nil
+	if it.file == nil {
+		return nil
+	}
+	err := it.file.Close()
+	it.file = nil
+	return err
+}
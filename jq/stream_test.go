@@ -0,0 +1,120 @@
+package jq
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStreamMatchesToStream(t *testing.T) {
+	documents := []string{
+		`{"a": 1, "b": [2, {"c": null}], "e": {}, "f": []}`,
+		`[[1, [2]], {"x": {"y": "z"}}, true]`,
+		`"scalar"`,
+		`[]`,
+		`{}`,
+	}
+
+	tempDir := t.TempDir()
+	for i, document := range documents {
+		t.Run(document, func(t *testing.T) {
+			filePath := filepath.Join(tempDir, fmt.Sprintf("doc%d.json", i))
+			require.NoError(t, os.WriteFile(filePath, []byte(document), 0644))
+
+			var parsed interface{}
+			require.NoError(t, json.Unmarshal([]byte(document), &parsed))
+			expected, err := ExecuteJQ("[tostream]", parsed)
+			require.NoError(t, err)
+
+			result, err := ExecuteJQFilesWithOptions(context.Background(), "[inputs]", []string{filePath}, Options{Stream: true})
+			require.NoError(t, err)
+			assert.Equal(t, expected, result.Output)
+		})
+	}
+}
+
+func TestExecuteJQFilesWithOptions_Stream(t *testing.T) {
+	tempDir := t.TempDir()
+
+	arrayPath := filepath.Join(tempDir, "records.json")
+	require.NoError(t, os.WriteFile(arrayPath, []byte(`[{"id": 1}, {"id": 2}, {"id": 3}]`), 0644))
+
+	linesPath := filepath.Join(tempDir, "records.jsonl")
+	require.NoError(t, os.WriteFile(linesPath, []byte("{\"id\": 4}\n{\"id\": 5}\n"), 0644))
+
+	invalidPath := filepath.Join(tempDir, "invalid.json")
+	require.NoError(t, os.WriteFile(invalidPath, []byte(`[{"id": 1}, {"id": `), 0644))
+
+	tests := []struct {
+		name      string
+		filter    string
+		paths     []string
+		expected  string
+		expectErr string
+	}{
+		{
+			name:     "rebuild top-level array elements",
+			filter:   "[fromstream(1 | truncate_stream(inputs)) | .id]",
+			paths:    []string{arrayPath},
+			expected: "[\n  1,\n  2,\n  3\n]",
+		},
+		{
+			name:     "events of every file in order",
+			filter:   "[inputs | select(length == 2) | .[1]]",
+			paths:    []string{arrayPath, linesPath},
+			expected: "[\n  1,\n  2,\n  3,\n  4,\n  5\n]",
+		},
+		{
+			name:      "truncated document",
+			filter:    "[inputs]",
+			paths:     []string{invalidPath},
+			expectErr: "does not contain valid JSON",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := ExecuteJQFilesWithOptions(context.Background(), tt.filter, tt.paths, Options{Stream: true})
+			if tt.expectErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.expectErr)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, tt.expected, result.Output)
+			}
+		})
+	}
+}
+
+func TestExecuteJQFilesWithOptions_MemoryLimit(t *testing.T) {
+	tempDir := t.TempDir()
+
+	records := make([]string, 20000)
+	for i := range records {
+		records[i] = fmt.Sprintf(`{"id": %d, "name": "record-%d"}`, i, i)
+	}
+	filePath := filepath.Join(tempDir, "large.json")
+	require.NoError(t, os.WriteFile(filePath, []byte("["+strings.Join(records, ",")+"]"), 0644))
+
+	// Whole documents larger than the limit are refused before they are read
+	_, err := ExecuteJQFilesWithOptions(context.Background(), "length", []string{filePath}, Options{MemoryLimit: 1024})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "use stream mode")
+
+	// Streaming with a small footprint stays within the limit
+	result, err := ExecuteJQFilesWithOptions(context.Background(), "reduce inputs as $e (0; . + 1)", []string{filePath}, Options{Stream: true, MemoryLimit: 64 << 20})
+	require.NoError(t, err)
+	assert.Equal(t, "60001", result.Output) // two leaves and a closing event per record, then the array close
+
+	// Events are not counted, even when the filter keeps them; the timeout bounds such filters
+	result, err = ExecuteJQFilesWithOptions(context.Background(), "[inputs] | length", []string{filePath}, Options{Stream: true, MemoryLimit: 64 << 10})
+	require.NoError(t, err)
+	assert.Equal(t, "60001", result.Output)
+}
@@ -0,0 +1,322 @@
+package jq
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"path/filepath"
+	"sort"
+
+	"github.com/berrydev-ai/gojq-mcp/sandbox"
+	"github.com/santhosh-tekuri/jsonschema/v6"
+)
+
+// maxViolations is the number of schema violations reported for each file
+const maxViolations = 100
+
+// inlineSchemaName is where an inline schema is placed in the data directory, so relative
+// $ref values in it name files there
+const inlineSchemaName = "inline-schema.json"
+
+// JSONSchema is a compiled JSON Schema. Schemas without "$schema" are read as draft 2020-12,
+// and "format" keywords are checked.
+type JSONSchema struct {
+	// path is the schema file, or empty for an inline schema
+	path   string
+	schema *jsonschema.Schema
+}
+
+// SchemaSource is a JSON Schema given by path, relative to the data directory, or inline
+type SchemaSource struct {
+	Path     string
+	Document interface{}
+}
+
+// ValidationReport lists how the files a query matched conform to a JSON Schema
+type ValidationReport struct {
+	Valid bool             `json:"valid"`
+	Files []FileValidation `json:"files"`
+}
+
+// FileValidation is the outcome of validating the documents of one file
+type FileValidation struct {
+	File      string `json:"file"`
+	Valid     bool   `json:"valid"`
+	Documents int    `json:"documents"`
+	// ReadError is set when the file cannot be read or decoded; documents before the error
+	// are still validated
+	ReadError string            `json:"read_error,omitempty"`
+	Errors    []SchemaViolation `json:"errors,omitempty"`
+	// Truncated is set when the file has more violations than are reported
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// SchemaViolation is a value that does not satisfy a schema keyword
+type SchemaViolation struct {
+	// Document is the index of the document the value is in, for files holding several
+	Document *int `json:"document,omitempty"`
+	// Location is a JSON Pointer to the value within its document; "" is the document itself
+	Location string `json:"location"`
+	// Keyword is a JSON Pointer to the failing keyword within the schema
+	Keyword string `json:"keyword"`
+	Message string `json:"message"`
+}
+
+// schemaLoader reads the files schemas refer to with $ref, keeping them inside box when it
+// is not nil
+type schemaLoader struct {
+	box *sandbox.Sandbox
+}
+
+func (l schemaLoader) Load(url string) (any, error) {
+	path, err := jsonschema.FileLoader{}.ToFile(url)
+	if err != nil {
+		return nil, fmt.Errorf("schema %s cannot be loaded; only files can be referenced", url)
+	}
+	if l.box != nil {
+		if err := l.box.Check(path); err != nil {
+			return nil, err
+		}
+	}
+	values, err := readFile(path, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(values) != 1 {
+		return nil, fmt.Errorf("schema file %s must hold exactly one document", path)
+	}
+	return schemaValue(values[0]), nil
+}
+
+// newSchemaCompiler returns a compiler reading referenced schemas through box. Drafts from
+// 2019-09 on treat "format" as an annotation; it is asserted so a malformed email or date
+// fails validation as it does with earlier drafts.
+func newSchemaCompiler(box *sandbox.Sandbox) *jsonschema.Compiler {
+	compiler := jsonschema.NewCompiler()
+	compiler.DefaultDraft(jsonschema.Draft2020)
+	compiler.AssertFormat()
+	compiler.UseLoader(schemaLoader{box: box})
+	return compiler
+}
+
+// LoadJSONSchema compiles the JSON or YAML schema file at schemaPath. When box is not nil,
+// the schema and every file it refers to must be inside it.
+func LoadJSONSchema(schemaPath string, box *sandbox.Sandbox) (*JSONSchema, error) {
+	absPath, err := filepath.Abs(schemaPath)
+	if err != nil {
+		return nil, fmt.Errorf("error resolving path %s: %w", schemaPath, err)
+	}
+	if box != nil {
+		if err := box.Check(absPath); err != nil {
+			return nil, err
+		}
+	}
+
+	schema, err := newSchemaCompiler(box).Compile(absPath)
+	if err != nil {
+		return nil, fmt.Errorf("invalid JSON schema %s: %w", schemaPath, err)
+	}
+	return &JSONSchema{path: absPath, schema: schema}, nil
+}
+
+// CompileJSONSchema compiles a schema document. Relative $ref values name files in the
+// sandbox's root, and the schema cannot refer to files when box is nil.
+func CompileJSONSchema(document interface{}, box *sandbox.Sandbox) (*JSONSchema, error) {
+	location := "urn:gojq-mcp:" + inlineSchemaName
+	if box != nil {
+		location = filepath.Join(box.Root(), inlineSchemaName)
+	}
+
+	compiler := newSchemaCompiler(box)
+	if err := compiler.AddResource(location, schemaValue(document)); err != nil {
+		return nil, fmt.Errorf("invalid JSON schema: %w", err)
+	}
+	schema, err := compiler.Compile(location)
+	if err != nil {
+		return nil, fmt.Errorf("invalid JSON schema: %w", err)
+	}
+	return &JSONSchema{schema: schema}, nil
+}
+
+// ValidateJSONSchema validates the files patterns match, relative to dataPath, against a
+// schema inside the data directory or given inline. A schema file matched by the patterns
+// is not validated against itself.
+func ValidateJSONSchema(ctx context.Context, patterns []string, dataPath string, source SchemaSource, opts Options) (*ValidationReport, error) {
+	if (source.Path == "") == (source.Document == nil) {
+		return nil, fmt.Errorf("give either a schema file or an inline schema")
+	}
+
+	filePaths, box, err := resolvePatterns(patterns, dataPath, opts.Symlinks)
+	if err != nil {
+		return nil, err
+	}
+	if opts.BaseDir == "" {
+		opts.BaseDir = box.Root()
+	}
+
+	var schema *JSONSchema
+	if source.Path != "" {
+		schemaPath, err := box.Pattern(source.Path)
+		if err != nil {
+			return nil, err
+		}
+		schema, err = LoadJSONSchema(schemaPath, box)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		schema, err = CompileJSONSchema(source.Document, box)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return ValidateJSONFiles(ctx, filePaths, schema, opts)
+}
+
+// ValidateJSONFiles validates every document of filePaths against schema. Files that cannot
+// be read or decoded are reported as invalid rather than failing the whole validation.
+func ValidateJSONFiles(ctx context.Context, filePaths []string, schema *JSONSchema, opts Options) (*ValidationReport, error) {
+	if schema.path != "" {
+		var rest []string
+		for _, filePath := range filePaths {
+			if absPath, err := filepath.Abs(filePath); err != nil || absPath != schema.path {
+				rest = append(rest, filePath)
+			}
+		}
+		filePaths = rest
+	}
+	if len(filePaths) == 0 {
+		return nil, fmt.Errorf("no files found matching the provided patterns")
+	}
+	if err := checkFiles(filePaths); err != nil {
+		return nil, err
+	}
+	files, err := newQueryFiles(filePaths, opts.BaseDir)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+
+	guard := newMemoryGuard(opts.MemoryLimit)
+	opts.leases = opts.Cache.leases()
+	defer opts.leases.release()
+	report := &ValidationReport{Valid: true, Files: make([]FileValidation, 0, len(filePaths))}
+	for _, filePath := range filePaths {
+		result, err := schema.validateFile(ctx, filePath, guard, opts)
+		if err != nil {
+			return nil, err
+		}
+		result.File = files.name(filePath)
+		report.Valid = report.Valid && result.Valid
+		report.Files = append(report.Files, result)
+	}
+	return report, nil
+}
+
+// validateFile validates the documents of one file
+func (s *JSONSchema) validateFile(ctx context.Context, filePath string, guard *memoryGuard, opts Options) (FileValidation, error) {
+	result := FileValidation{}
+	iter := newInputIter([]string{filePath}, guard, opts)
+	defer iter.Close()
+
+	var documents []int
+	for {
+		if err := ctx.Err(); err != nil {
+			return result, interrupted(err, opts)
+		}
+		v, ok := iter.Next()
+		if !ok {
+			break
+		}
+		if err, ok := v.(error); ok {
+			result.ReadError = err.Error()
+			break
+		}
+
+		violations := s.violations(v)
+		for i := range violations {
+			if len(result.Errors) == maxViolations {
+				result.Truncated = true
+				break
+			}
+			result.Errors = append(result.Errors, violations[i])
+			documents = append(documents, result.Documents)
+		}
+		result.Documents++
+	}
+
+	// Documents are only numbered when the file can hold several
+	if result.Documents > 1 || IsJSONLinesFile(filePath) {
+		for i := range result.Errors {
+			result.Errors[i].Document = &documents[i]
+		}
+	}
+	result.Valid = result.ReadError == "" && len(result.Errors) == 0
+	return result, nil
+}
+
+// violations returns the ways value does not satisfy the schema, one for each failing
+// keyword that is not only failing because of the keywords below it
+func (s *JSONSchema) violations(value interface{}) []SchemaViolation {
+	err := s.schema.Validate(schemaValue(value))
+	if err == nil {
+		return nil
+	}
+	var validationErr *jsonschema.ValidationError
+	if !errors.As(err, &validationErr) {
+		return []SchemaViolation{{Message: err.Error()}}
+	}
+
+	var violations []SchemaViolation
+	var collect func(unit jsonschema.OutputUnit)
+	collect = func(unit jsonschema.OutputUnit) {
+		if len(unit.Errors) == 0 && unit.Error != nil {
+			violations = append(violations, SchemaViolation{
+				Location: unit.InstanceLocation,
+				Keyword:  unit.KeywordLocation,
+				Message:  unit.Error.String(),
+			})
+		}
+		for _, cause := range unit.Errors {
+			collect(cause)
+		}
+	}
+	collect(*validationErr.DetailedOutput())
+
+	// The validator visits object properties in no particular order
+	sort.SliceStable(violations, func(i, j int) bool {
+		if violations[i].Location != violations[j].Location {
+			return violations[i].Location < violations[j].Location
+		}
+		return violations[i].Keyword < violations[j].Keyword
+	})
+	return violations
+}
+
+// schemaValue converts the numbers decoders produce that the validator does not accept
+func schemaValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case *big.Int:
+		return json.Number(v.String())
+	case []interface{}:
+		values := make([]interface{}, len(v))
+		for i, element := range v {
+			values[i] = schemaValue(element)
+		}
+		return values
+	case map[string]interface{}:
+		object := make(map[string]interface{}, len(v))
+		for key, element := range v {
+			object[key] = schemaValue(element)
+		}
+		return object
+	}
+	return value
+}
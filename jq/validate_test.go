@@ -0,0 +1,146 @@
+package jq
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/berrydev-ai/gojq-mcp/sandbox"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateJSONSchema(t *testing.T) {
+	rootDir := t.TempDir()
+	tempDir := filepath.Join(rootDir, "data")
+	files := map[string]string{
+		"schemas/user.json":     `{"$schema": "https://json-schema.org/draft/2020-12/schema", "type": "object", "required": ["id", "name"], "properties": {"id": {"type": "integer"}, "name": {"$ref": "name.json"}, "tags": {"type": "array", "items": {"type": "string"}}}, "additionalProperties": false}`,
+		"schemas/name.json":     `{"type": "string", "minLength": 2}`,
+		"schemas/user.yaml":     "type: object\nrequired: [id]\n",
+		"schemas/remote.json":   `{"$ref": "https://example.com/user.json"}`,
+		"schemas/escape.json":   `{"$ref": "../../outside.json"}`,
+		"users/good.json":       `{"id": 1, "name": "Alice", "tags": ["admin"]}`,
+		"users/bad.json":        `{"id": "x", "name": "A", "tags": [1], "extra": true}`,
+		"users/broken.json":     `{"id": `,
+		"events/records.jsonl":  "{\"id\": 1, \"name\": \"Al\"}\n{\"name\": \"Bo\"}\n",
+		"events/settings.yaml":  "id: 1\nname: Al\n---\nid: 2\n",
+		"events/single.yaml":    "id: 3\n",
+		"../outside.json":       `{"type": "object"}`,
+		"schemas/not-json.json": `{`,
+		"schemas/contact.json":  `{"properties": {"email": {"type": "string", "format": "email"}}}`,
+		"contacts/good.json":    `{"email": "alice@example.com"}`,
+		"contacts/bad.json":     `{"email": "not-an-email"}`,
+	}
+	for name, content := range files {
+		fullPath := filepath.Join(tempDir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(fullPath), 0755))
+		require.NoError(t, os.WriteFile(fullPath, []byte(content), 0644))
+	}
+	ctx := context.Background()
+
+	report, err := ValidateJSONSchema(ctx, []string{"users/*.json"}, tempDir, SchemaSource{Path: "schemas/user.json"}, Options{})
+	require.NoError(t, err)
+	assert.False(t, report.Valid)
+	require.Len(t, report.Files, 3)
+
+	bad := report.Files[0]
+	assert.Equal(t, "users/bad.json", bad.File)
+	assert.False(t, bad.Valid)
+	assert.Equal(t, 1, bad.Documents)
+	assert.Equal(t, []SchemaViolation{
+		{Location: "", Keyword: "/additionalProperties", Message: "additional properties 'extra' not allowed"},
+		{Location: "/id", Keyword: "/properties/id/type", Message: "got string, want integer"},
+		{Location: "/name", Keyword: "/properties/name/$ref/minLength", Message: "minLength: got 1, want 2"},
+		{Location: "/tags/0", Keyword: "/properties/tags/items/type", Message: "got number, want string"},
+	}, bad.Errors)
+
+	broken := report.Files[1]
+	assert.Equal(t, "users/broken.json", broken.File)
+	assert.False(t, broken.Valid)
+	assert.Contains(t, broken.ReadError, "does not contain valid JSON")
+
+	assert.Equal(t, FileValidation{File: "users/good.json", Valid: true, Documents: 1}, report.Files[2])
+
+	// Documents of multi-document files are numbered; single documents are not
+	report, err = ValidateJSONSchema(ctx, []string{"events/*"}, tempDir, SchemaSource{Path: "schemas/user.json"}, Options{})
+	require.NoError(t, err)
+	require.Len(t, report.Files, 3)
+	require.Len(t, report.Files[0].Errors, 1)
+	assert.Equal(t, 1, *report.Files[0].Errors[0].Document)
+	assert.Equal(t, "/required", report.Files[0].Errors[0].Keyword)
+	assert.Equal(t, 2, report.Files[1].Documents)
+	require.Len(t, report.Files[1].Errors, 1)
+	assert.Equal(t, 1, *report.Files[1].Errors[0].Document)
+	require.Len(t, report.Files[2].Errors, 1)
+	assert.Nil(t, report.Files[2].Errors[0].Document)
+
+	// YAML schemas work too, and the schema file is not validated against itself
+	report, err = ValidateJSONSchema(ctx, []string{"schemas/user.yaml", "events/single.yaml"}, tempDir, SchemaSource{Path: "schemas/user.yaml"}, Options{})
+	require.NoError(t, err)
+	assert.True(t, report.Valid)
+	require.Len(t, report.Files, 1)
+	assert.Equal(t, "events/single.yaml", report.Files[0].File)
+
+	inline := map[string]interface{}{"type": "object", "properties": map[string]interface{}{"name": map[string]interface{}{"$ref": "schemas/name.json"}}}
+	report, err = ValidateJSONSchema(ctx, []string{"users/good.json"}, tempDir, SchemaSource{Document: inline}, Options{})
+	require.NoError(t, err)
+	assert.True(t, report.Valid)
+
+	// Formats are asserted, not only annotations
+	report, err = ValidateJSONSchema(ctx, []string{"contacts/*.json"}, tempDir, SchemaSource{Path: "schemas/contact.json"}, Options{})
+	require.NoError(t, err)
+	assert.False(t, report.Valid)
+	require.Len(t, report.Files, 2)
+	assert.Equal(t, "contacts/bad.json", report.Files[0].File)
+	assert.Equal(t, []SchemaViolation{
+		{Location: "/email", Keyword: "/properties/email/format", Message: "'not-an-email' is not valid email: missing @"},
+	}, report.Files[0].Errors)
+	assert.True(t, report.Files[1].Valid)
+
+	errorTests := []struct {
+		name     string
+		patterns []string
+		source   SchemaSource
+		expected string
+	}{
+		{"schema outside the data directory", []string{"users/*.json"}, SchemaSource{Path: "../outside.json"}, "access denied"},
+		{"reference outside the data directory", []string{"users/*.json"}, SchemaSource{Path: "schemas/escape.json"}, "access denied"},
+		{"remote reference", []string{"users/*.json"}, SchemaSource{Path: "schemas/remote.json"}, "only files can be referenced"},
+		{"invalid schema document", []string{"users/*.json"}, SchemaSource{Path: "schemas/not-json.json"}, "invalid JSON schema"},
+		{"invalid inline schema", []string{"users/*.json"}, SchemaSource{Document: map[string]interface{}{"type": 1}}, "invalid JSON schema"},
+		{"no schema", []string{"users/*.json"}, SchemaSource{}, "give either a schema file or an inline schema"},
+		{"only the schema matches", []string{"schemas/user.json"}, SchemaSource{Path: "schemas/user.json"}, "no files found"},
+	}
+	for _, tt := range errorTests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ValidateJSONSchema(ctx, tt.patterns, tempDir, tt.source, Options{})
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.expected)
+		})
+	}
+}
+
+func TestValidateJSONFiles_Limits(t *testing.T) {
+	tempDir := t.TempDir()
+	filePath := filepath.Join(tempDir, "numbers.json")
+	require.NoError(t, os.WriteFile(filePath, []byte("["+strings.TrimSuffix(strings.Repeat(`"x",`, 2*maxViolations), ",")+"]"), 0644))
+
+	box, err := sandbox.New(tempDir, sandbox.SymlinksWithinRoot)
+	require.NoError(t, err)
+	schema, err := CompileJSONSchema(map[string]interface{}{"items": map[string]interface{}{"type": "number"}}, box)
+	require.NoError(t, err)
+
+	report, err := ValidateJSONFiles(context.Background(), []string{filePath}, schema, Options{})
+	require.NoError(t, err)
+	assert.False(t, report.Valid)
+	assert.True(t, report.Files[0].Truncated)
+	assert.Len(t, report.Files[0].Errors, maxViolations)
+	assert.Equal(t, filePath, report.Files[0].File)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = ValidateJSONFiles(ctx, []string{filePath}, schema, Options{})
+	assert.ErrorIs(t, err, ErrQueryCancelled)
+}
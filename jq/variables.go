@@ -0,0 +1,37 @@
+package jq
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+)
+
+var variableNamePattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// reservedVariables are predefined by jq, or by this package for queries on files, and
+// cannot be rebound
+var reservedVariables = map[string]bool{"ENV": true, "__loc__": true, "__prog_args": true, "__file": true}
+
+// variableBindings validates variables and returns their jq names ($name) and values in a
+// stable order, ready for gojq.WithVariables and Code.Run
+func variableBindings(variables map[string]interface{}) ([]string, []interface{}, error) {
+	keys := make([]string, 0, len(variables))
+	for name := range variables {
+		if !variableNamePattern.MatchString(name) {
+			return nil, nil, fmt.Errorf("invalid variable name %q: use letters, digits and underscores, without the leading $", name)
+		}
+		if reservedVariables[name] {
+			return nil, nil, fmt.Errorf("variable name %q is reserved", name)
+		}
+		keys = append(keys, name)
+	}
+	sort.Strings(keys)
+
+	names := make([]string, len(keys))
+	values := make([]interface{}, len(keys))
+	for i, key := range keys {
+		names[i] = "$" + key
+		values[i] = variables[key]
+	}
+	return names, values, nil
+}
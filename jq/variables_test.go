@@ -0,0 +1,74 @@
+package jq
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExecuteJQFilesWithOptions_Variables(t *testing.T) {
+	tempDir := t.TempDir()
+	filePath := filepath.Join(tempDir, "users.json")
+	require.NoError(t, os.WriteFile(filePath, []byte(`{"users": [{"name": "Ann", "age": 41}, {"name": "Bob", "age": 25}]}`), 0644))
+
+	tests := []struct {
+		name        string
+		filter      string
+		variables   map[string]interface{}
+		expected    string
+		expectError string
+	}{
+		{
+			name:      "string and number",
+			filter:    `[.users[] | select(.age > $min_age) | .name + $suffix]`,
+			variables: map[string]interface{}{"min_age": 30.0, "suffix": "!"},
+			expected:  "[\n  \"Ann!\"\n]",
+		},
+		{
+			name:      "value is not evaluated as jq",
+			filter:    `$name`,
+			variables: map[string]interface{}{"name": `") | env | ("`},
+			expected:  `"\") | env | (\""`,
+		},
+		{
+			name:      "environment stays hidden",
+			filter:    `$ENV | length`,
+			variables: map[string]interface{}{"x": 1.0},
+			expected:  "0",
+		},
+		{
+			name:        "undefined variable",
+			filter:      `$missing`,
+			expectError: "variable not defined: $missing",
+		},
+		{
+			name:        "invalid name",
+			filter:      `.`,
+			variables:   map[string]interface{}{"$name": "x"},
+			expectError: `invalid variable name "$name"`,
+		},
+		{
+			name:        "reserved name",
+			filter:      `.`,
+			variables:   map[string]interface{}{"ENV": "x"},
+			expectError: `variable name "ENV" is reserved`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := ExecuteJQFilesWithOptions(context.Background(), tt.filter, []string{filePath}, Options{Variables: tt.variables})
+			if tt.expectError != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.expectError)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, result.Output)
+		})
+	}
+}
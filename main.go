@@ -1,6 +1,7 @@
 package main
 
 import (
+	"encoding/json"
 	"flag"
 	"fmt"
 	"os"
@@ -8,7 +9,7 @@ import (
 
 	"github.com/berrydev-ai/gojq-mcp/cli"
 	"github.com/berrydev-ai/gojq-mcp/config"
-	"github.com/berrydev-ai/gojq-mcp/registry"
+	"github.com/berrydev-ai/gojq-mcp/jq"
 	"github.com/berrydev-ai/gojq-mcp/server"
 )
 
@@ -23,15 +24,27 @@ USAGE:
 MODES:
    CLI Mode:         gojq-mcp -f <file> -q <query>
    Server Mode:      gojq-mcp -p <path> [-c <config>] [-i <instructions>]
-   Generate Config:  gojq-mcp generate-config -p <path> [-o <output>]
+   Generate Config:  gojq-mcp generate-config -p <path> [-o <output>] [-force]
+   Validate:         gojq-mcp validate -s <schema> -f <file> [-f <file>...]
 
 OPTIONS:
     -f <file>       Path to JSON file (CLI mode, can be used multiple times)
     -q <query>      jq query to execute (CLI mode)
+   --arg <name> <value>     Bind $name to the string value (CLI mode, can be used multiple times)
+   --argjson <name> <json>  Bind $name to the JSON value (CLI mode, can be used multiple times)
+   -mode <mode>    How documents are given to the query: inputs, slurp, each (CLI mode, default: . for one file, inputs for several)
+   -stream         Read files as jq --stream events through inputs (CLI mode)
+   -max-memory <mb> Memory limit per query in MB, 0 for none (CLI mode, default: 1024)
+   -timeout <dur>  Stop the query after this duration, e.g. 30s (CLI mode, default: none)
+   -workers <n>    Files decoded at the same time in multi-file queries (CLI mode, default: 4)
+   -format <fmt>   Output format: json, compact, raw, jsonl, csv, tsv, markdown_table (CLI mode, default: json)
+   -array          Always print JSON results as an array, even when there is one (CLI mode)
    -p <path>       Path to folder containing JSON files
    -c <config>     Path to YAML configuration file (Server mode)
    -i <instructions> Server instructions for LLM (overrides config)
    -o <output>     Output file for generated config (default: config.yaml)
+   -force          Overwrite the output file of generate-config if it exists
+   -s <schema>     JSON Schema file to validate against (validate)
    -t <transport>  Transport type: stdio, http, or sse (overrides config, default: stdio)
    -a <address>    Address to listen on for http/sse (overrides config, default: :8080)
    -token <token>  Bearer token required by http/sse transports
@@ -49,6 +62,9 @@ EXAMPLES:
     # Generate a config file
     gojq-mcp generate-config -p ./data -o config.yaml
 
+    # Check that every export matches a JSON Schema; exits with status 1 if one does not
+    gojq-mcp validate -s schema.json -f './exports/*.json'
+
     # CLI mode - query a single JSON file
     gojq-mcp -f data.json -q '.users[] | select(.age > 30)'
 
@@ -58,6 +74,21 @@ EXAMPLES:
     # CLI mode - query files using glob patterns
     gojq-mcp -f './data/*.json' -q '.transactions[] | .amount | add'
 
+    # CLI mode - query JSON files at any depth, leaving out drafts
+    gojq-mcp -f './data/**/*.json' -f '!./data/drafts/**' -q '[inputs] | length'
+
+    # CLI mode - total across files with . as an array of every document
+    gojq-mcp -f './data/*.json' -mode slurp -q 'map(.transactions[].amount) | add'
+
+    # CLI mode - bind values to variables instead of quoting them into the query
+    gojq-mcp -f data.json --arg team core --argjson min 30 -q '.users[] | select(.team == $team and .age > $min)'
+
+    # CLI mode - export records as CSV for a spreadsheet
+    gojq-mcp -f data.json -format csv -q '.users[] | {name, email}'
+
+    # CLI mode - stream a file too large to load into memory
+    gojq-mcp -f export.json -stream -q '[inputs | select(length == 2)] | length'
+
     # Server mode with config file
     gojq-mcp -p ./data -c config.yaml
 
@@ -70,10 +101,58 @@ DOCUMENTATION:
 `, version)
 }
 
+// runGenerateConfig parses the flags of the generate-config subcommand
+func runGenerateConfig(args []string) {
+	fs := flag.NewFlagSet("generate-config", flag.ExitOnError)
+	fs.Usage = printUsage
+	dataPath := fs.String("p", "", "Path to folder containing JSON files")
+	output := fs.String("o", "config.yaml", "Output file for generated config")
+	force := fs.Bool("force", false, "Overwrite the output file if it exists")
+	fs.Parse(args)
+
+	cli.RunGenerateConfig(*dataPath, *output, *force)
+}
+
+// runValidate parses the flags of the validate subcommand
+func runValidate(args []string) {
+	fs := flag.NewFlagSet("validate", flag.ExitOnError)
+	fs.Usage = printUsage
+	var filePaths fileList
+	schemaPath := fs.String("s", "", "JSON Schema file to validate against")
+	fs.Var(&filePaths, "f", "Path or glob pattern of files to validate (can be used multiple times)")
+	maxMemory := fs.Int64("max-memory", jq.DefaultMemoryLimit>>20, "Memory limit in MB, 0 for none")
+	fs.Parse(args)
+
+	cli.RunValidate(*schemaPath, filePaths, jq.Options{MemoryLimit: *maxMemory << 20})
+}
+
+// fileList collects the values of a flag given several times
+type fileList []string
+
+func (l *fileList) String() string {
+	return strings.Join(*l, " ")
+}
+
+func (l *fileList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
 func main() {
 	flag.Usage = printUsage
 
+	// Subcommands are dispatched before the global flags are parsed
+	if len(os.Args) > 1 && os.Args[1] == "generate-config" {
+		runGenerateConfig(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "validate" {
+		runValidate(os.Args[2:])
+		return
+	}
+
 	filePaths := make([]string, 0)
+	variables := make(map[string]interface{})
 	query := flag.String("q", "", "jq query to execute")
 	dataPath := flag.String("p", "", "Path to folder containing JSON files")
 	configPath := flag.String("c", "", "Path to YAML configuration file")
@@ -82,17 +161,36 @@ func main() {
 	address := flag.String("a", "", "Address to listen on (overrides config)")
 	tokenFlag := flag.String("token", "", "Bearer token required by http/sse transports")
 	enableWatch := flag.Bool("watch", true, "Enable file system watching")
+	mode := flag.String("mode", "", "How documents are given to the query: inputs, slurp or each (CLI mode)")
+	stream := flag.Bool("stream", false, "Read files as jq --stream events (CLI mode)")
+	maxMemory := flag.Int64("max-memory", jq.DefaultMemoryLimit>>20, "Memory limit per query in MB, 0 for none (CLI mode)")
+	timeout := flag.Duration("timeout", 0, "Stop the query after this duration (CLI mode)")
+	workers := flag.Int("workers", jq.DefaultWorkers, "Files decoded at the same time in multi-file queries (CLI mode)")
+	format := flag.String("format", "json", "Output format (CLI mode)")
+	alwaysArray := flag.Bool("array", false, "Always print JSON results as an array (CLI mode)")
 	showVersion := flag.Bool("version", false, "Display version information")
 
 	// Custom flag parsing to support multiple -f flags
 	// We need to parse -f flags manually since Go's flag package doesn't support repeated flags
 	// Extract -f flags and remove them from args before calling flag.Parse()
+	// --arg and --argjson take two values, which the flag package cannot express, so they are extracted too
 	args := os.Args[1:]
 	filteredArgs := make([]string, 0, len(args))
 	for i := 0; i < len(args); i++ {
 		if args[i] == "-f" && i+1 < len(args) {
 			filePaths = append(filePaths, args[i+1])
 			i++ // Skip the next argument since we consumed it
+		} else if (args[i] == "--arg" || args[i] == "-arg") && i+2 < len(args) {
+			variables[args[i+1]] = args[i+2]
+			i += 2
+		} else if (args[i] == "--argjson" || args[i] == "-argjson") && i+2 < len(args) {
+			var value interface{}
+			if err := json.Unmarshal([]byte(args[i+2]), &value); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: --argjson %s: invalid JSON: %v\n", args[i+1], err)
+				os.Exit(1)
+			}
+			variables[args[i+1]] = value
+			i += 2
 		} else {
 			filteredArgs = append(filteredArgs, args[i])
 		}
@@ -114,7 +212,26 @@ func main() {
 
 	// CLI mode
 	if len(filePaths) > 0 && *query != "" {
-		cli.RunCLIMode(filePaths, *query)
+		outputFormat, err := jq.ParseOutputFormat(*format)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		queryMode, err := jq.ParseMode(*mode)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		cli.RunCLIModeWithOptions(filePaths, *query, jq.Options{
+			Stream:       *stream,
+			Mode:         queryMode,
+			MemoryLimit:  *maxMemory << 20,
+			Timeout:      *timeout,
+			Workers:      *workers,
+			OutputFormat: outputFormat,
+			AlwaysArray:  *alwaysArray,
+			Variables:    variables,
+		})
 		return
 	}
 
@@ -174,33 +291,16 @@ func main() {
 		os.Exit(1)
 	}
 
-	// Create file registry
-	fileRegistry, err := registry.NewFileRegistry(cfg.DataPath)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error initializing file registry: %v\n", err)
-		os.Exit(1)
-	}
-	defer fileRegistry.Close()
-
-	// Create MCP server
-	s, err := server.SetupMCPServer(cfg, fileRegistry)
+	// Create the MCP server and the file registry it publishes
+	s, err := server.NewServer(cfg, *enableWatch)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error setting up MCP server: %v\n", err)
 		os.Exit(1)
 	}
-
-	// Start file watching if enabled
-	if *enableWatch {
-		if err := fileRegistry.StartWatching(); err != nil {
-			fmt.Fprintf(os.Stderr, "Warning: could not enable file watching: %v\n", err)
-			fmt.Fprintf(os.Stderr, "Continuing without file watching...\n")
-		} else {
-			fmt.Fprintf(os.Stderr, "✅ Push notifications enabled - clients will be notified of file changes\n")
-		}
-	}
+	defer s.Close()
 
 	// Start the server
-	err = server.StartServer(s, cfg, authToken)
+	err = s.Start(authToken)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Server error: %v\n", err)
 		os.Exit(1)
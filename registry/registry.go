@@ -2,6 +2,7 @@ package registry
 
 import (
 	"fmt"
+	"net/url"
 	"os"
 	"path/filepath"
 	"sort"
@@ -9,26 +10,47 @@ import (
 	"sync"
 	"time"
 
+	"github.com/berrydev-ai/gojq-mcp/jq"
+	"github.com/bmatcuk/doublestar/v4"
 	"github.com/fsnotify/fsnotify"
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
 )
 
+// ResourceURITemplate is the RFC 6570 template under which data files are published as MCP resources.
+// The optional filter parameter selects a jq-filtered view of the file.
+const ResourceURITemplate = "gojq://data/{+path}{?filter}"
+
 // FileInfo stores metadata about discovered JSON files
 type FileInfo struct {
 	Path     string    `json:"path"`
 	Size     int64     `json:"size"`
 	Modified time.Time `json:"modified"`
+	Records  int       `json:"records,omitempty"` // number of records in a JSON Lines file
+	// UncompressedSize is the size of the data in a compressed file, when the file records it exactly
+	UncompressedSize int64 `json:"uncompressed_size,omitempty"`
+	// Archive is the zip or tar archive holding the file when Path is a virtual path inside it
+	Archive string `json:"archive,omitempty"`
 }
 
 // FileRegistry manages the list of discovered JSON files
 type FileRegistry struct {
-	mu        sync.RWMutex
-	files     []FileInfo
-	rootPath  string
-	watcher   *fsnotify.Watcher
-	debouncer *time.Timer
-	mcpServer *server.MCPServer
+	mu sync.RWMutex
+	// scanMu serializes scans, which read the data directory without holding mu
+	scanMu        sync.Mutex
+	files         []FileInfo
+	rootPath      string
+	watcher       *fsnotify.Watcher
+	debouncer     *time.Timer
+	mcpServer     *server.MCPServer
+	cache         *jq.DocumentCache
+	subscriptions map[string]map[string]bool
+}
+
+// fileChanges describes the difference between two scans of the data directory
+type fileChanges struct {
+	paths       []string
+	listChanged bool
 }
 
 // NewFileRegistry creates a new file registry
@@ -39,12 +61,13 @@ func NewFileRegistry(rootPath string) (*FileRegistry, error) {
 	}
 
 	fr := &FileRegistry{
-		rootPath: absPath,
-		files:    make([]FileInfo, 0),
+		rootPath:      absPath,
+		files:         make([]FileInfo, 0),
+		subscriptions: make(map[string]map[string]bool),
 	}
 
 	// Initial scan
-	if err := fr.scanFiles(); err != nil {
+	if _, err := fr.scanFiles(); err != nil {
 		return nil, err
 	}
 
@@ -58,10 +81,34 @@ func (fr *FileRegistry) SetMCPServer(s *server.MCPServer) {
 	fr.mcpServer = s
 }
 
-// scanFiles discovers all JSON files in the root path
-func (fr *FileRegistry) scanFiles() error {
+// SetDocumentCache sets the parsed-document cache whose entries are dropped when the
+// watcher sees their files change
+func (fr *FileRegistry) SetDocumentCache(cache *jq.DocumentCache) {
 	fr.mu.Lock()
 	defer fr.mu.Unlock()
+	fr.cache = cache
+}
+
+// DocumentCache returns the parsed-document cache set with SetDocumentCache, or nil
+func (fr *FileRegistry) DocumentCache() *jq.DocumentCache {
+	fr.mu.RLock()
+	defer fr.mu.RUnlock()
+	return fr.cache
+}
+
+// scanFiles discovers all JSON files in the root path and reports what changed since the previous scan.
+// Files are described, which reads archives and counts JSON Lines records, before the registry is
+// locked to swap the new list in, so queries and listings are not held up by a scan.
+func (fr *FileRegistry) scanFiles() (fileChanges, error) {
+	fr.scanMu.Lock()
+	defer fr.scanMu.Unlock()
+
+	fr.mu.RLock()
+	previous := make(map[string]FileInfo, len(fr.files))
+	for _, file := range fr.files {
+		previous[file.Path] = file
+	}
+	fr.mu.RUnlock()
 
 	var files []FileInfo
 	err := filepath.Walk(fr.rootPath, func(path string, info os.FileInfo, err error) error {
@@ -70,49 +117,216 @@ func (fr *FileRegistry) scanFiles() error {
 			return nil
 		}
 
-		if info.IsDir() || !strings.HasSuffix(strings.ToLower(path), ".json") {
+		if info.IsDir() {
+			return nil
+		}
+
+		if jq.IsArchive(path) {
+			// Archive members are listed under virtual paths such as bundle.zip/2025/01.json
+			members, err := jq.ArchiveMembers(path)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: error reading archive %s: %v\n", path, err)
+				return nil
+			}
+			for _, member := range members {
+				file := newFileInfo(filepath.Join(path, filepath.FromSlash(member.Name)), member.Size, member.Modified, previous)
+				file.Archive = path
+				files = append(files, file)
+			}
 			return nil
 		}
 
-		files = append(files, FileInfo{
-			Path:     path,
-			Size:     info.Size(),
-			Modified: info.ModTime(),
-		})
+		if jq.IsSupportedFile(path) {
+			files = append(files, newFileInfo(path, info.Size(), info.ModTime(), previous))
+		}
 
 		return nil
 	})
 
 	if err != nil {
-		return fmt.Errorf("error scanning files: %w", err)
+		return fileChanges{}, fmt.Errorf("error scanning files: %w", err)
 	}
 
 	sort.Slice(files, func(i, j int) bool {
 		return files[i].Path < files[j].Path
 	})
 
+	fr.mu.Lock()
+	changes := diffFiles(fr.files, files)
 	fr.files = files
+	fr.mu.Unlock()
 	fmt.Fprintf(os.Stderr, "Discovered %d JSON files in %s\n", len(files), fr.rootPath)
 
-	return nil
+	return changes, nil
+}
+
+// newFileInfo describes a data file, reusing the record count from the previous scan when the
+// file has not changed since
+func newFileInfo(path string, size int64, modified time.Time, previous map[string]FileInfo) FileInfo {
+	file := FileInfo{
+		Path:     path,
+		Size:     size,
+		Modified: modified,
+	}
+
+	if jq.Compression(path) != "" {
+		file.UncompressedSize, _ = jq.UncompressedSize(path)
+	}
+
+	if jq.IsJSONLinesFile(path) {
+		var err error
+		if prev, ok := previous[path]; ok && prev.Size == file.Size && prev.Modified.Equal(file.Modified) {
+			file.Records = prev.Records
+		} else if file.Records, err = jq.CountJSONLines(path); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: error counting records in %s: %v\n", path, err)
+		}
+	}
+
+	return file
+}
+
+// diffFiles compares two sorted file lists and returns the paths that were added, removed or modified
+func diffFiles(before, after []FileInfo) fileChanges {
+	var changes fileChanges
+
+	i, j := 0, 0
+	for i < len(before) || j < len(after) {
+		switch {
+		case j == len(after) || (i < len(before) && before[i].Path < after[j].Path):
+			changes.paths = append(changes.paths, before[i].Path)
+			changes.listChanged = true
+			i++
+		case i == len(before) || after[j].Path < before[i].Path:
+			changes.paths = append(changes.paths, after[j].Path)
+			changes.listChanged = true
+			j++
+		default:
+			if before[i].Size != after[j].Size || !before[i].Modified.Equal(after[j].Modified) {
+				changes.paths = append(changes.paths, after[j].Path)
+			}
+			i++
+			j++
+		}
+	}
+
+	return changes
 }
 
-// notifyClients sends MCP notification to all connected clients
-func (fr *FileRegistry) notifyClients() {
+// notifyClients sends list_changed to all connected clients when files were added or removed,
+// and resources/updated to each session subscribed to a changed file
+func (fr *FileRegistry) notifyClients(changes fileChanges) {
 	fr.mu.RLock()
 	mcpServer := fr.mcpServer
+	fileCount := len(fr.files)
 	fr.mu.RUnlock()
 
 	if mcpServer == nil {
 		return
 	}
 
-	mcpServer.SendNotificationToAllClients(
-		string(mcp.MethodNotificationResourcesListChanged),
-		nil,
-	)
+	if changes.listChanged {
+		mcpServer.SendNotificationToAllClients(
+			string(mcp.MethodNotificationResourcesListChanged),
+			nil,
+		)
+		fmt.Fprintf(os.Stderr, "📢 Sent notification to clients: resource list changed (%d files)\n", fileCount)
+	}
+
+	for sessionID, uris := range fr.matchSubscriptions(changes.paths) {
+		for _, uri := range uris {
+			err := mcpServer.SendNotificationToSpecificClient(
+				sessionID,
+				string(mcp.MethodNotificationResourceUpdated),
+				map[string]any{"uri": uri},
+			)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: could not notify session %s about %s: %v\n", sessionID, uri, err)
+			}
+		}
+		fmt.Fprintf(os.Stderr, "📢 Sent %d resource update(s) to session %s\n", len(uris), sessionID)
+	}
+}
+
+// SubscriptionPattern returns the file path or glob pattern, relative to the data directory,
+// that a resources/subscribe URI refers to
+func SubscriptionPattern(uri string) (string, error) {
+	pattern, _, err := ParseResourceURI(uri)
+	if err != nil {
+		return "", err
+	}
+	if !doublestar.ValidatePathPattern(pattern) {
+		return "", fmt.Errorf("invalid subscription pattern %q: %w", pattern, doublestar.ErrBadPattern)
+	}
+	return pattern, nil
+}
+
+// Subscribe records that a client session wants resources/updated notifications
+// for the file or glob pattern named by uri
+func (fr *FileRegistry) Subscribe(sessionID, uri string) error {
+	if sessionID == "" {
+		return fmt.Errorf("resource subscriptions require a client session")
+	}
+
+	pattern, err := SubscriptionPattern(uri)
+	if err != nil {
+		return err
+	}
+
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	if fr.subscriptions[sessionID] == nil {
+		fr.subscriptions[sessionID] = make(map[string]bool)
+	}
+	fr.subscriptions[sessionID][pattern] = true
 
-	fmt.Fprintf(os.Stderr, "📢 Sent notification to clients: resource list changed (%d files)\n", len(fr.files))
+	return nil
+}
+
+// Unsubscribe removes a subscription previously made with Subscribe
+func (fr *FileRegistry) Unsubscribe(sessionID, uri string) error {
+	pattern, err := SubscriptionPattern(uri)
+	if err != nil {
+		return err
+	}
+
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	delete(fr.subscriptions[sessionID], pattern)
+	if len(fr.subscriptions[sessionID]) == 0 {
+		delete(fr.subscriptions, sessionID)
+	}
+
+	return nil
+}
+
+// RemoveSession drops every subscription held by a client session
+func (fr *FileRegistry) RemoveSession(sessionID string) {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+	delete(fr.subscriptions, sessionID)
+}
+
+// matchSubscriptions maps each subscribed session to the resource URIs of the changed paths it matches
+func (fr *FileRegistry) matchSubscriptions(paths []string) map[string][]string {
+	fr.mu.RLock()
+	defer fr.mu.RUnlock()
+
+	matches := make(map[string][]string)
+	for sessionID, patterns := range fr.subscriptions {
+		for _, path := range paths {
+			relPath := fr.RelativePath(path)
+			for pattern := range patterns {
+				if doublestar.PathMatchUnvalidated(pattern, relPath) {
+					matches[sessionID] = append(matches[sessionID], ResourceURI(relPath))
+					break
+				}
+			}
+		}
+	}
+
+	return matches
 }
 
 // StartWatching starts watching the directory for changes
@@ -155,7 +369,7 @@ func (fr *FileRegistry) watch() {
 				return
 			}
 
-			isJSON := strings.HasSuffix(strings.ToLower(event.Name), ".json")
+			isJSON := jq.IsSupportedFile(event.Name) || jq.IsArchive(event.Name)
 			isDir := false
 			if info, err := os.Stat(event.Name); err == nil {
 				isDir = info.IsDir()
@@ -165,6 +379,13 @@ func (fr *FileRegistry) watch() {
 				continue
 			}
 
+			// Watch new directories right away so files created in them before the rescan are seen
+			if isDir && event.Op&fsnotify.Create == fsnotify.Create {
+				if err := fr.watcher.Add(event.Name); err != nil {
+					fmt.Fprintf(os.Stderr, "Warning: could not watch new directory %s: %v\n", event.Name, err)
+				}
+			}
+
 			if fr.debouncer != nil {
 				fr.debouncer.Stop()
 			}
@@ -172,20 +393,16 @@ func (fr *FileRegistry) watch() {
 			fr.debouncer = time.AfterFunc(500*time.Millisecond, func() {
 				fmt.Fprintf(os.Stderr, "📄 File system change detected (%s: %s), rescanning...\n", event.Op.String(), event.Name)
 
-				if err := fr.scanFiles(); err != nil {
+				changes, err := fr.scanFiles()
+				if err != nil {
 					fmt.Fprintf(os.Stderr, "Error rescanning files: %v\n", err)
 					return
 				}
 
-				fr.notifyClients()
-
-				if event.Op&fsnotify.Create == fsnotify.Create {
-					if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
-						if err := fr.watcher.Add(event.Name); err != nil {
-							fmt.Fprintf(os.Stderr, "Warning: could not watch new directory %s: %v\n", event.Name, err)
-						}
-					}
-				}
+				// The cache would notice the new size or modification time, but dropping
+				// the entries frees their memory right away
+				fr.DocumentCache().Invalidate(changes.paths...)
+				fr.notifyClients(changes)
 			})
 
 		case err, ok := <-fr.watcher.Errors:
@@ -207,29 +424,121 @@ func (fr *FileRegistry) GetFiles() []FileInfo {
 	return files
 }
 
+// RootPath returns the absolute path of the registry's data directory
+func (fr *FileRegistry) RootPath() string {
+	return fr.rootPath
+}
+
+// RelativePath returns path relative to the data directory, using forward slashes
+func (fr *FileRegistry) RelativePath(path string) string {
+	relPath, err := filepath.Rel(fr.rootPath, path)
+	if err != nil {
+		return path
+	}
+	return filepath.ToSlash(relPath)
+}
+
+// Lookup returns the registered file for a path relative to the data directory
+func (fr *FileRegistry) Lookup(relPath string) (FileInfo, bool) {
+	target := filepath.Join(fr.rootPath, filepath.FromSlash(relPath))
+
+	fr.mu.RLock()
+	defer fr.mu.RUnlock()
+
+	i := sort.Search(len(fr.files), func(i int) bool {
+		return fr.files[i].Path >= target
+	})
+	if i < len(fr.files) && fr.files[i].Path == target {
+		return fr.files[i], true
+	}
+	return FileInfo{}, false
+}
+
+// ResourceURI returns the MCP resource URI for a path relative to the data directory
+func ResourceURI(relPath string) string {
+	u := url.URL{Scheme: "gojq", Host: "data", Path: "/" + filepath.ToSlash(relPath)}
+	return u.String()
+}
+
+// ParseResourceURI splits a resource URI into the relative file path and its query parameters
+func ParseResourceURI(uri string) (string, url.Values, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid resource URI %q: %w", uri, err)
+	}
+	if u.Scheme != "gojq" || u.Host != "data" {
+		return "", nil, fmt.Errorf("unsupported resource URI %q", uri)
+	}
+
+	relPath := strings.TrimPrefix(u.Path, "/")
+	if relPath == "" {
+		return "", nil, fmt.Errorf("resource URI %q does not name a file", uri)
+	}
+
+	return relPath, u.Query(), nil
+}
+
+// MIMEType returns the MIME type reported for a data file
+func MIMEType(path string) string {
+	if format, ok := jq.LookupFormat(path); ok && format.MIMEType != "" {
+		return format.MIMEType
+	}
+	return "application/json"
+}
+
+// formatLabel names the format of the files matching a path or pattern, e.g. "gzip-compressed JSON"
+func formatLabel(path string) string {
+	format, _ := jq.LookupFormat(path)
+	if compression := jq.Compression(path); compression != "" {
+		return compression + "-compressed " + format.Label
+	}
+	return format.Label
+}
+
 // GetManifest returns a structured manifest
 func (fr *FileRegistry) GetManifest() map[string]interface{} {
 	files := fr.GetFiles()
 
 	type RelativeFileInfo struct {
-		Path     string    `json:"path"`
-		Modified time.Time `json:"modified"`
-		Size     int64     `json:"size"`
+		Path             string    `json:"path"`
+		Format           string    `json:"format"`
+		Compression      string    `json:"compression,omitempty"`
+		Modified         time.Time `json:"modified"`
+		Size             int64     `json:"size"`
+		UncompressedSize *int64    `json:"uncompressed_size,omitempty"`
+		Records          *int      `json:"records,omitempty"`
+		Archive          string    `json:"archive,omitempty"`
 	}
 
 	var relativeFiles []RelativeFileInfo
-	dirMap := make(map[string][]string)
+	dirMap := make(map[string][]string)   // keyed by directory pattern, e.g. "logs/*.jsonl"
+	otherFormats := make(map[string]bool) // extensions other than .json, such as ".yaml" or ".json.gz"
 
 	for _, file := range files {
-		relPath, err := filepath.Rel(fr.rootPath, file.Path)
-		if err != nil {
-			relPath = file.Path
-		}
+		relPath := fr.RelativePath(file.Path)
 
+		format, _ := jq.LookupFormat(file.Path)
 		fileInfo := RelativeFileInfo{
-			Path:     relPath,
-			Modified: file.Modified,
-			Size:     file.Size,
+			Path:        relPath,
+			Format:      format.Name,
+			Compression: jq.Compression(file.Path),
+			Modified:    file.Modified,
+			Size:        file.Size,
+		}
+		if file.UncompressedSize > 0 {
+			uncompressedSize := file.UncompressedSize
+			fileInfo.UncompressedSize = &uncompressedSize
+		}
+		if jq.IsJSONLinesFile(file.Path) {
+			records := file.Records
+			fileInfo.Records = &records
+		}
+		if file.Archive != "" {
+			fileInfo.Archive = fr.RelativePath(file.Archive)
+		}
+		ext := jq.FileExt(relPath)
+		if ext != ".json" {
+			otherFormats[ext] = true
 		}
 
 		relativeFiles = append(relativeFiles, fileInfo)
@@ -238,7 +547,8 @@ func (fr *FileRegistry) GetManifest() map[string]interface{} {
 		if relDir == "." {
 			relDir = "base"
 		}
-		dirMap[relDir] = append(dirMap[relDir], relPath)
+		dirPattern := relDir + "/*" + ext
+		dirMap[dirPattern] = append(dirMap[dirPattern], relPath)
 	}
 
 	manifest := map[string]interface{}{
@@ -251,15 +561,18 @@ func (fr *FileRegistry) GetManifest() map[string]interface{} {
 		patterns := make(map[string]string)
 		patterns["*.json"] = "All JSON files in base path"
 		patterns["**/*.json"] = "All JSON files recursively"
-
-		dirCounts := make(map[string]int)
-		for dir, paths := range dirMap {
-			dirCounts[dir] = len(paths)
+		for ext := range otherFormats {
+			if jq.IsJSONLinesFile(ext) {
+				patterns["**/*"+ext] = fmt.Sprintf("All %s files recursively (each record is a separate input)", formatLabel(ext))
+			} else {
+				patterns["**/*"+ext] = fmt.Sprintf("All %s files recursively", formatLabel(ext))
+			}
 		}
 
-		for dir, count := range dirCounts {
-			if count > 1 && dir != "base" {
-				patterns[dir+"/*.json"] = fmt.Sprintf("All %d JSON files in %s", count, filepath.Base(dir))
+		for dirPattern, paths := range dirMap {
+			dir := filepath.Dir(dirPattern)
+			if len(paths) > 1 && dir != "base" {
+				patterns[dirPattern] = fmt.Sprintf("All %d %s files in %s", len(paths), formatLabel(dirPattern), filepath.Base(dir))
 			}
 		}
 
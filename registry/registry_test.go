@@ -1,11 +1,18 @@
 package registry
 
 import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
 	"os"
 	"path/filepath"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/berrydev-ai/gojq-mcp/jq"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -105,3 +112,358 @@ func TestFileRegistry_StartWatching(t *testing.T) {
 
 	registry.Close()
 }
+
+func TestFileRegistry_InvalidatesDocumentCache(t *testing.T) {
+	tempDir := t.TempDir()
+	filePath := filepath.Join(tempDir, "data.json")
+	require.NoError(t, os.WriteFile(filePath, []byte(`{"version": 1}`), 0644))
+
+	registry, err := NewFileRegistry(tempDir)
+	require.NoError(t, err)
+	defer registry.Close()
+
+	cache := jq.NewDocumentCache(1 << 20)
+	registry.SetDocumentCache(cache)
+	require.NoError(t, registry.StartWatching())
+	time.Sleep(100 * time.Millisecond)
+
+	_, err = jq.ExecuteJQFilesWithOptions(context.Background(), ".version", []string{filePath}, jq.Options{Cache: cache})
+	require.NoError(t, err)
+	require.Equal(t, 1, cache.Stats().Entries)
+
+	require.NoError(t, os.WriteFile(filePath, []byte(`{"version": 22}`), 0644))
+	time.Sleep(600 * time.Millisecond)
+
+	assert.Equal(t, 0, cache.Stats().Entries)
+}
+
+func TestResourceURI(t *testing.T) {
+	uri := ResourceURI("2025-01/daily report.json")
+	assert.Equal(t, "gojq://data/2025-01/daily%20report.json", uri)
+
+	relPath, query, err := ParseResourceURI(uri + "?filter=.users%5B0%5D")
+	require.NoError(t, err)
+	assert.Equal(t, "2025-01/daily report.json", relPath)
+	assert.Equal(t, ".users[0]", query.Get("filter"))
+
+	_, _, err = ParseResourceURI("file:///etc/passwd")
+	assert.Error(t, err)
+	_, _, err = ParseResourceURI("gojq://data/")
+	assert.Error(t, err)
+}
+
+func TestFileRegistry_Lookup(t *testing.T) {
+	tempDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(tempDir, "subdir"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "subdir", "a.json"), []byte(`{}`), 0644))
+
+	registry, err := NewFileRegistry(tempDir)
+	require.NoError(t, err)
+
+	file, ok := registry.Lookup("subdir/a.json")
+	assert.True(t, ok)
+	assert.Equal(t, filepath.Join(tempDir, "subdir", "a.json"), file.Path)
+	assert.Equal(t, "subdir/a.json", registry.RelativePath(file.Path))
+
+	_, ok = registry.Lookup("missing.json")
+	assert.False(t, ok)
+	_, ok = registry.Lookup("../a.json")
+	assert.False(t, ok)
+}
+
+func TestDiffFiles(t *testing.T) {
+	now := time.Now()
+	before := []FileInfo{
+		{Path: "/data/a.json", Size: 1, Modified: now},
+		{Path: "/data/b.json", Size: 1, Modified: now},
+		{Path: "/data/c.json", Size: 1, Modified: now},
+	}
+
+	changes := diffFiles(before, before)
+	assert.Empty(t, changes.paths)
+	assert.False(t, changes.listChanged)
+
+	modified := []FileInfo{before[0], {Path: "/data/b.json", Size: 2, Modified: now}, before[2]}
+	changes = diffFiles(before, modified)
+	assert.Equal(t, []string{"/data/b.json"}, changes.paths)
+	assert.False(t, changes.listChanged)
+
+	after := []FileInfo{before[1], before[2], {Path: "/data/d.json", Size: 1, Modified: now}}
+	changes = diffFiles(before, after)
+	assert.Equal(t, []string{"/data/a.json", "/data/d.json"}, changes.paths)
+	assert.True(t, changes.listChanged)
+}
+
+func TestFileRegistry_Subscriptions(t *testing.T) {
+	tempDir := t.TempDir()
+	registry, err := NewFileRegistry(tempDir)
+	require.NoError(t, err)
+
+	require.NoError(t, registry.Subscribe("session-1", "gojq://data/2025-01/*.json"))
+	require.NoError(t, registry.Subscribe("session-2", "gojq://data/users.json"))
+	require.NoError(t, registry.Subscribe("session-3", "gojq://data/**/01.json"))
+	assert.Error(t, registry.Subscribe("session-1", "gojq://data/[.json"))
+	assert.Error(t, registry.Subscribe("session-1", "https://example.com/users.json"))
+	assert.Error(t, registry.Subscribe("", "gojq://data/users.json"))
+
+	changed := []string{
+		filepath.Join(tempDir, "2025-01", "01.json"),
+		filepath.Join(tempDir, "2025-02", "01.json"),
+		filepath.Join(tempDir, "users.json"),
+	}
+
+	matches := registry.matchSubscriptions(changed)
+	assert.Equal(t, map[string][]string{
+		"session-1": {"gojq://data/2025-01/01.json"},
+		"session-2": {"gojq://data/users.json"},
+		"session-3": {"gojq://data/2025-01/01.json", "gojq://data/2025-02/01.json"},
+	}, matches)
+
+	require.NoError(t, registry.Unsubscribe("session-1", "gojq://data/2025-01/*.json"))
+	registry.RemoveSession("session-2")
+	registry.RemoveSession("session-3")
+	assert.Empty(t, registry.matchSubscriptions(changed))
+}
+
+func TestFileRegistry_JSONLines(t *testing.T) {
+	tempDir := t.TempDir()
+
+	files := map[string]string{
+		"data.json":        `{"test": true}`,
+		"logs/a.jsonl":     "{\"id\": 1}\n{\"id\": 2}\n\n{\"id\": 3}\n",
+		"logs/b.jsonl":     "",
+		"logs/c.ndjson":    "{\"id\": 4}\n",
+		"logs/ignored.txt": "not data",
+	}
+	for name, content := range files {
+		fullPath := filepath.Join(tempDir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(fullPath), 0755))
+		require.NoError(t, os.WriteFile(fullPath, []byte(content), 0644))
+	}
+
+	registry, err := NewFileRegistry(tempDir)
+	require.NoError(t, err)
+
+	require.Len(t, registry.GetFiles(), 4)
+
+	file, ok := registry.Lookup("logs/a.jsonl")
+	require.True(t, ok)
+	assert.Equal(t, 3, file.Records)
+	assert.Equal(t, "application/x-ndjson", MIMEType(file.Path))
+	assert.Equal(t, "application/json", MIMEType(filepath.Join(tempDir, "data.json")))
+
+	manifest := registry.GetManifest()
+	output, err := json.Marshal(manifest["files"])
+	require.NoError(t, err)
+
+	var manifestFiles []struct {
+		Path    string `json:"path"`
+		Records *int   `json:"records"`
+	}
+	require.NoError(t, json.Unmarshal(output, &manifestFiles))
+
+	records := make(map[string]*int)
+	for _, f := range manifestFiles {
+		records[f.Path] = f.Records
+	}
+	assert.Nil(t, records["data.json"])
+	require.NotNil(t, records["logs/a.jsonl"])
+	assert.Equal(t, 3, *records["logs/a.jsonl"])
+	require.NotNil(t, records["logs/b.jsonl"])
+	assert.Equal(t, 0, *records["logs/b.jsonl"])
+
+	patterns := manifest["suggested_patterns"].(map[string]string)
+	assert.Contains(t, patterns, "logs/*.jsonl")
+	assert.Contains(t, patterns, "**/*.jsonl")
+}
+
+func TestFileRegistry_Formats(t *testing.T) {
+	tempDir := t.TempDir()
+
+	files := map[string]string{
+		"data.json":            `{}`,
+		"config/app.yaml":      "name: app\n",
+		"config/db.yml":        "port: 5432\n",
+		"config/settings.toml": "title = \"settings\"\n",
+		"exports/users.csv":    "name\nAnn\n",
+		"exports/readme.md":    "# not data",
+	}
+	for name, content := range files {
+		fullPath := filepath.Join(tempDir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(fullPath), 0755))
+		require.NoError(t, os.WriteFile(fullPath, []byte(content), 0644))
+	}
+
+	registry, err := NewFileRegistry(tempDir)
+	require.NoError(t, err)
+	require.Len(t, registry.GetFiles(), 5)
+
+	assert.Equal(t, "application/yaml", MIMEType(filepath.Join(tempDir, "config/app.yaml")))
+	assert.Equal(t, "application/toml", MIMEType(filepath.Join(tempDir, "config/settings.toml")))
+	assert.Equal(t, "text/csv", MIMEType(filepath.Join(tempDir, "exports/users.csv")))
+
+	manifest := registry.GetManifest()
+	output, err := json.Marshal(manifest["files"])
+	require.NoError(t, err)
+
+	var manifestFiles []struct {
+		Path   string `json:"path"`
+		Format string `json:"format"`
+	}
+	require.NoError(t, json.Unmarshal(output, &manifestFiles))
+
+	formats := make(map[string]string)
+	for _, f := range manifestFiles {
+		formats[f.Path] = f.Format
+	}
+	assert.Equal(t, map[string]string{
+		"data.json":            "json",
+		"config/app.yaml":      "yaml",
+		"config/db.yml":        "yaml",
+		"config/settings.toml": "toml",
+		"exports/users.csv":    "csv",
+	}, formats)
+
+	patterns := manifest["suggested_patterns"].(map[string]string)
+	assert.Equal(t, "All YAML files recursively", patterns["**/*.yaml"])
+	assert.Equal(t, "All CSV files recursively", patterns["**/*.csv"])
+	assert.NotContains(t, patterns, "**/*.md")
+}
+
+func TestFileRegistry_Compressed(t *testing.T) {
+	tempDir := t.TempDir()
+
+	compress := func(data string) []byte {
+		var buf bytes.Buffer
+		w := gzip.NewWriter(&buf)
+		_, err := w.Write([]byte(data))
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+		return buf.Bytes()
+	}
+
+	files := map[string][]byte{
+		"archive/2025-01-01.json.gz": compress(`{"day": 1}`),
+		"archive/2025-01-02.json.gz": compress(`{"day": 2}`),
+		"archive/events.jsonl.gz":    compress("{\"id\": 1}\n{\"id\": 2}\n"),
+		"archive/backup.gz":          compress("not data"),
+	}
+	for name, content := range files {
+		fullPath := filepath.Join(tempDir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(fullPath), 0755))
+		require.NoError(t, os.WriteFile(fullPath, content, 0644))
+	}
+
+	registry, err := NewFileRegistry(tempDir)
+	require.NoError(t, err)
+	require.Len(t, registry.GetFiles(), 3)
+
+	file, ok := registry.Lookup("archive/events.jsonl.gz")
+	require.True(t, ok)
+	assert.Equal(t, 2, file.Records)
+	assert.Equal(t, int64(20), file.UncompressedSize)
+	assert.Equal(t, "application/x-ndjson", MIMEType(file.Path))
+
+	manifest := registry.GetManifest()
+	output, err := json.Marshal(manifest["files"])
+	require.NoError(t, err)
+
+	var manifestFiles []struct {
+		Path             string `json:"path"`
+		Format           string `json:"format"`
+		Compression      string `json:"compression"`
+		Size             int64  `json:"size"`
+		UncompressedSize *int64 `json:"uncompressed_size"`
+	}
+	require.NoError(t, json.Unmarshal(output, &manifestFiles))
+	require.Len(t, manifestFiles, 3)
+
+	day := manifestFiles[0]
+	assert.Equal(t, "archive/2025-01-01.json.gz", day.Path)
+	assert.Equal(t, "json", day.Format)
+	assert.Equal(t, "gzip", day.Compression)
+	require.NotNil(t, day.UncompressedSize)
+	assert.Equal(t, int64(10), *day.UncompressedSize)
+	assert.Greater(t, day.Size, *day.UncompressedSize)
+
+	patterns := manifest["suggested_patterns"].(map[string]string)
+	assert.Equal(t, "All 2 gzip-compressed JSON files in archive", patterns["archive/*.json.gz"])
+	assert.Contains(t, patterns, "**/*.jsonl.gz")
+}
+
+func TestFileRegistry_Archives(t *testing.T) {
+	tempDir := t.TempDir()
+
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, content := range map[string]string{
+		"2025/01.json":  `{"month": 1}`,
+		"events.jsonl":  "{\"id\": 1}\n{\"id\": 2}\n",
+		"README.md":     "not data",
+		"../outer.json": `{}`,
+	} {
+		f, err := w.Create(name)
+		require.NoError(t, err)
+		_, err = f.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Close())
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "bundle.zip"), buf.Bytes(), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "broken.zip"), []byte("not a zip"), 0644))
+
+	registry, err := NewFileRegistry(tempDir)
+	require.NoError(t, err)
+	require.Len(t, registry.GetFiles(), 2)
+
+	file, ok := registry.Lookup("bundle.zip/events.jsonl")
+	require.True(t, ok)
+	assert.Equal(t, 2, file.Records)
+	assert.Equal(t, filepath.Join(tempDir, "bundle.zip"), file.Archive)
+
+	_, ok = registry.Lookup("bundle.zip/2025/01.json")
+	assert.True(t, ok)
+
+	output, err := json.Marshal(registry.GetManifest()["files"])
+	require.NoError(t, err)
+	assert.Contains(t, string(output), `"path":"bundle.zip/2025/01.json"`)
+	assert.Contains(t, string(output), `"archive":"bundle.zip"`)
+}
+
+func TestFileRegistry_ConcurrentScans(t *testing.T) {
+	tempDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "a.json"), []byte(`{}`), 0644))
+	events := bytes.Repeat([]byte("{\"id\": 1}\n"), 10000)
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "events.jsonl"), events, 0644))
+
+	registry, err := NewFileRegistry(tempDir)
+	require.NoError(t, err)
+	defer registry.Close()
+
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "b.json"), []byte(`{}`), 0644))
+
+	// Scans describe files without holding the registry's lock, so readers run alongside them
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			_, err := registry.scanFiles()
+			assert.NoError(t, err)
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				_, ok := registry.Lookup("a.json")
+				assert.True(t, ok)
+				registry.GetManifest()
+			}
+		}()
+	}
+	wg.Wait()
+
+	files := registry.GetFiles()
+	require.Len(t, files, 3)
+	file, ok := registry.Lookup("events.jsonl")
+	require.True(t, ok)
+	assert.Equal(t, 10000, file.Records)
+}
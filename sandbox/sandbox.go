@@ -0,0 +1,164 @@
+package sandbox
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// SymlinkPolicy controls whether files may be reached through symbolic links
+type SymlinkPolicy string
+
+const (
+	// SymlinksWithinRoot follows symbolic links whose targets are inside the root
+	SymlinksWithinRoot SymlinkPolicy = "within_root"
+	// SymlinksFollow follows every symbolic link, even to targets outside the root
+	SymlinksFollow SymlinkPolicy = "follow"
+	// SymlinksDeny rejects any path that passes through a symbolic link
+	SymlinksDeny SymlinkPolicy = "deny"
+)
+
+// ErrAccessDenied is wrapped by every error reporting a path outside the sandbox
+var ErrAccessDenied = errors.New("access denied")
+
+// ParseSymlinkPolicy validates a symlink policy name; an empty name selects SymlinksWithinRoot
+func ParseSymlinkPolicy(name string) (SymlinkPolicy, error) {
+	switch policy := SymlinkPolicy(name); policy {
+	case "":
+		return SymlinksWithinRoot, nil
+	case SymlinksWithinRoot, SymlinksFollow, SymlinksDeny:
+		return policy, nil
+	}
+	return "", fmt.Errorf("unsupported symlink policy %q; use one of %s, %s, %s", name, SymlinksWithinRoot, SymlinksFollow, SymlinksDeny)
+}
+
+// Sandbox confines file access to a root directory
+type Sandbox struct {
+	// root is the absolute root as configured and realRoot the same directory with its symlinks resolved
+	root     string
+	realRoot string
+	policy   SymlinkPolicy
+}
+
+// New creates a sandbox rooted at root. An empty policy selects SymlinksWithinRoot.
+func New(root string, policy SymlinkPolicy) (*Sandbox, error) {
+	policy, err := ParseSymlinkPolicy(string(policy))
+	if err != nil {
+		return nil, err
+	}
+
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return nil, fmt.Errorf("error resolving data directory %s: %w", root, err)
+	}
+	realRoot, err := filepath.EvalSymlinks(absRoot)
+	if err != nil {
+		return nil, fmt.Errorf("error resolving data directory %s: %w", root, err)
+	}
+
+	return &Sandbox{root: absRoot, realRoot: realRoot, policy: policy}, nil
+}
+
+// Root returns the absolute path of the sandbox root
+func (s *Sandbox) Root() string {
+	return s.root
+}
+
+// Pattern returns a file path or glob pattern as an absolute pattern inside the root.
+// Relative patterns are joined to the root; patterns that leave it, such as '../*.json'
+// or a sibling directory sharing the root's name as a prefix, are rejected. A leading !
+// is kept so excluding patterns keep working.
+func (s *Sandbox) Pattern(pattern string) (string, error) {
+	negation := ""
+	if strings.HasPrefix(pattern, "!") {
+		negation, pattern = "!", pattern[1:]
+	}
+
+	path := filepath.Clean(pattern)
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(s.root, path)
+	}
+	if !within(s.root, path) && !within(s.realRoot, path) {
+		return "", fmt.Errorf("%w: path %s is outside data directory", ErrAccessDenied, pattern)
+	}
+
+	return negation + path, nil
+}
+
+// Check resolves the symbolic links in path and returns an error if the file it names is
+// outside the root or is reached through a symbolic link the policy does not allow
+func (s *Sandbox) Check(path string) error {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return fmt.Errorf("error resolving path %s: %w", path, err)
+	}
+
+	var rel string
+	switch {
+	case within(s.root, absPath):
+		rel, _ = filepath.Rel(s.root, absPath)
+	case within(s.realRoot, absPath):
+		rel, _ = filepath.Rel(s.realRoot, absPath)
+	default:
+		return fmt.Errorf("%w: path %s is outside data directory", ErrAccessDenied, path)
+	}
+
+	realPath, err := resolve(absPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("file %s does not exist", path)
+		}
+		return fmt.Errorf("error resolving path %s: %w", path, err)
+	}
+
+	switch s.policy {
+	case SymlinksDeny:
+		if realPath != filepath.Join(s.realRoot, rel) {
+			return fmt.Errorf("%w: path %s passes through a symbolic link and symlinks are not allowed", ErrAccessDenied, path)
+		}
+	case SymlinksWithinRoot:
+		if !within(s.realRoot, realPath) {
+			return fmt.Errorf("%w: path %s links outside data directory", ErrAccessDenied, path)
+		}
+	}
+
+	return nil
+}
+
+// resolve evaluates the symbolic links in path. A path that continues below a regular file,
+// such as a member of an archive at "bundle.zip/2025/01.json", resolves to the real path of
+// that file followed by the rest of the path.
+func resolve(path string) (string, error) {
+	realPath, err := filepath.EvalSymlinks(path)
+	if err == nil {
+		return realPath, nil
+	}
+
+	for parent := filepath.Dir(path); parent != filepath.Dir(parent); parent = filepath.Dir(parent) {
+		info, statErr := os.Stat(parent)
+		if statErr != nil {
+			continue
+		}
+		if !info.Mode().IsRegular() {
+			break
+		}
+		realParent, evalErr := filepath.EvalSymlinks(parent)
+		if evalErr != nil {
+			return "", evalErr
+		}
+		rest, _ := filepath.Rel(parent, path)
+		return filepath.Join(realParent, rest), nil
+	}
+	return "", err
+}
+
+// within reports whether path is root or inside it; both must be absolute and clean
+func within(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
@@ -0,0 +1,129 @@
+package sandbox
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupTree creates a data directory next to a sibling sharing its name as a prefix and an
+// outside directory, with symbolic links from the data directory into each of them
+func setupTree(t *testing.T) (string, string) {
+	base := t.TempDir()
+	files := map[string]string{
+		"data/users.json":         `{}`,
+		"data/nested/orders.json": `{}`,
+		"data/bundle.zip":         "",
+		"outside/bundle.zip":      "",
+		"data-secret/keys.json":   `{}`,
+		"outside/secret.json":     `{}`,
+	}
+	for path, content := range files {
+		fullPath := filepath.Join(base, path)
+		require.NoError(t, os.MkdirAll(filepath.Dir(fullPath), 0755))
+		require.NoError(t, os.WriteFile(fullPath, []byte(content), 0644))
+	}
+
+	dataDir := filepath.Join(base, "data")
+	require.NoError(t, os.Symlink(filepath.Join(base, "outside", "secret.json"), filepath.Join(dataDir, "secret-link.json")))
+	require.NoError(t, os.Symlink(filepath.Join(base, "outside"), filepath.Join(dataDir, "outside-dir")))
+	require.NoError(t, os.Symlink(filepath.Join(dataDir, "nested", "orders.json"), filepath.Join(dataDir, "orders-link.json")))
+	require.NoError(t, os.Symlink(dataDir, filepath.Join(base, "data-link")))
+	require.NoError(t, os.Symlink(filepath.Join(base, "outside", "bundle.zip"), filepath.Join(dataDir, "bundle-link.zip")))
+
+	return base, dataDir
+}
+
+func TestSandbox_Pattern(t *testing.T) {
+	base, dataDir := setupTree(t)
+	box, err := New(dataDir, "")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		pattern  string
+		expected string
+		denied   bool
+	}{
+		{name: "relative file", pattern: "users.json", expected: filepath.Join(dataDir, "users.json")},
+		{name: "relative glob", pattern: "**/*.json", expected: filepath.Join(dataDir, "**/*.json")},
+		{name: "excluding pattern", pattern: "!nested/**", expected: "!" + filepath.Join(dataDir, "nested/**")},
+		{name: "absolute inside", pattern: filepath.Join(dataDir, "users.json"), expected: filepath.Join(dataDir, "users.json")},
+		{name: "traversal that stays inside", pattern: "nested/../users.json", expected: filepath.Join(dataDir, "users.json")},
+		{name: "relative traversal", pattern: "../outside/*.json", denied: true},
+		{name: "nested traversal", pattern: "nested/../../outside/secret.json", denied: true},
+		{name: "excluding traversal", pattern: "!../outside/**", denied: true},
+		{name: "sibling sharing the prefix", pattern: filepath.Join(base, "data-secret", "keys.json"), denied: true},
+		{name: "absolute outside", pattern: filepath.Join(base, "outside", "*.json"), denied: true},
+		{name: "absolute traversal", pattern: dataDir + "/../outside/secret.json", denied: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pattern, err := box.Pattern(tt.pattern)
+			if tt.denied {
+				assert.ErrorIs(t, err, ErrAccessDenied)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, pattern)
+		})
+	}
+}
+
+func TestSandbox_Check(t *testing.T) {
+	base, dataDir := setupTree(t)
+
+	tests := []struct {
+		name   string
+		root   string
+		policy SymlinkPolicy
+		path   string
+		denied bool
+	}{
+		{name: "regular file", root: dataDir, path: filepath.Join(dataDir, "users.json")},
+		{name: "sibling sharing the prefix", root: dataDir, path: filepath.Join(base, "data-secret", "keys.json"), denied: true},
+		{name: "file link outside", root: dataDir, path: filepath.Join(dataDir, "secret-link.json"), denied: true},
+		{name: "directory link outside", root: dataDir, path: filepath.Join(dataDir, "outside-dir", "secret.json"), denied: true},
+		{name: "link inside", root: dataDir, path: filepath.Join(dataDir, "orders-link.json")},
+		{name: "root reached through a link", root: filepath.Join(base, "data-link"), path: filepath.Join(base, "data-link", "users.json")},
+		{name: "real path of a linked root", root: filepath.Join(base, "data-link"), path: filepath.Join(dataDir, "users.json")},
+		{name: "archive member", root: dataDir, path: filepath.Join(dataDir, "bundle.zip", "2025", "01.json")},
+		{name: "member of an archive linked outside", root: dataDir, path: filepath.Join(dataDir, "bundle-link.zip", "01.json"), denied: true},
+		{name: "follow allows links outside", root: dataDir, policy: SymlinksFollow, path: filepath.Join(dataDir, "outside-dir", "secret.json")},
+		{name: "follow still checks the path", root: dataDir, policy: SymlinksFollow, path: filepath.Join(base, "outside", "secret.json"), denied: true},
+		{name: "deny rejects links inside", root: dataDir, policy: SymlinksDeny, path: filepath.Join(dataDir, "orders-link.json"), denied: true},
+		{name: "deny allows regular files", root: dataDir, policy: SymlinksDeny, path: filepath.Join(dataDir, "nested", "orders.json")},
+		{name: "deny allows a linked root", root: filepath.Join(base, "data-link"), policy: SymlinksDeny, path: filepath.Join(base, "data-link", "users.json")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			box, err := New(tt.root, tt.policy)
+			require.NoError(t, err)
+
+			err = box.Check(tt.path)
+			if tt.denied {
+				assert.ErrorIs(t, err, ErrAccessDenied)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestParseSymlinkPolicy(t *testing.T) {
+	policy, err := ParseSymlinkPolicy("")
+	require.NoError(t, err)
+	assert.Equal(t, SymlinksWithinRoot, policy)
+
+	policy, err = ParseSymlinkPolicy("deny")
+	require.NoError(t, err)
+	assert.Equal(t, SymlinksDeny, policy)
+
+	_, err = ParseSymlinkPolicy("always")
+	assert.EqualError(t, err, `unsupported symlink policy "always"; use one of within_root, follow, deny`)
+}
@@ -0,0 +1,101 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// methodNotificationsCancelled is sent by clients to abandon a request they issued
+const methodNotificationsCancelled = "notifications/cancelled"
+
+// requestKeyMeta is the _meta field the before-call hook stores a tool call's request key in
+const requestKeyMeta = "gojq-mcp/requestKey"
+
+// requestTracker lets notifications/cancelled stop the tool call it names. mcp-go passes
+// the request ID to hooks but not to tool handlers, and stdio requests share one context,
+// so the before-call hook stores the ID in the request's _meta and the tool middleware
+// reads it back to run the handler with a cancelable context.
+type requestTracker struct {
+	mu      sync.Mutex
+	cancels map[string]context.CancelFunc
+}
+
+func newRequestTracker() *requestTracker {
+	return &requestTracker{cancels: make(map[string]context.CancelFunc)}
+}
+
+// requestKey identifies a request by its client session and JSON-RPC ID
+func requestKey(ctx context.Context, id any) string {
+	sessionID := ""
+	if session := server.ClientSessionFromContext(ctx); session != nil {
+		sessionID = session.SessionID()
+	}
+	return sessionID + "/" + mcp.NewRequestId(id).String()
+}
+
+// beforeCallTool records the ID of a tool call about to run on the call itself
+func (t *requestTracker) beforeCallTool(ctx context.Context, id any, message *mcp.CallToolRequest) {
+	if message.Params.Meta == nil {
+		message.Params.Meta = &mcp.Meta{}
+	}
+	if message.Params.Meta.AdditionalFields == nil {
+		message.Params.Meta.AdditionalFields = make(map[string]any)
+	}
+	message.Params.Meta.AdditionalFields[requestKeyMeta] = requestKey(ctx, id)
+}
+
+// middleware runs each tool handler with a context that handleCancelled can cancel
+func (t *requestTracker) middleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if request.Params.Meta == nil {
+			return next(ctx, request)
+		}
+		key, ok := request.Params.Meta.AdditionalFields[requestKeyMeta].(string)
+		if !ok {
+			return next(ctx, request)
+		}
+		delete(request.Params.Meta.AdditionalFields, requestKeyMeta)
+
+		callCtx, cancel := context.WithCancel(ctx)
+		t.mu.Lock()
+		t.cancels[key] = cancel
+		t.mu.Unlock()
+
+		defer func() {
+			t.mu.Lock()
+			delete(t.cancels, key)
+			t.mu.Unlock()
+			cancel()
+		}()
+
+		return next(callCtx, request)
+	}
+}
+
+// handleCancelled cancels the in-flight tool call named by a notifications/cancelled message
+func (t *requestTracker) handleCancelled(ctx context.Context, notification mcp.JSONRPCNotification) {
+	id, ok := notification.Params.AdditionalFields["requestId"]
+	if !ok {
+		return
+	}
+
+	key := requestKey(ctx, id)
+	t.mu.Lock()
+	cancel, ok := t.cancels[key]
+	t.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	if reason, _ := notification.Params.AdditionalFields["reason"].(string); reason != "" {
+		fmt.Fprintf(os.Stderr, "🛑 Cancelling request %v: %s\n", id, reason)
+	} else {
+		fmt.Fprintf(os.Stderr, "🛑 Cancelling request %v\n", id)
+	}
+	cancel()
+}
@@ -0,0 +1,205 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/berrydev-ai/gojq-mcp/config"
+	"github.com/berrydev-ai/gojq-mcp/registry"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// toolResultTexts returns the text contents and error flag of a tools/call response
+func toolResultTexts(t *testing.T, response mcp.JSONRPCMessage) ([]string, bool) {
+	t.Helper()
+
+	rpcResponse, ok := response.(mcp.JSONRPCResponse)
+	require.True(t, ok, "unexpected response %#v", response)
+	result, ok := rpcResponse.Result.(mcp.CallToolResult)
+	require.True(t, ok)
+
+	var texts []string
+	for _, content := range result.Content {
+		text, ok := content.(mcp.TextContent)
+		require.True(t, ok)
+		texts = append(texts, text.Text)
+	}
+
+	return texts, result.IsError
+}
+
+func TestRunJQCancellation(t *testing.T) {
+	tempDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "data.json"), []byte(`{"n": 1}`), 0644))
+
+	fileRegistry, err := registry.NewFileRegistry(tempDir)
+	require.NoError(t, err)
+
+	cfg := &config.Config{DataPath: tempDir, Transport: "stdio", Port: 8080, QueryTimeout: time.Minute}
+	s, err := SetupMCPServer(cfg, fileRegistry)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	call := json.RawMessage(`{"jsonrpc": "2.0", "id": 42, "method": "tools/call", "params": {"name": "run_jq", "arguments": {"jq_filter": "[range(1e12)] | length", "json_file_path": "data.json"}}}`)
+
+	responses := make(chan mcp.JSONRPCMessage, 1)
+	go func() {
+		responses <- s.HandleMessage(ctx, call)
+	}()
+
+	// Keep cancelling until the call has started and its response arrives
+	cancelled := json.RawMessage(`{"jsonrpc": "2.0", "method": "notifications/cancelled", "params": {"requestId": 42, "reason": "test"}}`)
+	var response mcp.JSONRPCMessage
+	require.Eventually(t, func() bool {
+		s.HandleMessage(ctx, cancelled)
+		select {
+		case response = <-responses:
+			return true
+		default:
+			return false
+		}
+	}, 10*time.Second, 20*time.Millisecond)
+
+	texts, isError := toolResultTexts(t, response)
+	assert.True(t, isError)
+	assert.Equal(t, []string{"query cancelled"}, texts)
+}
+
+func TestRunJQCancellation_ConcurrentStdio(t *testing.T) {
+	tempDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "data.json"), []byte(`{"n": 1}`), 0644))
+
+	fileRegistry, err := registry.NewFileRegistry(tempDir)
+	require.NoError(t, err)
+
+	cfg := &config.Config{DataPath: tempDir, Transport: "stdio", Port: 8080, QueryTimeout: time.Minute}
+	s, err := SetupMCPServer(cfg, fileRegistry)
+	require.NoError(t, err)
+
+	// Every message on stdio shares the session's context
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stdinReader, stdin := io.Pipe()
+	stdout, stdoutWriter := io.Pipe()
+	go server.NewStdioServer(s).Listen(ctx, stdinReader, stdoutWriter)
+
+	responses := make(chan mcp.JSONRPCResponse, 5)
+	go func() {
+		scanner := bufio.NewScanner(stdout)
+		for scanner.Scan() {
+			var response mcp.JSONRPCResponse
+			if json.Unmarshal(scanner.Bytes(), &response) == nil {
+				responses <- response
+			}
+		}
+	}()
+
+	send := func(message string) {
+		_, err := io.WriteString(stdin, message+"\n")
+		require.NoError(t, err)
+	}
+	// The worker pool runs up to five tool calls at once
+	ids := []int64{1, 2, 3, 4, 5}
+	for _, id := range ids {
+		send(fmt.Sprintf(`{"jsonrpc": "2.0", "id": %d, "method": "tools/call", "params": {"name": "run_jq", "arguments": {"jq_filter": "[range(1e12)] | length", "json_file_path": "data.json"}}}`, id))
+	}
+
+	// Cancelling one call leaves the others running
+	for i := len(ids) - 1; i >= 0; i-- {
+		cancelled := fmt.Sprintf(`{"jsonrpc": "2.0", "method": "notifications/cancelled", "params": {"requestId": %d}}`, ids[i])
+		var response mcp.JSONRPCResponse
+		require.Eventually(t, func() bool {
+			send(cancelled)
+			select {
+			case response = <-responses:
+				return true
+			default:
+				return false
+			}
+		}, 10*time.Second, 20*time.Millisecond)
+		require.Equal(t, mcp.NewRequestId(ids[i]), response.ID, "cancelling request %d", ids[i])
+	}
+}
+
+func TestRequestTracker_SharedContext(t *testing.T) {
+	tracker := newRequestTracker()
+	ctx := context.Background()
+
+	// Both hooks run before either handler starts, as they can for concurrent stdio calls
+	first, second := &mcp.CallToolRequest{}, &mcp.CallToolRequest{}
+	tracker.beforeCallTool(ctx, 1, first)
+	tracker.beforeCallTool(ctx, 2, second)
+
+	started := make(chan struct{}, 2)
+	handler := tracker.middleware(func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		started <- struct{}{}
+		<-ctx.Done()
+		return mcp.NewToolResultText(request.Params.Name), nil
+	})
+	results := make(chan string, 2)
+	for name, request := range map[string]*mcp.CallToolRequest{"first": first, "second": second} {
+		request.Params.Name = name
+		go func(request mcp.CallToolRequest) {
+			result, _ := handler(ctx, request)
+			results <- result.Content[0].(mcp.TextContent).Text
+		}(*request)
+	}
+	<-started
+	<-started
+
+	tracker.handleCancelled(ctx, mcp.JSONRPCNotification{Notification: mcp.Notification{
+		Method: methodNotificationsCancelled,
+		Params: mcp.NotificationParams{AdditionalFields: map[string]any{"requestId": 2}},
+	}})
+	select {
+	case name := <-results:
+		assert.Equal(t, "second", name)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for request 2 to be cancelled")
+	}
+	select {
+	case name := <-results:
+		t.Fatalf("%s call stopped when request 2 was cancelled", name)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	tracker.handleCancelled(ctx, mcp.JSONRPCNotification{Notification: mcp.Notification{
+		Method: methodNotificationsCancelled,
+		Params: mcp.NotificationParams{AdditionalFields: map[string]any{"requestId": 1}},
+	}})
+	select {
+	case name := <-results:
+		assert.Equal(t, "first", name)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for request 1 to be cancelled")
+	}
+}
+
+func TestRunJQTimeout(t *testing.T) {
+	tempDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "data.json"), []byte(`{"n": 1}`), 0644))
+
+	fileRegistry, err := registry.NewFileRegistry(tempDir)
+	require.NoError(t, err)
+
+	cfg := &config.Config{DataPath: tempDir, Transport: "stdio", Port: 8080, QueryTimeout: 50 * time.Millisecond}
+	s, err := SetupMCPServer(cfg, fileRegistry)
+	require.NoError(t, err)
+
+	response := s.HandleMessage(context.Background(), json.RawMessage(`{"jsonrpc": "2.0", "id": 1, "method": "tools/call", "params": {"name": "run_jq", "arguments": {"jq_filter": "last(range(1e12))", "json_file_path": "data.json"}}}`))
+
+	texts, isError := toolResultTexts(t, response)
+	assert.True(t, isError)
+	require.Len(t, texts, 1)
+	assert.Contains(t, texts[0], "query timed out after 50ms")
+}
@@ -0,0 +1,120 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/berrydev-ai/gojq-mcp/config"
+	"github.com/berrydev-ai/gojq-mcp/jq"
+	"github.com/berrydev-ai/gojq-mcp/registry"
+	"github.com/berrydev-ai/gojq-mcp/sandbox"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// fileResource describes a registry file as an MCP resource
+func fileResource(fileRegistry *registry.FileRegistry, file registry.FileInfo) mcp.Resource {
+	relPath := fileRegistry.RelativePath(file.Path)
+
+	resource := mcp.NewResource(
+		registry.ResourceURI(relPath),
+		relPath,
+		mcp.WithResourceDescription(fmt.Sprintf("Data file (%d bytes, modified %s)", file.Size, file.Modified.Format(time.RFC3339))),
+		mcp.WithMIMEType(registry.MIMEType(file.Path)),
+	)
+	resource.Meta = &mcp.Meta{
+		AdditionalFields: map[string]any{
+			"size":     file.Size,
+			"modified": file.Modified,
+		},
+	}
+
+	return resource
+}
+
+// listFileResources returns a hook that adds every registry file to resources/list results.
+// Files are listed from the registry on each request so the list always matches the
+// notifications/resources/list_changed notifications sent by the watcher.
+func listFileResources(fileRegistry *registry.FileRegistry) server.OnAfterListResourcesFunc {
+	return func(ctx context.Context, id any, message *mcp.ListResourcesRequest, result *mcp.ListResourcesResult) {
+		if result == nil {
+			return
+		}
+		for _, file := range fileRegistry.GetFiles() {
+			result.Resources = append(result.Resources, fileResource(fileRegistry, file))
+		}
+	}
+}
+
+// readFileResource serves resources/read for data files, applying the jq filter
+// from the URI's filter parameter when one is given
+func readFileResource(cfg *config.Config, fileRegistry *registry.FileRegistry) server.ResourceTemplateHandlerFunc {
+	return func(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+		uri := request.Params.URI
+
+		relPath, query, err := registry.ParseResourceURI(uri)
+		if err != nil {
+			return nil, err
+		}
+
+		file, ok := fileRegistry.Lookup(relPath)
+		if !ok {
+			return nil, fmt.Errorf("resource not found: %s", uri)
+		}
+
+		// Listed files can be symbolic links, so they are checked like run_jq paths
+		box, err := sandbox.New(cfg.DataPath, cfg.SymlinkPolicy())
+		if err != nil {
+			return nil, err
+		}
+		if err := box.Check(file.Path); err != nil {
+			return nil, err
+		}
+
+		filter := query.Get("filter")
+		if filter == "" {
+			// Compressed files are served decompressed, no larger than a query may read
+			reader, err := jq.OpenFile(file.Path)
+			if err != nil {
+				return nil, fmt.Errorf("file %s is not readable: %w", relPath, err)
+			}
+			limit := cfg.MemoryLimit()
+			data, err := io.ReadAll(io.LimitReader(reader, limit+1))
+			reader.Close()
+			if err != nil {
+				return nil, fmt.Errorf("file %s is not readable: %w", relPath, err)
+			}
+			if int64(len(data)) > limit {
+				return nil, fmt.Errorf("file %s is larger than the memory limit of %d bytes; add ?filter=<jq filter> to the URI to read part of it, or use run_jq with stream=true", relPath, limit)
+			}
+			return []mcp.ResourceContents{
+				mcp.TextResourceContents{
+					URI:      uri,
+					MIMEType: registry.MIMEType(file.Path),
+					Text:     string(data),
+				},
+			}, nil
+		}
+
+		// Resources are read in full, so only the memory and time limits apply
+		result, err := jq.ExecuteJQFilesWithOptions(ctx, filter, []string{file.Path}, jq.Options{
+			MemoryLimit: cfg.MemoryLimit(),
+			Timeout:     cfg.Timeout(),
+			Cache:       fileRegistry.DocumentCache(),
+			BaseDir:     fileRegistry.RootPath(),
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		return []mcp.ResourceContents{
+			mcp.TextResourceContents{
+				URI:      uri,
+				MIMEType: "application/json",
+				Text:     result.Output,
+			},
+		}, nil
+	}
+}
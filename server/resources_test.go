@@ -0,0 +1,167 @@
+package server
+
+import (
+	"compress/gzip"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/berrydev-ai/gojq-mcp/config"
+	"github.com/berrydev-ai/gojq-mcp/registry"
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestClient starts an initialized in-process client for a server over dataPath
+func newTestClient(t *testing.T, dataPath string) *client.Client {
+	t.Helper()
+	return newConfiguredTestClient(t, &config.Config{DataPath: dataPath, Transport: "stdio", Port: 8080})
+}
+
+// newConfiguredTestClient starts an initialized in-process client for a server using cfg
+func newConfiguredTestClient(t *testing.T, cfg *config.Config) *client.Client {
+	t.Helper()
+
+	fileRegistry, err := registry.NewFileRegistry(cfg.DataPath)
+	require.NoError(t, err)
+
+	s, err := SetupMCPServer(cfg, fileRegistry)
+	require.NoError(t, err)
+
+	c, err := client.NewInProcessClient(s)
+	require.NoError(t, err)
+	t.Cleanup(func() { c.Close() })
+
+	ctx := context.Background()
+	require.NoError(t, c.Start(ctx))
+
+	initRequest := mcp.InitializeRequest{}
+	initRequest.Params.ProtocolVersion = mcp.LATEST_PROTOCOL_VERSION
+	initRequest.Params.ClientInfo = mcp.Implementation{Name: "test-client", Version: "1.0.0"}
+	_, err = c.Initialize(ctx, initRequest)
+	require.NoError(t, err)
+
+	return c
+}
+
+func TestFileResources(t *testing.T) {
+	tempDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(tempDir, "sub dir"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "users.json"), []byte(`{"users": [{"name": "Alice"}, {"name": "Bob"}]}`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "sub dir", "2025-01.json"), []byte(`[1, 2, 3]`), 0644))
+
+	c := newTestClient(t, tempDir)
+	ctx := context.Background()
+
+	listResult, err := c.ListResources(ctx, mcp.ListResourcesRequest{})
+	require.NoError(t, err)
+	require.Len(t, listResult.Resources, 2)
+
+	resource := listResult.Resources[0]
+	assert.Equal(t, "gojq://data/sub%20dir/2025-01.json", resource.URI)
+	assert.Equal(t, "sub dir/2025-01.json", resource.Name)
+	assert.Equal(t, "application/json", resource.MIMEType)
+	require.NotNil(t, resource.Meta)
+	assert.EqualValues(t, 9, resource.Meta.AdditionalFields["size"])
+	assert.NotEmpty(t, resource.Meta.AdditionalFields["modified"])
+
+	templates, err := c.ListResourceTemplates(ctx, mcp.ListResourceTemplatesRequest{})
+	require.NoError(t, err)
+	require.Len(t, templates.ResourceTemplates, 1)
+	assert.Equal(t, registry.ResourceURITemplate, templates.ResourceTemplates[0].URITemplate.Raw())
+
+	tests := []struct {
+		name      string
+		uri       string
+		expected  string
+		expectErr bool
+	}{
+		{
+			name:     "raw file",
+			uri:      resource.URI,
+			expected: `[1, 2, 3]`,
+		},
+		{
+			name:     "filtered view",
+			uri:      "gojq://data/users.json?filter=" + "%5B.users%5B%5D.name%5D",
+			expected: "[\n  \"Alice\",\n  \"Bob\"\n]",
+		},
+		{
+			name:     "file names relative to the data directory",
+			uri:      resource.URI + "?filter=input_filename",
+			expected: `"sub dir/2025-01.json"`,
+		},
+		{
+			name:     "file metadata keyed by relative name",
+			uri:      resource.URI + "?filter=%24__file%20%7C%20keys",
+			expected: "[\n  \"sub dir/2025-01.json\"\n]",
+		},
+		{
+			name:      "unknown file",
+			uri:       "gojq://data/missing.json",
+			expectErr: true,
+		},
+		{
+			name:      "path outside data directory",
+			uri:       "gojq://data/../secret.json",
+			expectErr: true,
+		},
+		{
+			name:      "invalid filter",
+			uri:       "gojq://data/users.json?filter=.users%5B",
+			expectErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := mcp.ReadResourceRequest{}
+			request.Params.URI = tt.uri
+
+			result, err := c.ReadResource(ctx, request)
+			if tt.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Len(t, result.Contents, 1)
+
+			contents, ok := result.Contents[0].(mcp.TextResourceContents)
+			require.True(t, ok)
+			assert.Equal(t, tt.uri, contents.URI)
+			assert.Equal(t, "application/json", contents.MIMEType)
+			assert.Equal(t, tt.expected, contents.Text)
+		})
+	}
+}
+
+func TestFileResources_MemoryLimit(t *testing.T) {
+	tempDir := t.TempDir()
+	file, err := os.Create(filepath.Join(tempDir, "large.json.gz"))
+	require.NoError(t, err)
+	writer := gzip.NewWriter(file)
+	_, err = writer.Write([]byte(`["` + strings.Repeat("x", 2<<20) + `"]`))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+	require.NoError(t, file.Close())
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "small.json"), []byte(`[1]`), 0644))
+
+	c := newConfiguredTestClient(t, &config.Config{DataPath: tempDir, Transport: "stdio", Port: 8080, MaxMemoryMB: 1})
+	ctx := context.Background()
+
+	request := mcp.ReadResourceRequest{}
+	request.Params.URI = "gojq://data/large.json.gz"
+	_, err = c.ReadResource(ctx, request)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "larger than the memory limit of 1048576 bytes; add ?filter=<jq filter>")
+
+	// Files within the limit are still served whole
+	request.Params.URI = "gojq://data/small.json"
+	result, err := c.ReadResource(ctx, request)
+	require.NoError(t, err)
+	assert.Equal(t, "[1]", result.Contents[0].(mcp.TextResourceContents).Text)
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"math/rand"
 	"net/http"
 	"os"
 	"strings"
@@ -16,10 +17,71 @@ import (
 	"github.com/mark3labs/mcp-go/server"
 )
 
-// SetupMCPServer creates and configures the MCP server with tools and prompts
+// Server is a configured MCP server together with the file registry it publishes
+type Server struct {
+	MCPServer *server.MCPServer
+	Registry  *registry.FileRegistry
+	cfg       *config.Config
+}
+
+// NewServer creates the file registry for cfg.DataPath and the MCP server that publishes it.
+// When watch is true the data directory is monitored and clients are notified of changes.
+func NewServer(cfg *config.Config, watch bool) (*Server, error) {
+	fileRegistry, err := registry.NewFileRegistry(cfg.DataPath)
+	if err != nil {
+		return nil, fmt.Errorf("error initializing file registry: %w", err)
+	}
+
+	s, err := SetupMCPServer(cfg, fileRegistry)
+	if err != nil {
+		fileRegistry.Close()
+		return nil, err
+	}
+
+	if watch {
+		if err := fileRegistry.StartWatching(); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: could not enable file watching: %v\n", err)
+			fmt.Fprintf(os.Stderr, "Continuing without file watching...\n")
+		} else if cfg.Transport != "stdio" {
+			fmt.Fprintf(os.Stderr, "✅ Push notifications enabled - clients will be notified of file changes\n")
+		}
+	}
+
+	return &Server{MCPServer: s, Registry: fileRegistry, cfg: cfg}, nil
+}
+
+// Start serves the MCP server over the configured transport
+func (s *Server) Start(authToken string) error {
+	return StartServer(s.MCPServer, s.cfg, s.Registry, authToken)
+}
+
+// Close stops watching the data directory
+func (s *Server) Close() error {
+	return s.Registry.Close()
+}
+
+// SetupMCPServer creates and configures the MCP server with tools and prompts, and attaches
+// it to fileRegistry so file changes are pushed to connected clients
 func SetupMCPServer(cfg *config.Config, fileRegistry *registry.FileRegistry) (*server.MCPServer, error) {
+	tracker := newRequestTracker()
+
+	hooks := &server.Hooks{}
+	hooks.AddBeforeCallTool(tracker.beforeCallTool)
+	hooks.AddAfterListResources(listFileResources(fileRegistry))
+	hooks.AddOnRequestInitialization(rejectSubscriptions())
+	if cfg.Transport == "sse" {
+		// SSE sessions end when their event stream closes; streamable HTTP sessions
+		// outlive their GET streams and are cleaned up on DELETE by subscriptionHandler
+		hooks.AddOnUnregisterSession(func(ctx context.Context, session server.ClientSession) {
+			fileRegistry.RemoveSession(session.SessionID())
+		})
+	}
+
 	serverOpts := []server.ServerOption{
 		server.WithToolCapabilities(false),
+		server.WithResourceCapabilities(subscriptionsSupported(cfg.Transport), true),
+		server.WithHooks(hooks),
+		server.WithToolHandlerMiddleware(tracker.middleware),
 		server.WithRecovery(),
 	}
 
@@ -28,6 +90,7 @@ func SetupMCPServer(cfg *config.Config, fileRegistry *registry.FileRegistry) (*s
 	}
 
 	s := server.NewMCPServer("GoJQ MCP Server", "1.0.5", serverOpts...)
+	s.AddNotificationHandler(methodNotificationsCancelled, tracker.handleCancelled)
 
 	// Register prompts
 	for _, promptConfig := range cfg.Prompts {
@@ -47,6 +110,7 @@ func SetupMCPServer(cfg *config.Config, fileRegistry *registry.FileRegistry) (*s
 					for k, v := range request.Params.Arguments {
 						exampleQuery += fmt.Sprintf("\n- %s: %v", k, v)
 					}
+					exampleQuery += "\n\nPass these to run_jq in its variables parameter and refer to them as $name in the filter instead of writing their values into it."
 				}
 
 				return mcp.NewGetPromptResult(
@@ -62,6 +126,17 @@ func SetupMCPServer(cfg *config.Config, fileRegistry *registry.FileRegistry) (*s
 		}(promptConfig))
 	}
 
+	// Register a template so any data file, or a jq-filtered view of it, can be read as a resource
+	s.AddResourceTemplate(
+		mcp.NewResourceTemplate(
+			registry.ResourceURITemplate,
+			"Data file",
+			mcp.WithTemplateDescription("A JSON, JSON Lines, YAML, TOML or CSV data file relative to the data directory. Add ?filter=<jq filter> to read a jq-filtered view of the file."),
+			mcp.WithTemplateMIMEType("application/json"),
+		),
+		readFileResource(cfg, fileRegistry),
+	)
+
 	// Add run_jq tool
 	runJqTool := mcp.NewTool("run_jq",
 		mcp.WithDescription(`Queries JSON data using jq syntax. Supports single files, multiple files, and glob patterns.
@@ -70,7 +145,17 @@ FILE SPECIFICATION (relative to data directory):
 - Single file: "file.json"
 - Multiple files: "file1.json file2.json file3.json"
 - Glob patterns: "subdir/*.json"
+- Any depth: "**/*.json"
+- Alternatives: "2025-{01,02}/*.json"
+- Exclusions: "**/*.json !drafts/**" (a leading ! removes the files that pattern matches)
 - Mixed: "schema.json segments/*.json"
+- JSON Lines: "events.jsonl" (.jsonl/.ndjson files provide one input per record)
+- YAML, TOML and CSV: "config.yaml", "settings.toml", "export.csv" (YAML files provide one input per
+  document; CSV files are an array of objects keyed by the header row, with string values)
+- Compressed: "archive/*.json.gz" (.gz, .zst and .bz2 files are decompressed as they are read)
+- Archives: "bundle.zip/2025/*.json" (members of .zip, .tar, .tar.gz and .tgz archives are addressed
+  as paths below the archive; patterns only look inside archives they name: "**/*.zip/**/*.json"
+  matches members, and "!bundle.zip/**" leaves an archive out)
 
 JQ FILTER EXAMPLES:
 - Identity: '.'
@@ -79,11 +164,41 @@ JQ FILTER EXAMPLES:
 - Filter: '.users[] | select(.age > 30)'
 - Multi-file collection: '[inputs]' (collects all input files into an array)
 - Multi-file processing: 'inputs | .name' (processes each file separately)
+- JSON Lines records: 'inputs | select(.level == "error")' or '[inputs] | length'
+
+MODES:
+By default a single file is '.' and several files are read through 'inputs'. Set mode to get the same
+behavior whatever the number of files:
+- inputs: '.' is null and every document is read through 'input'/'inputs'
+- slurp: '.' is an array of every document, e.g. 'map(.total) | add'
+- each: the filter runs once per file with its contents as '.' (JSON Lines and multi-document YAML files
+  are arrays), and each result is returned as {"file": path, "result": value}
+
+LARGE FILES:
+Set stream=true to read files as jq --stream events ([path, leaf] and closing [path]) through 'inputs'
+instead of loading whole documents. Examples:
+- Count leaves: '[inputs | select(length == 2)] | length'
+- Elements of a top-level array one at a time: 'fromstream(1 | truncate_stream(inputs)) | .id'
+Queries are stopped when the files they decode whole exceed the server's memory limit or when they run longer than its query timeout.
+
+VARIABLES:
+Bind values with the variables object and refer to them as $name instead of quoting them into the filter.
+Example: variables={"min_age": 30, "team": "core"} with '.users[] | select(.age > $min_age and .team == $team)'
+
+SOURCE FILES:
+input_filename returns the path of the file the current input came from, relative to the data directory.
+$__file maps each of those paths to {"size", "modified"}.
+Example: 'reduce inputs as $row ({}; .[input_filename] += ($row.conversions | length))'
+
+LARGE RESULTS:
+Results beyond the server's size limits are left out and a note with the total count is added.
+Use offset and limit to page through them, e.g. offset=0 limit=100, then offset=100 limit=100.
 
 REAL-TIME UPDATES:
 When file watching is enabled, this server automatically notifies clients when files change.
 
-TIP: Use 'list_data_files' first to discover available files.`),
+TIP: Use 'list_data_files' first to discover available files, and 'infer_schema' to learn their structure
+before writing a filter. 'explain_jq' checks a filter for syntax errors and costly patterns without running it.`),
 		mcp.WithString("jq_filter",
 			mcp.Required(),
 			mcp.Description("The jq filter to execute. Use 'inputs' function for multi-file queries."),
@@ -92,6 +207,31 @@ TIP: Use 'list_data_files' first to discover available files.`),
 			mcp.Required(),
 			mcp.Description("Space-separated string of file paths (relative to data directory) or glob patterns."),
 		),
+		mcp.WithString("mode",
+			mcp.Description("How documents are given to the filter: inputs (read through 'inputs'), slurp ('.' is an array of all documents) or each (run once per file, results tagged with the file). Omit for the default, where a single file is '.'."),
+			mcp.Enum(modeNames()...),
+		),
+		mcp.WithBoolean("stream",
+			mcp.Description("Read files as jq --stream events through 'inputs' instead of whole documents. Use for files too large to load."),
+		),
+		mcp.WithNumber("offset",
+			mcp.Description("Number of results to skip. Use with limit to page through large result streams."),
+			mcp.Min(0),
+		),
+		mcp.WithNumber("limit",
+			mcp.Description("Maximum number of results to return. When offset or limit is set, results are always returned as an array."),
+			mcp.Min(0),
+		),
+		mcp.WithString("output_format",
+			mcp.Description("How to render results: json (default, indented), compact (single-line JSON), raw (one result per line, strings unquoted like jq -r), jsonl, csv, tsv or markdown_table. Table formats use object keys as columns; a single array of objects is rendered one row per element."),
+			mcp.Enum(outputFormatNames()...),
+		),
+		mcp.WithBoolean("always_array",
+			mcp.Description("Return json/compact results as an array even when the query produces a single result, so the shape is predictable."),
+		),
+		mcp.WithObject("variables",
+			mcp.Description("Values to bind as jq variables, e.g. {\"min_age\": 30} is available as $min_age in the filter. Names use letters, digits and underscores."),
+		),
 	)
 
 	s.AddTool(runJqTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
@@ -110,27 +250,382 @@ TIP: Use 'list_data_files' first to discover available files.`),
 			return mcp.NewToolResultError("json_file_path cannot be empty"), nil
 		}
 
-		results, err := jq.ProcessJQQuery(jqFilter, patterns, cfg.DataPath)
+		opts := cfg.QueryOptions()
+		opts.Cache = fileRegistry.DocumentCache()
+		opts.Stream = request.GetBool("stream", false)
+		if opts.Mode, err = jq.ParseMode(request.GetString("mode", "")); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		opts.Offset = request.GetInt("offset", 0)
+		opts.Limit = request.GetInt("limit", 0)
+		if opts.Offset < 0 || opts.Limit < 0 {
+			return mcp.NewToolResultError("offset and limit must not be negative"), nil
+		}
+		opts.AlwaysArray = request.GetBool("always_array", false)
+		if opts.OutputFormat, err = jq.ParseOutputFormat(request.GetString("output_format", "")); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		if variables, ok := request.GetArguments()["variables"]; ok && variables != nil {
+			if opts.Variables, ok = variables.(map[string]interface{}); !ok {
+				return mcp.NewToolResultError("variables must be an object mapping names to values"), nil
+			}
+		}
+
+		result, err := jq.ProcessJQQueryWithOptions(ctx, jqFilter, patterns, cfg.DataPath, opts)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		toolResult := mcp.NewToolResultText(result.Output)
+		if summary := result.Summary(); summary != "" {
+			// Reported separately so the result text stays valid JSON when results are left out
+			toolResult.Content = append(toolResult.Content, mcp.NewTextContent(summary))
+		}
+		return toolResult, nil
+	})
+
+	// Add infer_schema tool
+	inferSchemaTool := mcp.NewTool("infer_schema",
+		mcp.WithDescription(`Summarizes the structure of data files so filters can be written without exploring them first.
+
+Reads every document of the matched files (same pattern syntax as run_jq's json_file_path; each JSON Lines
+record is a document) and returns one merged schema listing every field path as a jq path, such as
+.users[].email, with:
+- types: how many values of each jq type were seen (null, boolean, number, string, array, object)
+- optional: some objects holding the field do not have it
+- nullable: null was seen
+- array_length: the shortest and longest arrays seen
+- examples: up to 3 distinct example values
+
+Set json_schema=true to also get a JSON Schema draft 2020-12 document the files satisfy.
+Documents with more than 1000 paths are summarized partially and marked truncated.`),
+		mcp.WithString("json_file_path",
+			mcp.Required(),
+			mcp.Description("Space-separated string of file paths (relative to data directory) or glob patterns."),
+		),
+		mcp.WithBoolean("json_schema",
+			mcp.Description("Also return a JSON Schema draft 2020-12 document describing the files."),
+		),
+	)
+
+	s.AddTool(inferSchemaTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		jsonFilePath, err := request.RequireString("json_file_path")
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		patterns := strings.Fields(jsonFilePath)
+		if len(patterns) == 0 {
+			return mcp.NewToolResultError("json_file_path cannot be empty"), nil
+		}
+
+		opts := cfg.QueryOptions()
+		opts.Cache = fileRegistry.DocumentCache()
+		schema, err := jq.InferSchema(ctx, patterns, cfg.DataPath, opts)
 		if err != nil {
 			return mcp.NewToolResultError(err.Error()), nil
 		}
 
-		return mcp.NewToolResultText(results), nil
+		response := struct {
+			*jq.Schema
+			JSONSchema map[string]interface{} `json:"json_schema,omitempty"`
+		}{Schema: schema}
+		if request.GetBool("json_schema", false) {
+			response.JSONSchema = schema.JSONSchema()
+		}
+		output, err := json.MarshalIndent(response, "", "  ")
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("error formatting schema: %v", err)), nil
+		}
+		return mcp.NewToolResultText(string(output)), nil
+	})
+
+	// Add sample_records tool
+	sampleRecordsTool := mcp.NewTool("sample_records",
+		mcp.WithDescription(`Returns a few real records from data files as a preview, without the flood of output that running '.' produces.
+
+Records are the elements of the array that path selects in each document (default '.', so a top-level array's
+elements, or each JSON Lines record). Each record is returned with its file and its index among that file's
+records, and strings longer than max_string_length are cut short and end with '…'.
+
+METHODS:
+- head (default): the first records; stops reading once it has them
+- tail: the last records
+- random: a uniform random sample; reads the files twice
+- reservoir: a uniform random sample read in a single pass
+Random and reservoir samples report their seed; pass it back as seed to draw the same sample again.
+
+Examples:
+- path='.users' count=5 method='random'
+- path='.orders[] | .items' count=20 method='reservoir' seed=42`),
+		mcp.WithString("json_file_path",
+			mcp.Required(),
+			mcp.Description("Space-separated string of file paths (relative to data directory) or glob patterns."),
+		),
+		mcp.WithString("path",
+			mcp.Description("jq expression selecting the array of records in each document, e.g. '.users'. Defaults to '.'."),
+		),
+		mcp.WithNumber("count",
+			mcp.Description(fmt.Sprintf("Number of records to return (default %d, at most %d).", jq.DefaultSampleCount, jq.MaxSampleCount)),
+			mcp.Min(1),
+			mcp.Max(jq.MaxSampleCount),
+		),
+		mcp.WithString("method",
+			mcp.Description("How records are chosen: head (default), tail, random or reservoir."),
+			mcp.Enum(sampleMethodNames()...),
+		),
+		mcp.WithNumber("seed",
+			mcp.Description("Seed for random and reservoir sampling, to repeat a sample. A new seed is chosen when omitted."),
+		),
+		mcp.WithNumber("max_string_length",
+			mcp.Description(fmt.Sprintf("Strings longer than this many bytes are cut short (default %d, 0 keeps them whole).", jq.DefaultSampleStringLength)),
+			mcp.Min(0),
+		),
+	)
+
+	s.AddTool(sampleRecordsTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		jsonFilePath, err := request.RequireString("json_file_path")
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		patterns := strings.Fields(jsonFilePath)
+		if len(patterns) == 0 {
+			return mcp.NewToolResultError("json_file_path cannot be empty"), nil
+		}
+
+		sample := jq.SampleOptions{
+			Path:            request.GetString("path", ""),
+			Count:           request.GetInt("count", jq.DefaultSampleCount),
+			MaxStringLength: request.GetInt("max_string_length", jq.DefaultSampleStringLength),
+		}
+		if sample.Method, err = jq.ParseSampleMethod(request.GetString("method", "")); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		if _, ok := request.GetArguments()["seed"]; ok {
+			sample.Seed = int64(request.GetInt("seed", 0))
+		} else {
+			// Kept below 2^53 so the seed survives a round trip through a JSON number
+			sample.Seed = rand.Int63n(1 << 53)
+		}
+		if sample.MaxStringLength < 0 {
+			return mcp.NewToolResultError("max_string_length must not be negative"), nil
+		}
+
+		opts := cfg.QueryOptions()
+		opts.Cache = fileRegistry.DocumentCache()
+		records, err := jq.SampleRecords(ctx, patterns, cfg.DataPath, sample, opts)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		output, err := json.MarshalIndent(records, "", "  ")
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("error formatting records: %v", err)), nil
+		}
+		return mcp.NewToolResultText(string(output)), nil
+	})
+
+	// Add validate_json_schema tool
+	validateTool := mcp.NewTool("validate_json_schema",
+		mcp.WithDescription(`Validates data files against a JSON Schema and lists what each file gets wrong.
+
+Give the schema either as schema_path, a JSON or YAML file in the data directory, or inline as schema. Schemas
+without "$schema" are read as draft 2020-12, and "format" keywords (email, date-time, uri, ...) are checked.
+A relative $ref names another schema file in the data directory.
+
+Returns {"valid": bool, "files": [...]} with, for every matched file, its number of documents and its errors.
+Each error has a JSON Pointer "location" of the offending value (e.g. /users/3/email), the schema "keyword" that
+failed (e.g. /properties/users/items/properties/email/format) and a message. JSON Lines records and
+multi-document YAML files also give the "document" index. Files that cannot be parsed report a read_error.
+At most 100 errors are listed per file. The schema file itself is skipped when the patterns match it.`),
+		mcp.WithString("json_file_path",
+			mcp.Required(),
+			mcp.Description("Space-separated string of file paths (relative to data directory) or glob patterns."),
+		),
+		mcp.WithString("schema_path",
+			mcp.Description("Path of the schema file, relative to the data directory. Use either schema_path or schema."),
+		),
+		mcp.WithObject("schema",
+			mcp.Description("Inline JSON Schema document. Use either schema_path or schema."),
+		),
+	)
+
+	s.AddTool(validateTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		jsonFilePath, err := request.RequireString("json_file_path")
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		patterns := strings.Fields(jsonFilePath)
+		if len(patterns) == 0 {
+			return mcp.NewToolResultError("json_file_path cannot be empty"), nil
+		}
+
+		source := jq.SchemaSource{Path: request.GetString("schema_path", "")}
+		if schema, ok := request.GetArguments()["schema"]; ok && schema != nil {
+			if source.Document, ok = schema.(map[string]interface{}); !ok {
+				return mcp.NewToolResultError("schema must be a JSON Schema object"), nil
+			}
+		}
+		if (source.Path == "") == (source.Document == nil) {
+			return mcp.NewToolResultError("give either schema_path or schema"), nil
+		}
+
+		opts := cfg.QueryOptions()
+		opts.Cache = fileRegistry.DocumentCache()
+		report, err := jq.ValidateJSONSchema(ctx, patterns, cfg.DataPath, source, opts)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		output, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("error formatting report: %v", err)), nil
+		}
+		return mcp.NewToolResultText(string(output)), nil
+	})
+
+	// Add diff_json tool
+	diffTool := mcp.NewTool("diff_json",
+		mcp.WithDescription(`Compares two data files, or the results of jq filters on them, and lists what changed.
+
+Give left_path and right_path (relative to the data directory, one file each; they may be the same file). An optional
+left_filter or right_filter selects what is compared on that side; a filter producing several results compares the
+array of them. JSON Lines and multi-document YAML files are compared as arrays of their records.
+
+Returns {"patch": [...], "changes": [...]} followed by a readable summary:
+- patch is an RFC 6902 JSON Patch turning the left value into the right one (add, remove, replace and move operations)
+- changes lists each added, removed, changed or moved value with its jq path and its old and new values
+
+Arrays are compared by position. Give array_key (e.g. "id") to match elements of arrays of objects by that field
+instead, so reordered or inserted records do not show up as changes to every element after them. Paths of matched
+elements read like .users[id=42].email. Arrays whose elements do not all have a distinct scalar key are still
+compared by position.
+
+EXAMPLE: compare two daily snapshots by record id
+  left_path: "snapshots/2025-01-01.json", right_path: "snapshots/2025-01-02.json", array_key: "id"`),
+		mcp.WithString("left_path",
+			mcp.Required(),
+			mcp.Description("File to compare from, relative to the data directory."),
+		),
+		mcp.WithString("right_path",
+			mcp.Required(),
+			mcp.Description("File to compare to, relative to the data directory."),
+		),
+		mcp.WithString("left_filter",
+			mcp.Description("jq filter selecting what is compared in the left file. Defaults to the whole file."),
+		),
+		mcp.WithString("right_filter",
+			mcp.Description("jq filter selecting what is compared in the right file. Defaults to the whole file."),
+		),
+		mcp.WithString("array_key",
+			mcp.Description("Field matching elements of arrays of objects, e.g. \"id\". Defaults to comparing by position."),
+		),
+	)
+
+	s.AddTool(diffTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		leftPath, err := request.RequireString("left_path")
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		rightPath, err := request.RequireString("right_path")
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		if strings.TrimSpace(leftPath) == "" || strings.TrimSpace(rightPath) == "" {
+			return mcp.NewToolResultError("left_path and right_path cannot be empty"), nil
+		}
+
+		left := jq.DiffSource{Path: strings.TrimSpace(leftPath), Filter: request.GetString("left_filter", "")}
+		right := jq.DiffSource{Path: strings.TrimSpace(rightPath), Filter: request.GetString("right_filter", "")}
+
+		opts := cfg.QueryOptions()
+		opts.Cache = fileRegistry.DocumentCache()
+		diff, err := jq.DiffJSON(ctx, cfg.DataPath, left, right, request.GetString("array_key", ""), opts)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		output, err := json.MarshalIndent(diff, "", "  ")
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("error formatting diff: %v", err)), nil
+		}
+		toolResult := mcp.NewToolResultText(string(output))
+		toolResult.Content = append(toolResult.Content, mcp.NewTextContent(diff.Summary()))
+		return toolResult, nil
+	})
+
+	// Add explain_jq tool
+	explainTool := mcp.NewTool("explain_jq",
+		mcp.WithDescription(`Checks a jq filter without running it and describes what it uses.
+
+Returns {"valid": bool, "functions": [...], "variables": [...], "uses_inputs": bool, "warnings": [...]}:
+- error: why the filter cannot run. Syntax errors give the line, column and a snippet with a caret under the
+  problem; undefined functions and variables are reported by name
+- functions: functions the filter calls as name/arity (e.g. map/1); "defined" lists those it defines with def
+- variables: variables the filter refers to; pass the ones it does not bind itself as run_jq's variables
+- uses_inputs: whether the filter reads further inputs with input or inputs, as multi-file queries do
+- warnings: patterns that are slow or never finish, such as '..' on large files, recurse(f) or repeat without
+  limit, and range with millions of values
+
+Use it to fix a filter run_jq rejected, or to check a costly-looking filter before running it on large files.`),
+		mcp.WithString("jq_filter",
+			mcp.Required(),
+			mcp.Description("The jq filter to check."),
+		),
+		mcp.WithObject("variables",
+			mcp.Description("Variables the filter will be run with, as for run_jq, so references to them are not reported as undefined."),
+		),
+	)
+
+	s.AddTool(explainTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		jqFilter, err := request.RequireString("jq_filter")
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		opts := cfg.QueryOptions()
+		if variables, ok := request.GetArguments()["variables"]; ok && variables != nil {
+			if opts.Variables, ok = variables.(map[string]interface{}); !ok {
+				return mcp.NewToolResultError("variables must be an object mapping names to values"), nil
+			}
+		}
+
+		explanation, err := jq.ExplainFilter(jqFilter, opts)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		output, err := json.MarshalIndent(explanation, "", "  ")
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("error formatting explanation: %v", err)), nil
+		}
+		return mcp.NewToolResultText(string(output)), nil
 	})
 
 	// Add list_data_files tool
 	listFilesTool := mcp.NewTool("list_data_files",
-		mcp.WithDescription(`Lists all available JSON data files with metadata.
+		mcp.WithDescription(`Lists all available data files with metadata: JSON, JSON Lines (.jsonl/.ndjson), YAML (.yaml/.yml), TOML and CSV.
 
-Returns file paths (relative to data directory), modification times, sizes, and suggested query patterns.
+Returns file paths (relative to data directory), formats, compression, modification times, sizes (and uncompressed sizes where
+the compressed file records them), record counts for JSON Lines files, and suggested query patterns.
+Files inside zip and tar archives are listed under virtual paths such as bundle.zip/2025/01.json, with the archive they belong to.
+When the parsed-document cache is enabled, "cache" reports its hits, misses, evictions and size.
+Each file is also published as an MCP resource at gojq://data/{path}; append ?filter=<jq filter> to read a filtered view.
 
 REAL-TIME UPDATES:
-This server monitors the file system. When files change, clients receive
-'notifications/resources/list_changed'. Call this tool again for updated information.`),
+This server monitors the file system. When files are added or removed, clients receive
+'notifications/resources/list_changed'. Call this tool again for updated information.
+Clients can resources/subscribe to a file or glob pattern URI (e.g. gojq://data/2025-*/*.json)
+to receive 'notifications/resources/updated' when matching files change.`),
 	)
 
 	s.AddTool(listFilesTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 		manifest := fileRegistry.GetManifest()
+		if cache := fileRegistry.DocumentCache(); cache != nil {
+			manifest["cache"] = cache.Stats()
+		}
 		output, err := json.MarshalIndent(manifest, "", "  ")
 		if err != nil {
 			return mcp.NewToolResultError(fmt.Sprintf("error formatting manifest: %v", err)), nil
@@ -138,11 +633,41 @@ This server monitors the file system. When files change, clients receive
 		return mcp.NewToolResultText(string(output)), nil
 	})
 
+	fileRegistry.SetMCPServer(s)
+	fileRegistry.SetDocumentCache(jq.NewDocumentCache(cfg.CacheSize()))
+
 	return s, nil
 }
 
+// modeNames returns the names accepted by run_jq's mode parameter
+func modeNames() []string {
+	names := make([]string, len(jq.Modes))
+	for i, mode := range jq.Modes {
+		names[i] = string(mode)
+	}
+	return names
+}
+
+// sampleMethodNames returns the names accepted by sample_records' method parameter
+func sampleMethodNames() []string {
+	names := make([]string, len(jq.SampleMethods))
+	for i, method := range jq.SampleMethods {
+		names[i] = string(method)
+	}
+	return names
+}
+
+// outputFormatNames returns the names accepted by run_jq's output_format parameter
+func outputFormatNames() []string {
+	names := make([]string, len(jq.OutputFormats))
+	for i, format := range jq.OutputFormats {
+		names[i] = string(format)
+	}
+	return names
+}
+
 // StartServer starts the MCP server with the specified transport
-func StartServer(s *server.MCPServer, cfg *config.Config, authToken string) error {
+func StartServer(s *server.MCPServer, cfg *config.Config, fileRegistry *registry.FileRegistry, authToken string) error {
 	addressStr := fmt.Sprintf(":%d", cfg.Port)
 
 	switch cfg.Transport {
@@ -166,13 +691,19 @@ func StartServer(s *server.MCPServer, cfg *config.Config, authToken string) erro
 					auth.WriteUnauthorized(w)
 					return
 				}
-				httpServer.ServeHTTP(w, r)
+				subscriptionHandler(httpServer, fileRegistry, streamableSessionID).ServeHTTP(w, r)
 			})
 			srv := &http.Server{Addr: addressStr, Handler: handler}
 			httpServer = server.NewStreamableHTTPServer(s, server.WithStreamableHTTPServer(srv))
 			return httpServer.Start(addressStr)
 		} else {
-			httpServer := server.NewStreamableHTTPServer(s)
+			var httpServer *server.StreamableHTTPServer
+			mux := http.NewServeMux()
+			mux.Handle("/mcp", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				subscriptionHandler(httpServer, fileRegistry, streamableSessionID).ServeHTTP(w, r)
+			}))
+			srv := &http.Server{Addr: addressStr, Handler: mux}
+			httpServer = server.NewStreamableHTTPServer(s, server.WithStreamableHTTPServer(srv))
 			return httpServer.Start(addressStr)
 		}
 	case "sse":
@@ -189,14 +720,19 @@ func StartServer(s *server.MCPServer, cfg *config.Config, authToken string) erro
 					auth.WriteUnauthorized(w)
 					return
 				}
-				sseServerInstance.ServeHTTP(w, r)
+				subscriptionHandler(sseServerInstance, fileRegistry, sseSessionID).ServeHTTP(w, r)
 			})
 			srv := &http.Server{Addr: addressStr, Handler: handler}
 			opts := []server.SSEOption{server.WithHTTPServer(srv), server.WithAppendQueryToMessageEndpoint()}
 			sseServerInstance = server.NewSSEServer(s, opts...)
 			return sseServerInstance.Start(addressStr)
 		} else {
-			sseServer := server.NewSSEServer(s)
+			var sseServer *server.SSEServer
+			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				subscriptionHandler(sseServer, fileRegistry, sseSessionID).ServeHTTP(w, r)
+			})
+			srv := &http.Server{Addr: addressStr, Handler: handler}
+			sseServer = server.NewSSEServer(s, server.WithHTTPServer(srv))
 			return sseServer.Start(addressStr)
 		}
 	default:
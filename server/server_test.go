@@ -1,11 +1,14 @@
 package server
 
 import (
+	"context"
+	"encoding/json"
 	"os"
 	"path/filepath"
 	"testing"
 
 	"github.com/berrydev-ai/gojq-mcp/config"
+	"github.com/berrydev-ai/gojq-mcp/jq"
 	"github.com/berrydev-ai/gojq-mcp/registry"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -71,3 +74,281 @@ func TestSetupMCPServerWithTools(t *testing.T) {
 	// We can't easily test the MCP tool execution without MCP client setup
 	// But we can verify the server creation doesn't fail
 }
+
+func TestNewServer(t *testing.T) {
+	tempDir := t.TempDir()
+	err := os.WriteFile(filepath.Join(tempDir, "test.json"), []byte(`{"name": "test"}`), 0644)
+	require.NoError(t, err)
+
+	cfg := &config.Config{
+		DataPath:  tempDir,
+		Transport: "http",
+		Port:      8080,
+	}
+
+	s, err := NewServer(cfg, true)
+	require.NoError(t, err)
+	assert.NotNil(t, s.MCPServer)
+	assert.NotNil(t, s.Registry)
+	assert.Len(t, s.Registry.GetFiles(), 1)
+	assert.NoError(t, s.Close())
+}
+
+func TestRunJQPagination(t *testing.T) {
+	tempDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "data.json"), []byte(`[1, 2, 3, 4, 5]`), 0644))
+
+	fileRegistry, err := registry.NewFileRegistry(tempDir)
+	require.NoError(t, err)
+
+	cfg := &config.Config{DataPath: tempDir, Transport: "stdio", Port: 8080, MaxResultItems: 4}
+	s, err := SetupMCPServer(cfg, fileRegistry)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name      string
+		arguments string
+		expected  []string
+	}{
+		{
+			name:      "page with more results",
+			arguments: `"offset": 1, "limit": 2`,
+			expected:  []string{"[\n  2,\n  3\n]", "Showing 2 of 5 results starting at offset 1. Use offset=3 to see more."},
+		},
+		{
+			name:      "last page",
+			arguments: `"offset": 4, "limit": 2`,
+			expected:  []string{"[\n  5\n]"},
+		},
+		{
+			name:      "server item limit",
+			arguments: `"limit": 0`,
+			expected:  []string{"[\n  1,\n  2,\n  3,\n  4\n]", "Showing 4 of 5 results starting at offset 0. Use offset=4 to see more."},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			message := `{"jsonrpc": "2.0", "id": 1, "method": "tools/call", "params": {"name": "run_jq", "arguments": {"jq_filter": ".[]", "json_file_path": "data.json", ` + tt.arguments + `}}}`
+			response := s.HandleMessage(context.Background(), json.RawMessage(message))
+
+			texts, isError := toolResultTexts(t, response)
+			assert.False(t, isError)
+			assert.Equal(t, tt.expected, texts)
+		})
+	}
+}
+
+func TestRunJQVariables(t *testing.T) {
+	tempDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "data.json"), []byte(`[{"team": "core", "age": 41}, {"team": "web", "age": 35}]`), 0644))
+
+	fileRegistry, err := registry.NewFileRegistry(tempDir)
+	require.NoError(t, err)
+
+	cfg := &config.Config{DataPath: tempDir, Transport: "stdio", Port: 8080}
+	s, err := SetupMCPServer(cfg, fileRegistry)
+	require.NoError(t, err)
+
+	message := `{"jsonrpc": "2.0", "id": 1, "method": "tools/call", "params": {"name": "run_jq", "arguments": {"jq_filter": "[.[] | select(.team == $team and .age > $min_age)] | length", "json_file_path": "data.json", "variables": {"team": "core", "min_age": 40}}}}`
+	texts, isError := toolResultTexts(t, s.HandleMessage(context.Background(), json.RawMessage(message)))
+	assert.False(t, isError)
+	assert.Equal(t, []string{"1"}, texts)
+
+	message = `{"jsonrpc": "2.0", "id": 2, "method": "tools/call", "params": {"name": "run_jq", "arguments": {"jq_filter": ".", "json_file_path": "data.json", "variables": ["core"]}}}`
+	texts, isError = toolResultTexts(t, s.HandleMessage(context.Background(), json.RawMessage(message)))
+	assert.True(t, isError)
+	assert.Equal(t, []string{"variables must be an object mapping names to values"}, texts)
+}
+
+func TestRunJQMode(t *testing.T) {
+	tempDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "data.json"), []byte(`{"total": 5}`), 0644))
+
+	fileRegistry, err := registry.NewFileRegistry(tempDir)
+	require.NoError(t, err)
+
+	cfg := &config.Config{DataPath: tempDir, Transport: "stdio", Port: 8080}
+	s, err := SetupMCPServer(cfg, fileRegistry)
+	require.NoError(t, err)
+
+	message := `{"jsonrpc": "2.0", "id": 1, "method": "tools/call", "params": {"name": "run_jq", "arguments": {"jq_filter": "map(.total) | add", "json_file_path": "data.json", "mode": "slurp"}}}`
+	texts, isError := toolResultTexts(t, s.HandleMessage(context.Background(), json.RawMessage(message)))
+	assert.False(t, isError)
+	assert.Equal(t, []string{"5"}, texts)
+
+	message = `{"jsonrpc": "2.0", "id": 2, "method": "tools/call", "params": {"name": "run_jq", "arguments": {"jq_filter": ".", "json_file_path": "data.json", "mode": "merge"}}}`
+	texts, isError = toolResultTexts(t, s.HandleMessage(context.Background(), json.RawMessage(message)))
+	assert.True(t, isError)
+	assert.Equal(t, []string{`unsupported mode "merge"; use one of inputs, slurp, each`}, texts)
+}
+
+func TestInferSchemaTool(t *testing.T) {
+	tempDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "data.json"), []byte(`{"users": [{"name": "Alice", "age": 30}]}`), 0644))
+
+	fileRegistry, err := registry.NewFileRegistry(tempDir)
+	require.NoError(t, err)
+
+	cfg := &config.Config{DataPath: tempDir, Transport: "stdio", Port: 8080}
+	s, err := SetupMCPServer(cfg, fileRegistry)
+	require.NoError(t, err)
+
+	message := `{"jsonrpc": "2.0", "id": 1, "method": "tools/call", "params": {"name": "infer_schema", "arguments": {"json_file_path": "*.json", "json_schema": true}}}`
+	texts, isError := toolResultTexts(t, s.HandleMessage(context.Background(), json.RawMessage(message)))
+	require.False(t, isError, texts)
+	require.Len(t, texts, 1)
+
+	var response struct {
+		Files     []string `json:"files"`
+		Documents int      `json:"documents"`
+		Fields    []struct {
+			Path string `json:"path"`
+		} `json:"fields"`
+		JSONSchema map[string]interface{} `json:"json_schema"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(texts[0]), &response))
+	assert.Equal(t, []string{"data.json"}, response.Files)
+	assert.Equal(t, 1, response.Documents)
+	require.Len(t, response.Fields, 5)
+	assert.Equal(t, ".users[].name", response.Fields[4].Path)
+	assert.Equal(t, "https://json-schema.org/draft/2020-12/schema", response.JSONSchema["$schema"])
+
+	message = `{"jsonrpc": "2.0", "id": 2, "method": "tools/call", "params": {"name": "infer_schema", "arguments": {"json_file_path": "missing/*.json"}}}`
+	texts, isError = toolResultTexts(t, s.HandleMessage(context.Background(), json.RawMessage(message)))
+	assert.True(t, isError)
+	assert.Equal(t, []string{"no files found matching the provided patterns"}, texts)
+}
+
+func TestSampleRecordsTool(t *testing.T) {
+	tempDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "data.json"), []byte(`{"users": [{"name": "Alice"}, {"name": "Bob"}, {"name": "Carol"}]}`), 0644))
+
+	fileRegistry, err := registry.NewFileRegistry(tempDir)
+	require.NoError(t, err)
+
+	cfg := &config.Config{DataPath: tempDir, Transport: "stdio", Port: 8080}
+	s, err := SetupMCPServer(cfg, fileRegistry)
+	require.NoError(t, err)
+
+	message := `{"jsonrpc": "2.0", "id": 1, "method": "tools/call", "params": {"name": "sample_records", "arguments": {"json_file_path": "data.json", "path": ".users", "count": 2, "method": "reservoir", "seed": 3, "max_string_length": 3}}}`
+	texts, isError := toolResultTexts(t, s.HandleMessage(context.Background(), json.RawMessage(message)))
+	require.False(t, isError, texts)
+	require.Len(t, texts, 1)
+
+	var sample jq.Sample
+	require.NoError(t, json.Unmarshal([]byte(texts[0]), &sample))
+	assert.Equal(t, jq.SampleReservoir, sample.Method)
+	assert.Equal(t, int64(3), *sample.Seed)
+	assert.Equal(t, 3, *sample.Total)
+	require.Len(t, sample.Records, 2)
+	assert.Equal(t, "data.json", sample.Records[0].File)
+	for _, record := range sample.Records {
+		assert.LessOrEqual(t, len(record.Record.(map[string]interface{})["name"].(string)), len("Car…"))
+	}
+
+	message = `{"jsonrpc": "2.0", "id": 2, "method": "tools/call", "params": {"name": "sample_records", "arguments": {"json_file_path": "data.json", "method": "middle"}}}`
+	texts, isError = toolResultTexts(t, s.HandleMessage(context.Background(), json.RawMessage(message)))
+	assert.True(t, isError)
+	assert.Equal(t, []string{`unsupported sample method "middle"; use one of head, tail, random, reservoir`}, texts)
+}
+
+func TestValidateJSONSchemaTool(t *testing.T) {
+	tempDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "schema.json"), []byte(`{"required": ["id"]}`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "good.json"), []byte(`{"id": 1}`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "bad.json"), []byte(`{"name": "x"}`), 0644))
+
+	fileRegistry, err := registry.NewFileRegistry(tempDir)
+	require.NoError(t, err)
+
+	cfg := &config.Config{DataPath: tempDir, Transport: "stdio", Port: 8080}
+	s, err := SetupMCPServer(cfg, fileRegistry)
+	require.NoError(t, err)
+
+	message := `{"jsonrpc": "2.0", "id": 1, "method": "tools/call", "params": {"name": "validate_json_schema", "arguments": {"json_file_path": "*.json", "schema_path": "schema.json"}}}`
+	texts, isError := toolResultTexts(t, s.HandleMessage(context.Background(), json.RawMessage(message)))
+	require.False(t, isError, texts)
+	var report jq.ValidationReport
+	require.NoError(t, json.Unmarshal([]byte(texts[0]), &report))
+	assert.False(t, report.Valid)
+	require.Len(t, report.Files, 2)
+	assert.Equal(t, "bad.json", report.Files[0].File)
+	assert.Equal(t, "missing property 'id'", report.Files[0].Errors[0].Message)
+	assert.True(t, report.Files[1].Valid)
+
+	message = `{"jsonrpc": "2.0", "id": 2, "method": "tools/call", "params": {"name": "validate_json_schema", "arguments": {"json_file_path": "good.json", "schema": {"type": "array"}}}}`
+	texts, isError = toolResultTexts(t, s.HandleMessage(context.Background(), json.RawMessage(message)))
+	require.False(t, isError, texts)
+	require.NoError(t, json.Unmarshal([]byte(texts[0]), &report))
+	assert.False(t, report.Valid)
+
+	message = `{"jsonrpc": "2.0", "id": 3, "method": "tools/call", "params": {"name": "validate_json_schema", "arguments": {"json_file_path": "good.json"}}}`
+	texts, isError = toolResultTexts(t, s.HandleMessage(context.Background(), json.RawMessage(message)))
+	assert.True(t, isError)
+	assert.Equal(t, []string{"give either schema_path or schema"}, texts)
+}
+
+func TestDiffJSONTool(t *testing.T) {
+	tempDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "old.json"), []byte(`{"users": [{"id": 1, "name": "Alice"}, {"id": 2, "name": "Bob"}]}`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "new.json"), []byte(`{"users": [{"id": 0, "name": "Zed"}, {"id": 1, "name": "Alice"}, {"id": 2, "name": "Bobby"}]}`), 0644))
+
+	fileRegistry, err := registry.NewFileRegistry(tempDir)
+	require.NoError(t, err)
+
+	cfg := &config.Config{DataPath: tempDir, Transport: "stdio", Port: 8080}
+	s, err := SetupMCPServer(cfg, fileRegistry)
+	require.NoError(t, err)
+
+	message := `{"jsonrpc": "2.0", "id": 1, "method": "tools/call", "params": {"name": "diff_json", "arguments": {"left_path": "old.json", "right_path": "new.json", "array_key": "id"}}}`
+	texts, isError := toolResultTexts(t, s.HandleMessage(context.Background(), json.RawMessage(message)))
+	require.False(t, isError, texts)
+	require.Len(t, texts, 2)
+	var diff jq.Diff
+	require.NoError(t, json.Unmarshal([]byte(texts[0]), &diff))
+	require.Len(t, diff.Patch, 2)
+	assert.Equal(t, "add", diff.Patch[0].Op)
+	assert.Equal(t, "/users/0", diff.Patch[0].Path)
+	assert.Equal(t, "/users/2/name", diff.Patch[1].Path)
+	assert.Equal(t, "2 change(s): 1 added, 1 changed\n+ .users[id=0]: {\"id\":0,\"name\":\"Zed\"}\n~ .users[id=2].name: \"Bob\" → \"Bobby\"", texts[1])
+
+	message = `{"jsonrpc": "2.0", "id": 2, "method": "tools/call", "params": {"name": "diff_json", "arguments": {"left_path": "old.json", "right_path": "new.json", "left_filter": "[.users[].name]", "right_filter": "[.users[1:][].name]"}}}`
+	texts, isError = toolResultTexts(t, s.HandleMessage(context.Background(), json.RawMessage(message)))
+	require.False(t, isError, texts)
+	assert.Equal(t, "1 change(s): 1 changed\n~ .[1]: \"Bob\" → \"Bobby\"", texts[1])
+
+	message = `{"jsonrpc": "2.0", "id": 3, "method": "tools/call", "params": {"name": "diff_json", "arguments": {"left_path": "old.json", "right_path": " "}}}`
+	texts, isError = toolResultTexts(t, s.HandleMessage(context.Background(), json.RawMessage(message)))
+	assert.True(t, isError)
+	assert.Equal(t, []string{"left_path and right_path cannot be empty"}, texts)
+}
+
+func TestExplainJQTool(t *testing.T) {
+	tempDir := t.TempDir()
+	fileRegistry, err := registry.NewFileRegistry(tempDir)
+	require.NoError(t, err)
+
+	cfg := &config.Config{DataPath: tempDir, Transport: "stdio", Port: 8080}
+	s, err := SetupMCPServer(cfg, fileRegistry)
+	require.NoError(t, err)
+
+	message := `{"jsonrpc": "2.0", "id": 1, "method": "tools/call", "params": {"name": "explain_jq", "arguments": {"jq_filter": "[inputs | .. | select(.price > $min)]", "variables": {"min": 10}}}}`
+	texts, isError := toolResultTexts(t, s.HandleMessage(context.Background(), json.RawMessage(message)))
+	require.False(t, isError, texts)
+	var explanation jq.FilterExplanation
+	require.NoError(t, json.Unmarshal([]byte(texts[0]), &explanation))
+	assert.True(t, explanation.Valid)
+	assert.Equal(t, []string{"inputs/0", "select/1"}, explanation.Functions)
+	assert.Equal(t, []string{"$min"}, explanation.Variables)
+	assert.True(t, explanation.UsesInputs)
+	require.Len(t, explanation.Warnings, 1)
+	assert.Contains(t, explanation.Warnings[0], "'..' visits every value")
+
+	message = `{"jsonrpc": "2.0", "id": 2, "method": "tools/call", "params": {"name": "explain_jq", "arguments": {"jq_filter": ".users[] | select(.age > 30"}}}`
+	texts, isError = toolResultTexts(t, s.HandleMessage(context.Background(), json.RawMessage(message)))
+	require.False(t, isError, texts)
+	require.NoError(t, json.Unmarshal([]byte(texts[0]), &explanation))
+	assert.False(t, explanation.Valid)
+	assert.Equal(t, &jq.FilterError{Message: "unexpected EOF", Line: 1, Column: 28, Snippet: "  .users[] | select(.age > 30\n                             ^"}, explanation.Error)
+}
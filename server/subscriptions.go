@@ -0,0 +1,123 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/berrydev-ai/gojq-mcp/registry"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+const (
+	methodResourcesSubscribe   = "resources/subscribe"
+	methodResourcesUnsubscribe = "resources/unsubscribe"
+)
+
+// subscriptionMessage is the subset of a JSON-RPC request needed to handle subscriptions
+type subscriptionMessage struct {
+	ID     json.RawMessage `json:"id"`
+	Method string          `json:"method"`
+	Params struct {
+		URI string `json:"uri"`
+	} `json:"params"`
+}
+
+// subscriptionsSupported reports whether transport can deliver notifications/resources/updated
+// to the session that subscribed; over stdio subscriptions are refused, so they are not
+// advertised either
+func subscriptionsSupported(transport string) bool {
+	return transport == "http" || transport == "sse"
+}
+
+// streamableSessionID returns the client session of a streamable HTTP request
+func streamableSessionID(r *http.Request) string {
+	return r.Header.Get(server.HeaderKeySessionID)
+}
+
+// sseSessionID returns the client session of an SSE message request
+func sseSessionID(r *http.Request) string {
+	return r.URL.Query().Get("sessionId")
+}
+
+// subscriptionHandler implements resources/subscribe and resources/unsubscribe, which mcp-go
+// does not handle itself. Successful requests are recorded in the registry and forwarded as a
+// ping with the same request ID, so the transport replies with the empty result the protocol
+// requires on whichever channel it uses for responses. Rejected requests are forwarded
+// unchanged and answered by rejectSubscriptions.
+func subscriptionHandler(next http.Handler, fileRegistry *registry.FileRegistry, sessionID func(*http.Request) string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Streamable HTTP clients end their session with DELETE
+		if r.Method == http.MethodDelete {
+			if id := sessionID(r); id != "" {
+				fileRegistry.RemoveSession(id)
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if r.Method != http.MethodPost || r.Body == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		r.Body.Close()
+		if err != nil {
+			http.Error(w, "error reading request body", http.StatusBadRequest)
+			return
+		}
+
+		var message subscriptionMessage
+		if json.Unmarshal(body, &message) == nil && len(message.ID) > 0 {
+			switch message.Method {
+			case methodResourcesSubscribe:
+				if fileRegistry.Subscribe(sessionID(r), message.Params.URI) == nil {
+					body = pingRequest(message.ID)
+				}
+			case methodResourcesUnsubscribe:
+				if fileRegistry.Unsubscribe(sessionID(r), message.Params.URI) == nil {
+					body = pingRequest(message.ID)
+				}
+			}
+		}
+
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		r.ContentLength = int64(len(body))
+		next.ServeHTTP(w, r)
+	})
+}
+
+// pingRequest builds a ping request carrying the given JSON-RPC ID
+func pingRequest(id json.RawMessage) []byte {
+	return []byte(fmt.Sprintf(`{"jsonrpc":%q,"id":%s,"method":%q}`, mcp.JSONRPC_VERSION, id, mcp.MethodPing))
+}
+
+// rejectSubscriptions returns a hook that answers subscription requests that reached the MCP
+// server, either because subscriptionHandler rejected them or because the transport does not
+// support them, with a descriptive error instead of "method not found"
+func rejectSubscriptions() server.OnRequestInitializationFunc {
+	return func(ctx context.Context, id any, message any) error {
+		raw, ok := message.(json.RawMessage)
+		if !ok {
+			return nil
+		}
+
+		var request subscriptionMessage
+		if err := json.Unmarshal(raw, &request); err != nil {
+			return nil
+		}
+		if request.Method != methodResourcesSubscribe && request.Method != methodResourcesUnsubscribe {
+			return nil
+		}
+
+		if _, err := registry.SubscriptionPattern(request.Params.URI); err != nil {
+			return err
+		}
+		return fmt.Errorf("resource subscriptions require the http or sse transport")
+	}
+}
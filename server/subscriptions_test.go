@@ -0,0 +1,136 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/berrydev-ai/gojq-mcp/config"
+	"github.com/berrydev-ai/gojq-mcp/registry"
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/client/transport"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResourceSubscriptions(t *testing.T) {
+	tempDir := t.TempDir()
+	watched := filepath.Join(tempDir, "watched.json")
+	other := filepath.Join(tempDir, "other.json")
+	require.NoError(t, os.WriteFile(watched, []byte(`{"version": 1}`), 0644))
+	require.NoError(t, os.WriteFile(other, []byte(`{"version": 1}`), 0644))
+
+	fileRegistry, err := registry.NewFileRegistry(tempDir)
+	require.NoError(t, err)
+	defer fileRegistry.Close()
+
+	cfg := &config.Config{DataPath: tempDir, Transport: "http", Port: 8080}
+	s, err := SetupMCPServer(cfg, fileRegistry)
+	require.NoError(t, err)
+	require.NoError(t, fileRegistry.StartWatching())
+
+	httpServer := server.NewStreamableHTTPServer(s)
+	ts := httptest.NewServer(subscriptionHandler(httpServer, fileRegistry, streamableSessionID))
+	defer ts.Close()
+
+	c, err := client.NewStreamableHttpClient(ts.URL+"/mcp", transport.WithContinuousListening())
+	require.NoError(t, err)
+	defer c.Close()
+
+	notifications := make(chan mcp.JSONRPCNotification, 10)
+	c.OnNotification(func(notification mcp.JSONRPCNotification) {
+		notifications <- notification
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	require.NoError(t, c.Start(ctx))
+
+	initRequest := mcp.InitializeRequest{}
+	initRequest.Params.ProtocolVersion = mcp.LATEST_PROTOCOL_VERSION
+	initRequest.Params.ClientInfo = mcp.Implementation{Name: "test-client", Version: "1.0.0"}
+	initResult, err := c.Initialize(ctx, initRequest)
+	require.NoError(t, err)
+	require.NotNil(t, initResult.Capabilities.Resources)
+	assert.True(t, initResult.Capabilities.Resources.Subscribe)
+
+	subscribeRequest := mcp.SubscribeRequest{}
+	subscribeRequest.Params.URI = "gojq://data/watch*.json"
+	require.NoError(t, c.Subscribe(ctx, subscribeRequest))
+
+	badRequest := mcp.SubscribeRequest{}
+	badRequest.Params.URI = "file:///etc/passwd"
+	assert.Error(t, c.Subscribe(ctx, badRequest))
+
+	// Give the listening GET stream time to connect
+	time.Sleep(200 * time.Millisecond)
+
+	require.NoError(t, os.WriteFile(other, []byte(`{"version": 2, "changed": true}`), 0644))
+	require.NoError(t, os.WriteFile(watched, []byte(`{"version": 2, "changed": true}`), 0644))
+
+	select {
+	case notification := <-notifications:
+		assert.Equal(t, string(mcp.MethodNotificationResourceUpdated), notification.Method)
+		assert.Equal(t, "gojq://data/watched.json", notification.Params.AdditionalFields["uri"])
+	case <-ctx.Done():
+		t.Fatal("timed out waiting for resources/updated notification")
+	}
+
+	// Content changes do not alter the resource list, so nothing else is sent
+	select {
+	case notification := <-notifications:
+		t.Fatalf("unexpected notification %s", notification.Method)
+	case <-time.After(300 * time.Millisecond):
+	}
+}
+
+func TestResourceSubscriptions_UnsupportedTransport(t *testing.T) {
+	tempDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "a.json"), []byte(`{}`), 0644))
+
+	c := newTestClient(t, tempDir)
+
+	request := mcp.SubscribeRequest{}
+	request.Params.URI = "gojq://data/a.json"
+	err := c.Subscribe(context.Background(), request)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "require the http or sse transport")
+}
+
+func TestResourceSubscriptions_Capability(t *testing.T) {
+	tempDir := t.TempDir()
+	fileRegistry, err := registry.NewFileRegistry(tempDir)
+	require.NoError(t, err)
+	defer fileRegistry.Close()
+
+	for _, tc := range []struct {
+		transport string
+		subscribe bool
+	}{
+		{"stdio", false},
+		{"http", true},
+		{"sse", true},
+	} {
+		t.Run(tc.transport, func(t *testing.T) {
+			cfg := &config.Config{DataPath: tempDir, Transport: tc.transport, Port: 8080}
+			s, err := SetupMCPServer(cfg, fileRegistry)
+			require.NoError(t, err)
+
+			message := `{"jsonrpc": "2.0", "id": 1, "method": "initialize", "params": {"protocolVersion": "` +
+				mcp.LATEST_PROTOCOL_VERSION + `", "clientInfo": {"name": "test-client", "version": "1.0.0"}}}`
+			response, ok := s.HandleMessage(context.Background(), json.RawMessage(message)).(mcp.JSONRPCResponse)
+			require.True(t, ok)
+			result, ok := response.Result.(mcp.InitializeResult)
+			require.True(t, ok)
+			require.NotNil(t, result.Capabilities.Resources)
+			assert.Equal(t, tc.subscribe, result.Capabilities.Resources.Subscribe)
+			assert.True(t, result.Capabilities.Resources.ListChanged)
+		})
+	}
+}
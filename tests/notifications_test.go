@@ -0,0 +1,68 @@
+package tests
+
+import (
+	"context"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/berrydev-ai/gojq-mcp/config"
+	gojqserver "github.com/berrydev-ai/gojq-mcp/server"
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/client/transport"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPClientReceivesFileCreatedNotification(t *testing.T) {
+	tempDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "existing.json"), []byte(`{"id": 1}`), 0644))
+
+	cfg := &config.Config{DataPath: tempDir, Transport: "http", Port: 8080}
+	s, err := gojqserver.NewServer(cfg, true)
+	require.NoError(t, err)
+	defer s.Close()
+
+	ts := httptest.NewServer(server.NewStreamableHTTPServer(s.MCPServer))
+	defer ts.Close()
+
+	c, err := client.NewStreamableHttpClient(ts.URL+"/mcp", transport.WithContinuousListening())
+	require.NoError(t, err)
+	defer c.Close()
+
+	notifications := make(chan mcp.JSONRPCNotification, 10)
+	c.OnNotification(func(notification mcp.JSONRPCNotification) {
+		notifications <- notification
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	require.NoError(t, c.Start(ctx))
+
+	initRequest := mcp.InitializeRequest{}
+	initRequest.Params.ProtocolVersion = mcp.LATEST_PROTOCOL_VERSION
+	initRequest.Params.ClientInfo = mcp.Implementation{Name: "integration-test", Version: "1.0.0"}
+	_, err = c.Initialize(ctx, initRequest)
+	require.NoError(t, err)
+
+	// Give the listening GET stream time to connect
+	time.Sleep(200 * time.Millisecond)
+
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "created.json"), []byte(`{"id": 2}`), 0644))
+
+	select {
+	case notification := <-notifications:
+		assert.Equal(t, mcp.MethodNotificationResourcesListChanged, notification.Method)
+	case <-ctx.Done():
+		t.Fatal("timed out waiting for notifications/resources/list_changed")
+	}
+
+	resources, err := c.ListResources(ctx, mcp.ListResourcesRequest{})
+	require.NoError(t, err)
+	require.Len(t, resources.Resources, 2)
+	assert.Equal(t, "gojq://data/created.json", resources.Resources[0].URI)
+}